@@ -15,12 +15,18 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/mpapenbr/go-racelogger/log"
+	analyzeCmd "github.com/mpapenbr/go-racelogger/pkg/cmd/analyze"
+	captureCmd "github.com/mpapenbr/go-racelogger/pkg/cmd/capture"
 	"github.com/mpapenbr/go-racelogger/pkg/cmd/check"
 	importCmd "github.com/mpapenbr/go-racelogger/pkg/cmd/logimport"
+	inspectCmd "github.com/mpapenbr/go-racelogger/pkg/cmd/loginspect"
+	logtoolCmd "github.com/mpapenbr/go-racelogger/pkg/cmd/logtool"
 	pingCmd "github.com/mpapenbr/go-racelogger/pkg/cmd/ping"
 	recordCmd "github.com/mpapenbr/go-racelogger/pkg/cmd/record"
+	replayCmd "github.com/mpapenbr/go-racelogger/pkg/cmd/replay"
 	serverCmd "github.com/mpapenbr/go-racelogger/pkg/cmd/server"
 	statusCmd "github.com/mpapenbr/go-racelogger/pkg/cmd/status"
+	uploadCmd "github.com/mpapenbr/go-racelogger/pkg/cmd/upload"
 	"github.com/mpapenbr/go-racelogger/pkg/config"
 	"github.com/mpapenbr/go-racelogger/version"
 )
@@ -114,7 +120,13 @@ func init() {
 	rootCmd.AddCommand(statusCmd.NewStatusCmd())
 	rootCmd.AddCommand(check.NewVersionCheckCmd())
 	rootCmd.AddCommand(recordCmd.NewRecordCmd())
+	rootCmd.AddCommand(captureCmd.NewCaptureCmd())
+	rootCmd.AddCommand(replayCmd.NewReplayCmd())
+	rootCmd.AddCommand(uploadCmd.NewUploadCmd())
 	rootCmd.AddCommand(importCmd.NewImportCmd())
+	rootCmd.AddCommand(inspectCmd.NewInspectCmd())
+	rootCmd.AddCommand(logtoolCmd.NewLogtoolCmd())
+	rootCmd.AddCommand(analyzeCmd.NewAnalyzeCmd())
 	rootCmd.AddCommand(serverCmd.NewServerCmd())
 }
 
@@ -0,0 +1,322 @@
+// Package analyze rebuilds per-car lap histories from the state messages of a
+// msg log (see the analyze command).
+package analyze
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/go-racelogger/internal/inspect"
+	"github.com/mpapenbr/go-racelogger/internal/msgtool"
+	"github.com/mpapenbr/go-racelogger/log"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+type (
+	// Result contains the lap histories of all cars of an event
+	Result struct {
+		EventKey string `json:"eventKey"`
+		Event    string `json:"event"`
+		Track    string `json:"track"`
+		Cars     []*Car `json:"cars"`
+	}
+	Car struct {
+		CarIdx   int32    `json:"carIdx"`
+		CarNum   string   `json:"carNum"`
+		Name     string   `json:"name"`
+		CarClass string   `json:"carClass"`
+		Laps     []*Lap   `json:"laps"`
+		Stints   []*Stint `json:"stints"`
+	}
+	Lap struct {
+		Lap int32 `json:"lap"`
+		// lap time (0 if not available)
+		Time   float32 `json:"time"`
+		Marker string  `json:"marker"`
+		// overall and class position at the end of the lap
+		Pos   int32 `json:"pos"`
+		Pic   int32 `json:"pic"`
+		Stint int   `json:"stint"`
+		// normal, inlap, outlap or inoutlap
+		Mode string `json:"mode"`
+		// time measured by the racelogger for in/out laps
+		InOutTime   float32 `json:"inOutTime,omitempty"`
+		SessionTime float32 `json:"sessionTime"`
+	}
+	// Stint is the sequence of laps between two pit stops.
+	// A lap belongs to the stint in which it started.
+	Stint struct {
+		Num      int   `json:"num"`
+		StartLap int32 `json:"startLap"`
+		EndLap   int32 `json:"endLap"`
+		Laps     int   `json:"laps"`
+		// average and best time of the laps which are neither in nor out laps
+		AvgTime  float32 `json:"avgTime"`
+		BestTime float32 `json:"bestTime"`
+	}
+
+	Option   func(*Analyzer)
+	Analyzer struct {
+		eventKey string
+		current  string
+		result   Result
+		cars     map[int32]*carState
+		info     map[int32]carInfo
+	}
+	carInfo struct {
+		carNum, name, carClass string
+	}
+	carState struct {
+		car      *Car
+		lc       int32
+		pitstops uint32
+		stint    int
+		// stint at the start of the current lap
+		lapStint int
+		// lap modes reported by TimeInfo (lap number -> info)
+		modes map[int32]*racestatev1.TimeInfo
+	}
+)
+
+// WithEventKey analyzes the messages of this event.
+// By default the first registered event is used.
+func WithEventKey(key string) Option {
+	return func(a *Analyzer) { a.eventKey = key }
+}
+
+func NewAnalyzer(opts ...Option) *Analyzer {
+	ret := &Analyzer{
+		cars: map[int32]*carState{},
+		info: map[int32]carInfo{},
+	}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	return ret
+}
+
+// Analyze reads the msg log and returns the lap histories
+func Analyze(r io.Reader, opts ...Option) (*Result, error) {
+	a := NewAnalyzer(opts...)
+	reader := inspect.NewReader(r)
+	for {
+		rec, err := reader.Next()
+		if errors.Is(err, logger.ErrInvalidMessage) {
+			log.Warn("skipping invalid message", log.ErrorField(err))
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Warn("msg log ends within a record")
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		a.Add(rec.Msg)
+	}
+	return a.Result(), nil
+}
+
+// Add processes the next message of the msg log
+func (a *Analyzer) Add(msg proto.Message) {
+	key := msgtool.EventKey(msg)
+	if reg, ok := msg.(*providerv1.RegisterEventRequest); ok {
+		a.current = key
+		if a.eventKey == "" {
+			a.eventKey = key
+		}
+		if key == a.eventKey {
+			a.result.EventKey = key
+			a.result.Event = reg.GetEvent().GetName()
+			a.result.Track = reg.GetTrack().GetName()
+		}
+		return
+	}
+	if key == "" {
+		key = a.current
+	}
+	if key != a.eventKey {
+		return
+	}
+	switch req := msg.(type) {
+	case *racestatev1.PublishDriverDataRequest:
+		a.addDriverData(req)
+	case *racestatev1.PublishStateRequest:
+		a.addState(req)
+	}
+}
+
+func (a *Analyzer) addDriverData(req *racestatev1.PublishDriverDataRequest) {
+	classes := map[int32]string{}
+	for _, c := range req.CarClasses {
+		classes[int32(c.Id)] = c.Name //nolint:gosec // by design
+	}
+	for _, e := range req.Entries {
+		carIdx := int32(e.GetCar().GetCarIdx()) //nolint:gosec // by design
+		info := carInfo{
+			carNum:   e.GetCar().GetCarNumber(),
+			name:     e.GetTeam().GetName(),
+			carClass: classes[e.GetCar().GetCarClassId()],
+		}
+		if name, ok := req.CurrentDrivers[uint32(carIdx)]; ok && info.name == "" {
+			info.name = name
+		}
+		a.info[carIdx] = info
+		if cs, ok := a.cars[carIdx]; ok {
+			cs.setInfo(info)
+		}
+	}
+}
+
+func (a *Analyzer) addState(req *racestatev1.PublishStateRequest) {
+	sessionTime := req.GetSession().GetSessionTime()
+	for _, c := range req.Cars {
+		cs, ok := a.cars[c.CarIdx]
+		if !ok {
+			cs = &carState{
+				car:      &Car{CarIdx: c.CarIdx},
+				lc:       c.Lc,
+				pitstops: c.Pitstops,
+				stint:    1,
+				lapStint: 1,
+				modes:    map[int32]*racestatev1.TimeInfo{},
+			}
+			cs.setInfo(a.info[c.CarIdx])
+			a.cars[c.CarIdx] = cs
+		}
+		cs.update(c, sessionTime)
+	}
+}
+
+func (cs *carState) setInfo(info carInfo) {
+	cs.car.CarNum = info.carNum
+	cs.car.Name = info.name
+	cs.car.CarClass = info.carClass
+}
+
+func (cs *carState) update(c *racestatev1.Car, sessionTime float32) {
+	if c.TimeInfo != nil {
+		cs.modes[c.TimeInfo.LapNo] = c.TimeInfo
+	}
+	laps := cs.car.Laps
+	switch {
+	case c.Lc > cs.lc && c.Lc > 0:
+		cs.car.Laps = append(laps, &Lap{
+			Lap:         c.Lc,
+			Time:        c.GetLast().GetTime(),
+			Marker:      marker(c.GetLast()),
+			Pos:         c.Pos,
+			Pic:         c.Pic,
+			Stint:       cs.lapStint,
+			SessionTime: sessionTime,
+		})
+		cs.lc = c.Lc
+		cs.lapStint = cs.stint
+	case len(laps) > 0 && laps[len(laps)-1].Lap == c.Lc &&
+		c.GetLast().GetTime() > 0 && c.GetLast().GetTime() != laps[len(laps)-1].Time:
+		// the lap time may be computed after the lap count changed
+		laps[len(laps)-1].Time = c.GetLast().GetTime()
+		laps[len(laps)-1].Marker = marker(c.GetLast())
+	}
+	if c.Pitstops > cs.pitstops {
+		cs.pitstops = c.Pitstops
+		cs.stint++
+	}
+}
+
+func marker(t *racestatev1.TimeWithMarker) string {
+	name := strings.TrimPrefix(t.GetMarker().String(), "TIME_MARKER_")
+	if t.GetMarker() == racestatev1.TimeMarker_TIME_MARKER_UNSPECIFIED {
+		return ""
+	}
+	return strings.ToLower(name)
+}
+
+func lapMode(m commonv1.LapMode) string {
+	//nolint:exhaustive // normal is the default
+	switch m {
+	case commonv1.LapMode_LAP_MODE_INLAP:
+		return "inlap"
+	case commonv1.LapMode_LAP_MODE_OUTLAP:
+		return "outlap"
+	case commonv1.LapMode_LAP_MODE_INOUTLAP:
+		return "inoutlap"
+	default:
+		return "normal"
+	}
+}
+
+// Result returns the lap histories of the messages processed so far.
+// The cars are ordered by car number.
+func (a *Analyzer) Result() *Result {
+	ret := a.result
+	ret.Cars = make([]*Car, 0, len(a.cars))
+	for _, cs := range a.cars {
+		for _, lap := range cs.car.Laps {
+			lap.Mode = lapMode(commonv1.LapMode_LAP_MODE_NORMAL)
+			if ti, ok := cs.modes[lap.Lap]; ok {
+				lap.Mode = lapMode(ti.LapMode)
+				lap.InOutTime = ti.Time
+			}
+		}
+		cs.car.Stints = stints(cs.car.Laps)
+		ret.Cars = append(ret.Cars, cs.car)
+	}
+	slices.SortFunc(ret.Cars, func(a, b *Car) int {
+		if c := compareCarNum(a.CarNum, b.CarNum); c != 0 {
+			return c
+		}
+		return int(a.CarIdx - b.CarIdx)
+	})
+	return &ret
+}
+
+// compareCarNum compares car numbers numerically if possible
+func compareCarNum(a, b string) int {
+	if len(a) != len(b) && strings.TrimLeft(a, "0123456789") == "" &&
+		strings.TrimLeft(b, "0123456789") == "" {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
+
+func stints(laps []*Lap) []*Stint {
+	ret := []*Stint{}
+	var cur *Stint
+	var sum float32
+	var count int
+	finish := func() {
+		if cur != nil && count > 0 {
+			cur.AvgTime = sum / float32(count)
+		}
+	}
+	for _, lap := range laps {
+		if cur == nil || lap.Stint != cur.Num {
+			finish()
+			cur = &Stint{Num: lap.Stint, StartLap: lap.Lap}
+			sum, count = 0, 0
+			ret = append(ret, cur)
+		}
+		cur.EndLap = lap.Lap
+		cur.Laps++
+		if lap.Mode == "normal" && lap.Time > 0 {
+			sum += lap.Time
+			count++
+			if cur.BestTime == 0 || lap.Time < cur.BestTime {
+				cur.BestTime = lap.Time
+			}
+		}
+	}
+	finish()
+	return ret
+}
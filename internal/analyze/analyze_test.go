@@ -0,0 +1,164 @@
+package analyze
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	carv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/car/v1"
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	driverv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/driver/v1"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+func sel(key string) *commonv1.EventSelector {
+	return &commonv1.EventSelector{Arg: &commonv1.EventSelector_Key{Key: key}}
+}
+
+func last(t float32, m racestatev1.TimeMarker) *racestatev1.TimeWithMarker {
+	return &racestatev1.TimeWithMarker{Time: t, Marker: m}
+}
+
+// state returns a state message containing car (as car 1) and car 2, which
+// completes a lap every 100 seconds
+func state(key string, st float32, car *racestatev1.Car) proto.Message {
+	car.CarIdx = 1
+	return &racestatev1.PublishStateRequest{
+		Event:   sel(key),
+		Session: &racestatev1.Session{SessionTime: st},
+		Cars: []*racestatev1.Car{
+			car,
+			{CarIdx: 2, Lc: int32(st / 100), Pos: 2, Pic: 1, Last: last(100, 0)},
+		},
+	}
+}
+
+//nolint:funlen // test data
+func testMsgs() []proto.Message {
+	best := racestatev1.TimeMarker_TIME_MARKER_OVERALL_BEST
+	return []proto.Message{
+		&providerv1.RegisterEventRequest{
+			Event: &eventv1.Event{Key: "ev", Name: "Test race"},
+			Track: &trackv1.Track{Name: "Spa"},
+			Key:   "ev",
+		},
+		&racestatev1.PublishDriverDataRequest{
+			Event:      sel("ev"),
+			CarClasses: []*carv1.CarClass{{Id: 5, Name: "GT3"}},
+			Entries: []*carv1.CarEntry{
+				{
+					Car:  &carv1.Car{CarIdx: 1, CarNumber: "7", CarClassId: 5},
+					Team: &driverv1.Team{Name: "Team A"},
+				},
+				{
+					Car:  &carv1.Car{CarIdx: 2, CarNumber: "12", CarClassId: 5},
+					Team: &driverv1.Team{Name: "Team B"},
+				},
+			},
+		},
+		state("ev", 10, &racestatev1.Car{Lc: 0, Pos: 1}),
+		state("ev", 100, &racestatev1.Car{Lc: 1, Pos: 1, Pic: 1, Last: last(90, best)}),
+		// lap time arrives after the lap count
+		state("ev", 190, &racestatev1.Car{Lc: 2, Pos: 1, Pic: 1}),
+		state("ev", 191, &racestatev1.Car{Lc: 2, Pos: 1, Pic: 1, Last: last(91, 0)}),
+		// pit entry during lap 3
+		state("ev", 250, &racestatev1.Car{Lc: 2, Pos: 1, Pic: 1, Pitstops: 1}),
+		state("ev", 310, &racestatev1.Car{
+			Lc: 3, Pos: 2, Pic: 2, Pitstops: 1, Last: last(120, 0),
+			TimeInfo: &racestatev1.TimeInfo{
+				Time: 119, LapMode: commonv1.LapMode_LAP_MODE_INLAP, LapNo: 3,
+			},
+		}),
+		state("ev", 440, &racestatev1.Car{
+			Lc: 4, Pos: 2, Pic: 2, Pitstops: 1, Last: last(130, 0),
+			TimeInfo: &racestatev1.TimeInfo{
+				Time: 131, LapMode: commonv1.LapMode_LAP_MODE_OUTLAP, LapNo: 4,
+			},
+		}),
+		state("ev", 530, &racestatev1.Car{
+			Lc: 5, Pos: 2, Pic: 2, Pitstops: 1, Last: last(92, 0),
+		}),
+		state("ev", 625, &racestatev1.Car{
+			Lc: 6, Pos: 1, Pic: 1, Pitstops: 1, Last: last(94, 0),
+		}),
+		&providerv1.UnregisterEventRequest{EventSelector: sel("ev")},
+		// another event in the same file
+		&providerv1.RegisterEventRequest{Event: &eventv1.Event{Key: "other"}, Key: "other"},
+		state("other", 700, &racestatev1.Car{Lc: 10, Pos: 3, Last: last(80, 0)}),
+	}
+}
+
+func writeLog(t *testing.T, msgs []proto.Message) []byte {
+	t.Helper()
+	buf := bytes.Buffer{}
+	w := logger.NewMsgLogger(logger.WithWriter(&buf), logger.WithStateDelta(3))
+	for _, msg := range msgs {
+		require.NoError(t, w.Log(msg.ProtoReflect()))
+	}
+	return buf.Bytes()
+}
+
+func TestAnalyze(t *testing.T) {
+	res, err := Analyze(bytes.NewReader(writeLog(t, testMsgs())))
+	require.NoError(t, err)
+	assert.Equal(t, "ev", res.EventKey)
+	assert.Equal(t, "Spa", res.Track)
+	require.Len(t, res.Cars, 2)
+
+	car := res.Cars[0]
+	assert.Equal(t, "7", car.CarNum)
+	assert.Equal(t, "Team A", car.Name)
+	assert.Equal(t, "GT3", car.CarClass)
+	require.Len(t, car.Laps, 6)
+	assert.Equal(t, &Lap{
+		Lap: 1, Time: 90, Marker: "overall_best", Pos: 1, Pic: 1, Stint: 1,
+		Mode: "normal", SessionTime: 100,
+	}, car.Laps[0])
+	assert.InDelta(t, 91, car.Laps[1].Time, 0.001)
+	assert.Equal(t, "inlap", car.Laps[2].Mode)
+	assert.InDelta(t, 119, car.Laps[2].InOutTime, 0.001)
+	assert.Equal(t, 1, car.Laps[2].Stint)
+	assert.Equal(t, "outlap", car.Laps[3].Mode)
+	assert.Equal(t, 2, car.Laps[3].Stint)
+	assert.Equal(t, []*Stint{
+		{Num: 1, StartLap: 1, EndLap: 3, Laps: 3, AvgTime: 90.5, BestTime: 90},
+		{Num: 2, StartLap: 4, EndLap: 6, Laps: 3, AvgTime: 93, BestTime: 92},
+	}, car.Stints)
+
+	assert.Equal(t, "12", res.Cars[1].CarNum)
+	assert.Len(t, res.Cars[1].Laps, 6)
+}
+
+func TestAnalyze_EventKey(t *testing.T) {
+	res, err := Analyze(bytes.NewReader(writeLog(t, testMsgs())), WithEventKey("other"))
+	require.NoError(t, err)
+	require.Len(t, res.Cars, 2)
+	car := res.Cars[0]
+	assert.Empty(t, car.CarNum)
+	// no lap was completed while recording this event
+	assert.Empty(t, car.Laps)
+}
+
+func TestWriteCSV(t *testing.T) {
+	res, err := Analyze(bytes.NewReader(writeLog(t, testMsgs())))
+	require.NoError(t, err)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, WriteLapsCSV(&buf, res))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 13)
+	assert.Equal(t, "1,7,Team A,GT3,3,120.000,,2,2,1,inlap,119.000,310.000", lines[3])
+
+	buf.Reset()
+	require.NoError(t, WriteStintsCSV(&buf, res))
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, "1,7,Team A,GT3,2,4,6,3,93.000,92.000", lines[2])
+}
@@ -0,0 +1,68 @@
+package analyze
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// WriteJSON writes the result as indented JSON
+func WriteJSON(w io.Writer, res *Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res)
+}
+
+// WriteLapsCSV writes one line per car and lap
+func WriteLapsCSV(w io.Writer, res *Result) error {
+	out := csv.NewWriter(w)
+	//nolint:errcheck // errors are reported by out.Error
+	out.Write([]string{
+		"carIdx", "carNum", "name", "carClass", "lap", "time", "marker",
+		"pos", "pic", "stint", "mode", "inOutTime", "sessionTime",
+	})
+	for _, car := range res.Cars {
+		for _, lap := range car.Laps {
+			//nolint:errcheck // errors are reported by out.Error
+			out.Write([]string{
+				itoa(car.CarIdx), car.CarNum, car.Name, car.CarClass,
+				itoa(lap.Lap), ftoa(lap.Time), lap.Marker,
+				itoa(lap.Pos), itoa(lap.Pic), strconv.Itoa(lap.Stint), lap.Mode,
+				ftoa(lap.InOutTime), ftoa(lap.SessionTime),
+			})
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteStintsCSV writes one line per car and stint
+func WriteStintsCSV(w io.Writer, res *Result) error {
+	out := csv.NewWriter(w)
+	//nolint:errcheck // errors are reported by out.Error
+	out.Write([]string{
+		"carIdx", "carNum", "name", "carClass", "stint", "startLap", "endLap",
+		"laps", "avgTime", "bestTime",
+	})
+	for _, car := range res.Cars {
+		for _, s := range car.Stints {
+			//nolint:errcheck // errors are reported by out.Error
+			out.Write([]string{
+				itoa(car.CarIdx), car.CarNum, car.Name, car.CarClass,
+				strconv.Itoa(s.Num), itoa(s.StartLap), itoa(s.EndLap),
+				strconv.Itoa(s.Laps), ftoa(s.AvgTime), ftoa(s.BestTime),
+			})
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func itoa(i int32) string {
+	return strconv.FormatInt(int64(i), 10)
+}
+
+func ftoa(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', 3, 32)
+}
@@ -0,0 +1,117 @@
+// Package clock provides the time source used by the processor and racelogger.
+// Live recordings use the wall clock, replays derive the time from the iRacing
+// SessionTime and tests use a fake clock.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Sleep(d time.Duration)
+}
+
+// Real returns a clock backed by the wall clock
+func Real() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                  { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration { return time.Since(t) }
+func (realClock) Sleep(d time.Duration)           { time.Sleep(d) }
+
+// SessionClock derives the time from the iRacing SessionTime.
+// Now returns the start time plus the session time elapsed since the first update.
+// The clock only advances by calls to Update, Sleep returns immediately.
+type SessionClock struct {
+	mu          sync.Mutex
+	start       time.Time
+	base        float64 // session time at start
+	current     float64 // latest session time
+	initialized bool
+}
+
+var _ Clock = (*SessionClock)(nil)
+
+// NewSessionClock creates a clock that starts at start with the first Update
+func NewSessionClock(start time.Time) *SessionClock {
+	return &SessionClock{start: start}
+}
+
+// Update sets the current session time.
+// If the session time goes backwards (new session) the clock continues
+// from its current time.
+func (c *SessionClock) Update(sessionTime float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case !c.initialized:
+		c.base = sessionTime
+		c.initialized = true
+	case sessionTime < c.current:
+		c.start = c.now()
+		c.base = sessionTime
+	}
+	c.current = sessionTime
+}
+
+func (c *SessionClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now()
+}
+
+func (c *SessionClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Sleep does nothing. Session time only advances with new telemetry data.
+func (c *SessionClock) Sleep(d time.Duration) {}
+
+func (c *SessionClock) now() time.Time {
+	return c.start.Add(time.Duration((c.current - c.base) * float64(time.Second)))
+}
+
+// Fake is a clock for tests. It only advances by calls to Advance, Set or Sleep.
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+var _ Clock = (*Fake)(nil)
+
+func NewFake(t time.Time) *Fake {
+	return &Fake{now: t}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// Sleep advances the clock by d
+func (f *Fake) Sleep(d time.Duration) {
+	f.Advance(d)
+}
+
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = t
+}
@@ -0,0 +1,39 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var start = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func TestSessionClock(t *testing.T) {
+	c := NewSessionClock(start)
+	c.Update(100)
+	assert.Equal(t, start, c.Now())
+
+	c.Update(101.5)
+	assert.Equal(t, start.Add(1500*time.Millisecond), c.Now())
+	assert.Equal(t, 1500*time.Millisecond, c.Since(start))
+
+	c.Sleep(time.Minute)
+	assert.Equal(t, start.Add(1500*time.Millisecond), c.Now())
+
+	// new session: session time starts again, clock continues
+	c.Update(10)
+	assert.Equal(t, start.Add(1500*time.Millisecond), c.Now())
+	c.Update(12)
+	assert.Equal(t, start.Add(3500*time.Millisecond), c.Now())
+}
+
+func TestFake(t *testing.T) {
+	f := NewFake(start)
+	f.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), f.Now())
+	f.Sleep(2 * time.Second)
+	assert.Equal(t, 3*time.Second, f.Since(start))
+	f.Set(start)
+	assert.Equal(t, start, f.Now())
+}
@@ -0,0 +1,161 @@
+package inspect
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	carv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/car/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// type names accepted by the filter (besides the message names)
+var typeAliases = map[string]string{
+	"register":   "RegisterEventRequest",
+	"unregister": "UnregisterEventRequest",
+	"state":      "PublishStateRequest",
+	"driverdata": "PublishDriverDataRequest",
+	"speedmap":   "PublishSpeedmapRequest",
+	"extrainfo":  "PublishEventExtraInfoRequest",
+}
+
+// Filter selects messages by type, car number and session time.
+// Empty values don't restrict the selection.
+type Filter struct {
+	// message names or aliases (state, driverdata, speedmap, ...)
+	Types []string
+	// car number as displayed in iRacing.
+	// Only car related messages are selected and reduced to the data of this car.
+	CarNum string
+	// session time window. To = 0 means no limit.
+	From, To time.Duration
+
+	types       map[string]bool
+	carIdx      map[string]int32 // car number -> car index (from driver data)
+	sessionTime float64          // session time of the last message containing one
+}
+
+// ValidateTypes returns an error if a type is unknown
+func (f *Filter) ValidateTypes() error {
+	known := map[string]bool{}
+	for alias, name := range typeAliases {
+		known[alias] = true
+		known[name] = true
+	}
+	for _, t := range f.Types {
+		if !known[t] {
+			return fmt.Errorf("unknown message type %q", t)
+		}
+	}
+	return nil
+}
+
+// Apply returns the message of rec to be shown or nil if it is not selected.
+// Records have to be passed in the order of the file.
+func (f *Filter) Apply(rec *Record) proto.Message {
+	f.track(rec.Msg)
+	if !f.matchType(rec.Msg) {
+		return nil
+	}
+	if !f.inWindow() {
+		return nil
+	}
+	if f.CarNum == "" {
+		return rec.Msg
+	}
+	return f.reduceToCar(rec.Msg)
+}
+
+func (f *Filter) inWindow() bool {
+	return f.sessionTime >= f.From.Seconds() &&
+		(f.To <= 0 || f.sessionTime <= f.To.Seconds())
+}
+
+func (f *Filter) track(msg proto.Message) {
+	if st, ok := sessionTime(msg); ok {
+		f.sessionTime = st
+	}
+	switch req := msg.(type) {
+	case *providerv1.RegisterEventRequest:
+		f.sessionTime = 0
+	case *racestatev1.PublishDriverDataRequest:
+		f.carIdx = map[string]int32{}
+		for _, e := range req.Entries {
+			//nolint:gosec // car index < 64
+			f.carIdx[e.GetCar().GetCarNumber()] = int32(e.GetCar().GetCarIdx())
+		}
+	}
+}
+
+func (f *Filter) matchType(msg proto.Message) bool {
+	if len(f.Types) == 0 {
+		return true
+	}
+	if f.types == nil {
+		f.types = map[string]bool{}
+		for _, t := range f.Types {
+			if name, ok := typeAliases[t]; ok {
+				t = name
+			}
+			f.types[t] = true
+		}
+	}
+	return f.types[string(msg.ProtoReflect().Descriptor().Name())]
+}
+
+// reduceToCar returns a copy of msg containing only the data of the car.
+// Returns nil for messages not related to cars.
+func (f *Filter) reduceToCar(msg proto.Message) proto.Message {
+	idx, ok := f.carIdx[f.CarNum]
+	if !ok {
+		return nil
+	}
+	switch req := msg.(type) {
+	case *racestatev1.PublishStateRequest:
+		ret := &racestatev1.PublishStateRequest{
+			Event:     req.Event,
+			Session:   req.Session,
+			Timestamp: req.Timestamp,
+		}
+		for _, c := range req.Cars {
+			if c.CarIdx == idx {
+				ret.Cars = append(ret.Cars, c)
+			}
+		}
+		for _, m := range req.Messages {
+			if m.CarNum == f.CarNum {
+				ret.Messages = append(ret.Messages, m)
+			}
+		}
+		return ret
+	case *racestatev1.PublishDriverDataRequest:
+		ret := proto.Clone(req).(*racestatev1.PublishDriverDataRequest)
+		ret.Entries = []*carv1.CarEntry{}
+		for _, e := range req.Entries {
+			if e.GetCar().GetCarNumber() == f.CarNum {
+				ret.Entries = append(ret.Entries, e)
+			}
+		}
+		return ret
+	}
+	return nil
+}
+
+// WriteJSON writes msg of rec as a single line of JSON. Format:
+// {"index":<n>,"timestamp":"<capture time>","type":"<name>","data":<message>}
+func WriteJSON(w io.Writer, rec *Record, msg proto.Message) error {
+	b, err := protojson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	ts := ""
+	if !rec.Timestamp.IsZero() {
+		ts = rec.Timestamp.Format(time.RFC3339Nano)
+	}
+	_, err = fmt.Fprintf(w, "{\"index\":%d,\"timestamp\":%q,\"type\":%q,\"data\":%s}\n",
+		rec.Index, ts, msg.ProtoReflect().Descriptor().Name(), b)
+	return err
+}
@@ -0,0 +1,94 @@
+// Package inspect analyzes msg log files (see the inspect command).
+package inspect
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/go-racelogger/log"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+	"github.com/mpapenbr/go-racelogger/pkg/statedelta"
+)
+
+type (
+	// Record is a single message of a msg log
+	Record struct {
+		// position of the record in the file (starting at 0)
+		Index int
+		// capture time of the record (zero for v1 files)
+		Timestamp time.Time
+		Msg       proto.Message
+	}
+	// Reader reads the records of a msg log.
+	// Delta encoded state messages are returned as complete messages.
+	Reader struct {
+		m       *logger.MsgLogger
+		decoder *statedelta.Decoder
+		index   int
+	}
+)
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		m:       logger.NewMsgLogger(logger.WithReader(r)),
+		decoder: statedelta.NewDecoder(),
+	}
+}
+
+// Next returns the next record. Records of unknown message types are skipped.
+// Returns io.EOF at the end of the file.
+// Messages which could not be decoded are reported by logger.ErrInvalidMessage.
+// The reader may continue after such an error.
+func (r *Reader) Next() (*Record, error) {
+	for {
+		msg, err := r.m.ReadNext()
+		if err != nil {
+			if errors.Is(err, logger.ErrInvalidMessage) {
+				r.index++
+			}
+			return nil, err
+		}
+		index := r.index
+		r.index++
+		if msg == nil {
+			continue
+		}
+		rec := &Record{Index: index, Timestamp: r.m.Timestamp(), Msg: msg.Interface()}
+		if state, ok := rec.Msg.(*racestatev1.PublishStateRequest); ok {
+			full, err := r.decoder.Decode(state)
+			if err != nil {
+				log.Debug("could not decode state delta",
+					log.Int("index", index), log.ErrorField(err))
+			} else {
+				rec.Msg = full
+			}
+		}
+		return rec, nil
+	}
+}
+
+// Logger returns the underlying msg logger (file header, skipped records)
+func (r *Reader) Logger() *logger.MsgLogger {
+	return r.m
+}
+
+// sessionTime returns the session time of msg if it contains one
+func sessionTime(msg proto.Message) (float64, bool) {
+	switch req := msg.(type) {
+	case *racestatev1.PublishStateRequest:
+		if req.Session != nil {
+			return float64(req.Session.SessionTime), true
+		}
+	case *racestatev1.PublishDriverDataRequest:
+		return float64(req.SessionTime), true
+	case *racestatev1.PublishSpeedmapRequest:
+		if req.Speedmap != nil {
+			return float64(req.Speedmap.SessionTime), true
+		}
+	}
+	return 0, false
+}
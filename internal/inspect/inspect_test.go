@@ -0,0 +1,159 @@
+package inspect
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	carv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/car/v1"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+var start = time.Date(2024, 3, 17, 14, 0, 0, 0, time.UTC)
+
+func state(sessionTime float32) *racestatev1.PublishStateRequest {
+	return &racestatev1.PublishStateRequest{
+		Timestamp: timestamppb.New(start.Add(time.Duration(sessionTime) * time.Second)),
+		Session:   &racestatev1.Session{SessionTime: sessionTime},
+		Cars: []*racestatev1.Car{
+			{CarIdx: 3, Pos: 1},
+			{CarIdx: 5, Pos: 2},
+		},
+		Messages: []*racestatev1.Message{{CarNum: "12", Msg: "pit"}},
+	}
+}
+
+// writeLog creates a msg log with state messages at the given session times
+func writeLog(t *testing.T, opts ...logger.Option) []byte {
+	t.Helper()
+	buf := bytes.Buffer{}
+	w := logger.NewMsgLogger(append(opts, logger.WithWriter(&buf))...)
+	msgs := []proto.Message{
+		&providerv1.RegisterEventRequest{
+			Event: &eventv1.Event{Key: "myEvent"},
+			Track: &trackv1.Track{Name: "Sebring"},
+		},
+		&racestatev1.PublishDriverDataRequest{
+			SessionTime: 10,
+			Entries: []*carv1.CarEntry{
+				{Car: &carv1.Car{CarIdx: 3, CarNumber: "7"}},
+				{Car: &carv1.Car{CarIdx: 5, CarNumber: "12"}},
+			},
+		},
+		state(10), state(11), state(12),
+		// gap
+		state(20),
+		&racestatev1.PublishSpeedmapRequest{},
+		&providerv1.UnregisterEventRequest{},
+	}
+	for _, msg := range msgs {
+		require.NoError(t, w.Log(msg.ProtoReflect()))
+	}
+	return buf.Bytes()
+}
+
+func TestSummarize(t *testing.T) {
+	// delta encoding must not affect the result
+	data := writeLog(t, logger.WithStateDelta(2))
+	s := Summarize(bytes.NewReader(data), 5*time.Second)
+	require.NoError(t, s.Err)
+	assert.Equal(t, logger.FormatV2, s.Format)
+	assert.Equal(t, "myEvent", s.EventKey)
+	assert.Equal(t, "Sebring", s.Track)
+	assert.Equal(t, 8, s.Total)
+	assert.Equal(t, 4, s.Counts["PublishStateRequest"])
+	assert.Equal(t, start.Add(10*time.Second), s.First)
+	assert.Equal(t, start.Add(20*time.Second), s.Last)
+	assert.InDelta(t, 10, s.SessionTimeFrom, 1e-6)
+	assert.InDelta(t, 20, s.SessionTimeTo, 1e-6)
+	require.Len(t, s.Gaps, 1)
+	assert.InDelta(t, 12, s.Gaps[0].SessionTimeFrom, 1e-6)
+	assert.InDelta(t, 20, s.Gaps[0].SessionTimeTo, 1e-6)
+}
+
+func applyFilter(t *testing.T, f *Filter, data []byte) []proto.Message {
+	t.Helper()
+	r := NewReader(bytes.NewReader(data))
+	ret := []proto.Message{}
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return ret
+		}
+		require.NoError(t, err)
+		if msg := f.Apply(rec); msg != nil {
+			ret = append(ret, msg)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	data := writeLog(t)
+
+	t.Run("type and session time", func(t *testing.T) {
+		f := &Filter{Types: []string{"state"}, From: 11 * time.Second, To: 12 * time.Second}
+		require.NoError(t, f.ValidateTypes())
+		got := applyFilter(t, f, data)
+		require.Len(t, got, 2)
+		for _, msg := range got {
+			assert.IsType(t, &racestatev1.PublishStateRequest{}, msg)
+		}
+	})
+
+	t.Run("car", func(t *testing.T) {
+		f := &Filter{CarNum: "12"}
+		got := applyFilter(t, f, data)
+		require.Len(t, got, 5) // driver data and states
+		dd := got[0].(*racestatev1.PublishDriverDataRequest)
+		require.Len(t, dd.Entries, 1)
+		assert.Equal(t, "12", dd.Entries[0].Car.CarNumber)
+		s := got[1].(*racestatev1.PublishStateRequest)
+		require.Len(t, s.Cars, 1)
+		assert.Equal(t, int32(5), s.Cars[0].CarIdx)
+		assert.Len(t, s.Messages, 1)
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		f := &Filter{Types: []string{"unknown"}}
+		assert.Error(t, f.ValidateTypes())
+	})
+}
+
+func TestValidate(t *testing.T) {
+	data := writeLog(t)
+	tests := []struct {
+		name   string
+		modify func(b []byte) []byte
+		want   Verdict
+	}{
+		{"ok", func(b []byte) []byte { return b }, VerdictOK},
+		{"truncated", func(b []byte) []byte { return b[:len(b)-3] }, VerdictTruncated},
+		{
+			"corrupt",
+			func(b []byte) []byte {
+				idx := bytes.Index(b, []byte("Sebring"))
+				b[idx] = 'X'
+				return b
+			},
+			VerdictCorrupt,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.modify(bytes.Clone(data))
+			v := Validate(bytes.NewReader(b))
+			assert.Equal(t, tt.want, v.Verdict)
+		})
+	}
+}
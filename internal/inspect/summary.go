@@ -0,0 +1,147 @@
+package inspect
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+type (
+	Summary struct {
+		Format   byte
+		EventKey string
+		Track    string
+		// number of messages by message name
+		Counts map[string]int
+		Total  int
+		// time span of the state messages
+		First, Last time.Time
+		// session time span of the state messages
+		SessionTimeFrom, SessionTimeTo float64
+		// gaps in the state stream
+		Gaps    []Gap
+		Skipped int
+		// number of records whose message could not be decoded
+		Invalid int
+		// error which stopped reading the file (nil if the file was read completely)
+		Err error
+	}
+	// Gap between two consecutive state messages
+	Gap struct {
+		From, To                       time.Time
+		SessionTimeFrom, SessionTimeTo float64
+	}
+)
+
+// Summarize reads the msg log from r. Intervals between state messages longer
+// than gapThreshold are reported as gaps.
+func Summarize(r io.Reader, gapThreshold time.Duration) *Summary {
+	ret := &Summary{Counts: map[string]int{}}
+	reader := NewReader(r)
+	var lastState *racestatev1.PublishStateRequest
+	for {
+		rec, err := reader.Next()
+		if errors.Is(err, logger.ErrInvalidMessage) {
+			ret.Invalid++
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				ret.Err = err
+			}
+			break
+		}
+		ret.Total++
+		ret.Counts[string(rec.Msg.ProtoReflect().Descriptor().Name())]++
+		switch req := rec.Msg.(type) {
+		case *providerv1.RegisterEventRequest:
+			if ret.EventKey == "" {
+				ret.EventKey = req.GetEvent().GetKey()
+			}
+			if ret.Track == "" {
+				ret.Track = req.GetTrack().GetName()
+			}
+		case *racestatev1.PublishStateRequest:
+			ret.addState(lastState, req, gapThreshold)
+			lastState = req
+		}
+	}
+	m := reader.Logger()
+	ret.Format = m.Format()
+	ret.Skipped = m.Skipped()
+	if h := m.FileHeader(); h != nil && h.EventKey != "" {
+		ret.EventKey = h.EventKey
+	}
+	return ret
+}
+
+//nolint:whitespace // can't get different linters happy
+func (s *Summary) addState(
+	prev, cur *racestatev1.PublishStateRequest,
+	gapThreshold time.Duration,
+) {
+	ts := cur.GetTimestamp().AsTime()
+	st := float64(cur.GetSession().GetSessionTime())
+	if prev == nil {
+		s.First = ts
+		s.SessionTimeFrom = st
+	} else if ts.Sub(s.Last) > gapThreshold {
+		s.Gaps = append(s.Gaps, Gap{
+			From:            s.Last,
+			To:              ts,
+			SessionTimeFrom: s.SessionTimeTo,
+			SessionTimeTo:   st,
+		})
+	}
+	s.Last = ts
+	s.SessionTimeTo = st
+}
+
+// Print writes the summary in a human readable form
+func (s *Summary) Print(w io.Writer) {
+	fmt.Fprintf(w, "Format       : v%d\n", s.Format)
+	fmt.Fprintf(w, "Event key    : %s\n", s.EventKey)
+	fmt.Fprintf(w, "Track        : %s\n", s.Track)
+	fmt.Fprintf(w, "Messages     : %d\n", s.Total)
+	names := make([]string, 0, len(s.Counts))
+	for name := range s.Counts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-30s %d\n", name, s.Counts[name])
+	}
+	if !s.First.IsZero() {
+		fmt.Fprintf(w, "Time span    : %s - %s (%s)\n",
+			s.First.Format(time.RFC3339), s.Last.Format(time.RFC3339),
+			s.Last.Sub(s.First).Round(time.Second))
+		fmt.Fprintf(w, "Session time : %s - %s\n",
+			formatSessionTime(s.SessionTimeFrom), formatSessionTime(s.SessionTimeTo))
+	}
+	fmt.Fprintf(w, "State gaps   : %d\n", len(s.Gaps))
+	for _, g := range s.Gaps {
+		fmt.Fprintf(w, "  %s - %s (%s)\n",
+			formatSessionTime(g.SessionTimeFrom), formatSessionTime(g.SessionTimeTo),
+			g.To.Sub(g.From).Round(time.Second))
+	}
+	if s.Skipped > 0 {
+		fmt.Fprintf(w, "Skipped      : %d corrupted records\n", s.Skipped)
+	}
+	if s.Invalid > 0 {
+		fmt.Fprintf(w, "Invalid      : %d messages could not be decoded\n", s.Invalid)
+	}
+	if s.Err != nil {
+		fmt.Fprintf(w, "Error        : %v\n", s.Err)
+	}
+}
+
+func formatSessionTime(st float64) string {
+	return (time.Duration(st) * time.Second).String()
+}
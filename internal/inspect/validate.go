@@ -0,0 +1,89 @@
+package inspect
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+type (
+	Verdict string
+	// Validation is the result of Validate
+	Validation struct {
+		Verdict Verdict
+		Format  byte
+		// number of valid records
+		Records int
+		// number of corrupted records which were skipped (v2 only)
+		Skipped int
+		// number of records whose message could not be decoded
+		Invalid int
+		// the file ends within a record
+		Truncated bool
+		// error which stopped reading the file
+		Err error
+	}
+)
+
+const (
+	VerdictOK        Verdict = "OK"
+	VerdictCorrupt   Verdict = "CORRUPT"
+	VerdictTruncated Verdict = "TRUNCATED"
+	VerdictInvalid   Verdict = "INVALID"
+)
+
+// Validate reads the complete msg log and checks the records.
+// Files in v1 format have no checksums, so only truncated records and messages
+// which can't be decoded are detected.
+func Validate(r io.Reader) *Validation {
+	ret := &Validation{}
+	reader := NewReader(r)
+	for {
+		_, err := reader.Next()
+		if err == nil {
+			ret.Records++
+			continue
+		}
+		if errors.Is(err, logger.ErrInvalidMessage) {
+			ret.Invalid++
+			continue
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			ret.Truncated = true
+		} else if !errors.Is(err, io.EOF) {
+			ret.Err = err
+		}
+		break
+	}
+	ret.Format = reader.Logger().Format()
+	ret.Skipped = reader.Logger().Skipped()
+	switch {
+	case ret.Err != nil:
+		ret.Verdict = VerdictInvalid
+	case ret.Skipped > 0 || ret.Invalid > 0:
+		ret.Verdict = VerdictCorrupt
+	case ret.Truncated:
+		ret.Verdict = VerdictTruncated
+	default:
+		ret.Verdict = VerdictOK
+	}
+	return ret
+}
+
+// Print writes the validation result in a human readable form
+func (v *Validation) Print(w io.Writer) {
+	fmt.Fprintf(w, "Format    : v%d\n", v.Format)
+	fmt.Fprintf(w, "Records   : %d\n", v.Records)
+	fmt.Fprintf(w, "Corrupted : %d (skipped)\n", v.Skipped)
+	fmt.Fprintf(w, "Invalid   : %d (could not be decoded)\n", v.Invalid)
+	fmt.Fprintf(w, "Truncated : %t\n", v.Truncated)
+	if v.Err != nil {
+		fmt.Fprintf(w, "Error     : %v\n", v.Err)
+	}
+	if v.Format == logger.FormatV1 {
+		fmt.Fprintln(w, "Note      : v1 files have no checksums")
+	}
+	fmt.Fprintf(w, "Verdict   : %s\n", v.Verdict)
+}
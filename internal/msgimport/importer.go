@@ -0,0 +1,261 @@
+// Package msgimport sends messages read from a msg log file to the backend server.
+// It is used by the import command and the uploader of spooled recordings.
+package msgimport
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/mpapenbr/go-racelogger/internal/pacer"
+	"github.com/mpapenbr/go-racelogger/log"
+	"github.com/mpapenbr/go-racelogger/pkg/publisher"
+	"github.com/mpapenbr/go-racelogger/pkg/statedelta"
+)
+
+type (
+	// Target receives the imported messages (usually the DataProviderClient)
+	Target interface {
+		publisher.Publisher
+		DeleteEvent(eventKey string) error
+	}
+	Importer struct {
+		target        Target
+		recordingMode providerv1.RecordingMode
+		replaceData   bool
+		eventKey      string
+		decoder       *statedelta.Decoder
+		ctx           context.Context
+		pacer         *pacer.Pacer
+		from, to      float64
+		// session time of the last state message
+		sessionTime float64
+		// driver data received before the session time window
+		pendingDriverData *racestatev1.PublishDriverDataRequest
+	}
+	Option func(*Importer)
+)
+
+// WithRecordingMode overrides the recording mode of the register messages
+func WithRecordingMode(mode providerv1.RecordingMode) Option {
+	return func(i *Importer) { i.recordingMode = mode }
+}
+
+// WithReplaceData deletes existing data of the event before registering it
+func WithReplaceData(b bool) Option {
+	return func(i *Importer) { i.replaceData = b }
+}
+
+// WithEventKey sends the messages using this event key instead of the logged one
+func WithEventKey(key string) Option {
+	return func(i *Importer) { i.eventKey = key }
+}
+
+// WithContext sets the context used to cancel the pacing
+func WithContext(ctx context.Context) Option {
+	return func(i *Importer) { i.ctx = ctx }
+}
+
+// WithPace sends the messages paced by their timestamps.
+// A speed of 1 is real time, 2 double speed and 0 as fast as possible.
+func WithPace(speed float64) Option {
+	return func(i *Importer) { i.pacer = pacer.New(speed) }
+}
+
+// WithSessionTimeWindow sends only the data between from and to (session time).
+// A value of 0 means no limit. Register and unregister messages are always sent.
+// The last driver data before the window is sent when the window starts.
+func WithSessionTimeWindow(from, to time.Duration) Option {
+	return func(i *Importer) {
+		i.from = from.Seconds()
+		i.to = to.Seconds()
+	}
+}
+
+func NewImporter(target Target, opts ...Option) *Importer {
+	ret := &Importer{
+		target:        target,
+		recordingMode: providerv1.RecordingMode_RECORDING_MODE_PERSIST,
+		decoder:       statedelta.NewDecoder(),
+		ctx:           context.Background(),
+		pacer:         pacer.New(0),
+	}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	return ret
+}
+
+// Send sends a single message to the target. Unknown messages are ignored.
+// Delta encoded state messages are sent as complete messages. Deltas without
+// a previous keyframe are skipped (see Skip).
+//
+//nolint:cyclop,funlen // by design
+func (i *Importer) Send(msg protoreflect.Message) error {
+	switch req := msg.Interface().(type) {
+	case *providerv1.RegisterEventRequest:
+		if i.eventKey != "" {
+			req.Event.Key = i.eventKey
+		}
+		if i.replaceData {
+			if err := i.target.DeleteEvent(req.Event.Key); err != nil {
+				if status.Code(err) != codes.NotFound {
+					return err
+				}
+			}
+		}
+		i.sessionTime = 0
+		i.pendingDriverData = nil
+		_, err := i.target.RegisterProvider(req.Event, req.Track, i.recordingMode)
+		return err
+	case *providerv1.UnregisterEventRequest:
+		eventKey := req.EventSelector.GetKey()
+		if i.eventKey != "" {
+			eventKey = i.eventKey
+		}
+		return i.target.UnregisterProvider(eventKey)
+	case *racestatev1.PublishStateRequest:
+		full, err := i.decoder.Decode(req)
+		if errors.Is(err, statedelta.ErrMissingKeyframe) {
+			log.Debug("Skipping state delta without keyframe")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		i.sessionTime = float64(full.Session.GetSessionTime())
+		if !i.inWindow() {
+			return nil
+		}
+		if err := i.sendPendingDriverData(); err != nil {
+			return err
+		}
+		i.wait(full.Timestamp)
+		i.updateEventSelector(full.Event)
+		return i.target.PublishState(full)
+	case *racestatev1.PublishDriverDataRequest:
+		sessionTime := i.sessionTime
+		if req.SessionTime > 0 {
+			sessionTime = float64(req.SessionTime)
+		}
+		if !i.inWindowAt(sessionTime) {
+			if sessionTime < i.from {
+				i.pendingDriverData = req
+			}
+			return nil
+		}
+		i.pendingDriverData = nil
+		i.wait(req.Timestamp)
+		i.updateEventSelector(req.Event)
+		return i.target.PublishDriverData(req)
+	case *racestatev1.PublishSpeedmapRequest:
+		if !i.inWindow() {
+			return nil
+		}
+		i.wait(req.Timestamp)
+		i.updateEventSelector(req.Event)
+		return i.target.PublishSpeedmap(req)
+	case *racestatev1.PublishEventExtraInfoRequest:
+		// the extra info (e.g. pit lane) applies to the whole event
+		i.updateEventSelector(req.Event)
+		return i.target.PublishEventExtraInfo(req)
+	}
+	return nil
+}
+
+// Skip processes msg like Send without sending it. This is used when resuming
+// an import, so that the following delta encoded state messages can be decoded.
+func (i *Importer) Skip(msg protoreflect.Message) error {
+	target, p := i.target, i.pacer
+	i.target, i.pacer = discardTarget{}, pacer.New(0)
+	defer func() { i.target, i.pacer = target, p }()
+	return i.Send(msg)
+}
+
+// inWindow returns true if the session time of the last state message is
+// within the session time window. Messages without a session time of their own
+// belong to the last state message.
+func (i *Importer) inWindow() bool {
+	return i.inWindowAt(i.sessionTime)
+}
+
+func (i *Importer) inWindowAt(sessionTime float64) bool {
+	return sessionTime >= i.from && (i.to <= 0 || sessionTime <= i.to)
+}
+
+func (i *Importer) sendPendingDriverData() error {
+	if i.pendingDriverData == nil {
+		return nil
+	}
+	req := i.pendingDriverData
+	i.updateEventSelector(req.Event)
+	if err := i.target.PublishDriverData(req); err != nil {
+		return err
+	}
+	i.pendingDriverData = nil
+	return nil
+}
+
+// wait delays the message according to its timestamp (see WithPace)
+func (i *Importer) wait(ts *timestamppb.Timestamp) {
+	if ts == nil {
+		return
+	}
+	i.pacer.Wait(i.ctx, float64(ts.AsTime().UnixNano())/float64(time.Second))
+}
+
+func (i *Importer) updateEventSelector(sel *commonv1.EventSelector) {
+	if i.eventKey != "" {
+		sel.Arg = &commonv1.EventSelector_Key{Key: i.eventKey}
+	}
+}
+
+// discardTarget drops all messages (see Skip)
+type discardTarget struct{}
+
+//nolint:whitespace // can't get different linters happy
+func (discardTarget) RegisterProvider(
+	event *eventv1.Event,
+	track *trackv1.Track,
+	recordingMode providerv1.RecordingMode,
+) (*providerv1.RegisterEventResponse, error) {
+	return &providerv1.RegisterEventResponse{Event: event, Track: track}, nil
+}
+
+func (discardTarget) UnregisterProvider(eventKey string) error { return nil }
+func (discardTarget) DeleteEvent(eventKey string) error        { return nil }
+
+func (discardTarget) PublishState(req *racestatev1.PublishStateRequest) error {
+	return nil
+}
+
+//nolint:whitespace // can't get different linters happy
+func (discardTarget) PublishDriverData(
+	req *racestatev1.PublishDriverDataRequest,
+) error {
+	return nil
+}
+
+//nolint:whitespace // can't get different linters happy
+func (discardTarget) PublishSpeedmap(
+	req *racestatev1.PublishSpeedmapRequest,
+) error {
+	return nil
+}
+
+//nolint:whitespace // can't get different linters happy
+func (discardTarget) PublishEventExtraInfo(
+	req *racestatev1.PublishEventExtraInfoRequest,
+) error {
+	return nil
+}
@@ -0,0 +1,159 @@
+package msgimport
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/go-racelogger/pkg/publisher"
+)
+
+// recordingTarget records the received messages
+type recordingTarget struct {
+	publisher.Publisher
+	calls []string
+}
+
+//nolint:whitespace // can't get different linters happy
+func (r *recordingTarget) RegisterProvider(
+	event *eventv1.Event,
+	track *trackv1.Track,
+	recordingMode providerv1.RecordingMode,
+) (*providerv1.RegisterEventResponse, error) {
+	r.calls = append(r.calls, "register")
+	return &providerv1.RegisterEventResponse{}, nil
+}
+
+func (r *recordingTarget) UnregisterProvider(eventKey string) error {
+	r.calls = append(r.calls, "unregister")
+	return nil
+}
+
+func (r *recordingTarget) PublishState(req *racestatev1.PublishStateRequest) error {
+	r.calls = append(r.calls, fmt.Sprintf("state:%.0f", req.Session.SessionTime))
+	return nil
+}
+
+//nolint:whitespace // can't get different linters happy
+func (r *recordingTarget) PublishDriverData(
+	req *racestatev1.PublishDriverDataRequest,
+) error {
+	r.calls = append(r.calls, fmt.Sprintf("driverdata:%.0f", req.SessionTime))
+	return nil
+}
+
+//nolint:whitespace // can't get different linters happy
+func (r *recordingTarget) PublishSpeedmap(
+	req *racestatev1.PublishSpeedmapRequest,
+) error {
+	r.calls = append(r.calls, "speedmap")
+	return nil
+}
+
+func (r *recordingTarget) DeleteEvent(eventKey string) error {
+	return nil
+}
+
+func sessionMessages() []proto.Message {
+	ret := []proto.Message{
+		&providerv1.RegisterEventRequest{Event: &eventv1.Event{Key: "ev"}},
+	}
+	for st := float32(10); st <= 50; st += 10 {
+		// driver data is sent less frequently
+		if int(st)%20 == 10 {
+			ret = append(ret, &racestatev1.PublishDriverDataRequest{SessionTime: st})
+		}
+		ret = append(ret,
+			&racestatev1.PublishStateRequest{
+				Session: &racestatev1.Session{SessionTime: st},
+			},
+			&racestatev1.PublishSpeedmapRequest{},
+		)
+	}
+	return append(ret, &providerv1.UnregisterEventRequest{})
+}
+
+func TestImporter_SessionTimeWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to time.Duration
+		want     []string
+	}{
+		{
+			"complete", 0, 0,
+			[]string{
+				"register",
+				"driverdata:10", "state:10", "speedmap",
+				"state:20", "speedmap",
+				"driverdata:30", "state:30", "speedmap",
+				"state:40", "speedmap",
+				"driverdata:50", "state:50", "speedmap",
+				"unregister",
+			},
+		},
+		{
+			"window", 20 * time.Second, 30 * time.Second,
+			[]string{
+				"register",
+				// last driver data before the window
+				"driverdata:10", "state:20", "speedmap",
+				"driverdata:30", "state:30", "speedmap",
+				"unregister",
+			},
+		},
+		{
+			"from only", 40 * time.Second, 0,
+			[]string{
+				"register",
+				"driverdata:30", "state:40", "speedmap",
+				"driverdata:50", "state:50", "speedmap",
+				"unregister",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &recordingTarget{}
+			i := NewImporter(target, WithSessionTimeWindow(tt.from, tt.to))
+			for _, msg := range sessionMessages() {
+				require.NoError(t, i.Send(msg.ProtoReflect()))
+			}
+			assert.Equal(t, tt.want, target.calls)
+		})
+	}
+}
+
+func TestParsePace(t *testing.T) {
+	tests := []struct {
+		pace    string
+		want    float64
+		wantErr bool
+	}{
+		{"realtime", 1, false},
+		{"max", 0, false},
+		{"2x", 2, false},
+		{"0.5x", 0.5, false},
+		{"2", 0, true},
+		{"0x", 0, true},
+		{"fast", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pace, func(t *testing.T) {
+			got, err := ParsePace(tt.pace)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidPace)
+				return
+			}
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
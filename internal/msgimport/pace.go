@@ -0,0 +1,35 @@
+package msgimport
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	PaceRealtime = "realtime"
+	PaceMax      = "max"
+)
+
+var ErrInvalidPace = errors.New("invalid pace")
+
+// ParsePace converts a pace value (realtime, max or Nx, e.g. 2x) into a speed.
+// A speed of 1 is real time, 0 means as fast as possible.
+func ParsePace(s string) (float64, error) {
+	switch s {
+	case PaceRealtime:
+		return 1, nil
+	case PaceMax, "":
+		return 0, nil
+	}
+	factor, ok := strings.CutSuffix(s, "x")
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidPace, s)
+	}
+	speed, err := strconv.ParseFloat(factor, 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidPace, s)
+	}
+	return speed, nil
+}
@@ -0,0 +1,242 @@
+// Package msgtool rewrites msg log files by the event key of the messages
+// (see the logtool command).
+// The output contains complete state messages. Delta encoded states are decoded
+// unless logger.WithStateDelta is passed for the output.
+package msgtool
+
+import (
+	"errors"
+	"io"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/go-racelogger/internal/inspect"
+	"github.com/mpapenbr/go-racelogger/log"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+// Counts holds the number of records per event key
+type Counts map[string]int
+
+// EventKey returns the key of the EventSelector of msg.
+// The key of the event is used for register messages.
+// Returns "" if msg doesn't refer to an event by key.
+func EventKey(msg proto.Message) string {
+	switch req := msg.(type) {
+	case *providerv1.RegisterEventRequest:
+		if req.GetEvent().GetKey() != "" {
+			return req.GetEvent().GetKey()
+		}
+		return req.GetKey()
+	case *providerv1.UnregisterEventRequest:
+		return req.GetEventSelector().GetKey()
+	case *racestatev1.PublishStateRequest:
+		return req.GetEvent().GetKey()
+	case *racestatev1.PublishDriverDataRequest:
+		return req.GetEvent().GetKey()
+	case *racestatev1.PublishSpeedmapRequest:
+		return req.GetEvent().GetKey()
+	case *racestatev1.PublishEventExtraInfoRequest:
+		return req.GetEvent().GetKey()
+	}
+	return ""
+}
+
+// setEventKey changes the event key of msg
+func setEventKey(msg proto.Message, key string) {
+	sel := func() *commonv1.EventSelector {
+		return &commonv1.EventSelector{Arg: &commonv1.EventSelector_Key{Key: key}}
+	}
+	switch req := msg.(type) {
+	case *providerv1.RegisterEventRequest:
+		if req.Event != nil {
+			req.Event.Key = key
+		}
+		req.Key = key
+	case *providerv1.UnregisterEventRequest:
+		req.EventSelector = sel()
+	case *racestatev1.PublishStateRequest:
+		req.Event = sel()
+	case *racestatev1.PublishDriverDataRequest:
+		req.Event = sel()
+	case *racestatev1.PublishSpeedmapRequest:
+		req.Event = sel()
+	case *racestatev1.PublishEventExtraInfoRequest:
+		req.Event = sel()
+	}
+}
+
+// source reads the records of a msg log together with their event key.
+// Records without a key belong to the event registered last.
+type source struct {
+	r       *inspect.Reader
+	current string
+}
+
+func newSource(r io.Reader) *source {
+	return &source{r: inspect.NewReader(r)}
+}
+
+// next returns the next record and its event key.
+// Invalid messages are skipped, a truncated last record ends the data.
+// Returns io.EOF at the end of the data.
+func (s *source) next() (*inspect.Record, string, error) {
+	for {
+		rec, err := s.r.Next()
+		if errors.Is(err, logger.ErrInvalidMessage) {
+			log.Warn("skipping invalid message", log.ErrorField(err))
+			continue
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Warn("msg log ends within a record")
+			return nil, "", io.EOF
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		key := EventKey(rec.Msg)
+		if _, ok := rec.Msg.(*providerv1.RegisterEventRequest); ok {
+			s.current = key
+		}
+		if key == "" {
+			key = s.current
+		}
+		return rec, key, nil
+	}
+}
+
+func write(m *logger.MsgLogger, rec *inspect.Record) error {
+	return m.LogAt(rec.Msg.ProtoReflect(), rec.Timestamp)
+}
+
+// Split writes the records of each event to a separate msg log.
+// create is called for each event key when its first record is read.
+// The writers are closed when all records are written.
+//
+//nolint:whitespace // can't get different linters happy
+func Split(
+	r io.Reader,
+	create func(key string) (io.WriteCloser, error),
+	opts ...logger.Option,
+) (ret Counts, retErr error) {
+	ret = Counts{}
+	writers := map[string]*logger.MsgLogger{}
+	closers := []io.Closer{}
+	defer func() {
+		for _, c := range closers {
+			retErr = errors.Join(retErr, c.Close())
+		}
+	}()
+	src := newSource(r)
+	for {
+		rec, key, err := src.next()
+		if errors.Is(err, io.EOF) {
+			return ret, nil
+		}
+		if err != nil {
+			return ret, err
+		}
+		m, ok := writers[key]
+		if !ok {
+			w, err := create(key)
+			if err != nil {
+				return ret, err
+			}
+			closers = append(closers, w)
+			m = logger.NewMsgLogger(append(opts,
+				logger.WithWriter(w), logger.WithEventKey(key))...)
+			writers[key] = m
+		}
+		if err := write(m, rec); err != nil {
+			return ret, err
+		}
+		ret[key]++
+	}
+}
+
+// Merge writes the records of all inputs to w ordered by their capture time.
+// Records with the same capture time keep the order of the inputs.
+func Merge(w io.Writer, inputs []io.Reader, opts ...logger.Option) (Counts, error) {
+	type pending struct {
+		src *source
+		rec *inspect.Record
+		key string
+	}
+	ret := Counts{}
+	out := logger.NewMsgLogger(append(opts, logger.WithWriter(w))...)
+	heads := make([]*pending, 0, len(inputs))
+	advance := func(p *pending) (bool, error) {
+		rec, key, err := p.src.next()
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		p.rec, p.key = rec, key
+		return err == nil, err
+	}
+	for _, in := range inputs {
+		p := &pending{src: newSource(in)}
+		ok, err := advance(p)
+		if err != nil {
+			return ret, err
+		}
+		if ok {
+			heads = append(heads, p)
+		}
+	}
+	for len(heads) > 0 {
+		idx := 0
+		for i, p := range heads {
+			if p.rec.Timestamp.Before(heads[idx].rec.Timestamp) {
+				idx = i
+			}
+		}
+		p := heads[idx]
+		if err := write(out, p.rec); err != nil {
+			return ret, err
+		}
+		ret[p.key]++
+		ok, err := advance(p)
+		if err != nil {
+			return ret, err
+		}
+		if !ok {
+			heads = append(heads[:idx], heads[idx+1:]...)
+		}
+	}
+	return ret, nil
+}
+
+// Rekey copies the records of r to w and replaces the event key from by to.
+// If from is empty the records of all events get the new key.
+// Returns the number of changed records.
+//
+//nolint:whitespace // can't get different linters happy
+func Rekey(
+	r io.Reader,
+	w io.Writer,
+	from, to string,
+	opts ...logger.Option,
+) (int, error) {
+	changed := 0
+	out := logger.NewMsgLogger(append(opts, logger.WithWriter(w))...)
+	src := newSource(r)
+	for {
+		rec, key, err := src.next()
+		if errors.Is(err, io.EOF) {
+			return changed, nil
+		}
+		if err != nil {
+			return changed, err
+		}
+		if from == "" || key == from {
+			setEventKey(rec.Msg, to)
+			changed++
+		}
+		if err := write(out, rec); err != nil {
+			return changed, err
+		}
+	}
+}
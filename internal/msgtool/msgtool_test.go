@@ -0,0 +1,144 @@
+package msgtool
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/go-racelogger/internal/inspect"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+var start = time.Date(2024, 3, 17, 14, 0, 0, 0, time.UTC)
+
+func sel(key string) *commonv1.EventSelector {
+	return &commonv1.EventSelector{Arg: &commonv1.EventSelector_Key{Key: key}}
+}
+
+// eventMsgs returns the messages of an event with n state messages
+func eventMsgs(key string, n int) []proto.Message {
+	ret := []proto.Message{
+		&providerv1.RegisterEventRequest{Event: &eventv1.Event{Key: key}, Key: key},
+	}
+	for i := range n {
+		ret = append(ret, &racestatev1.PublishStateRequest{
+			Event:   sel(key),
+			Session: &racestatev1.Session{SessionTime: float32(i)},
+			Cars:    []*racestatev1.Car{{CarIdx: 1, Pos: int32(i)}},
+		})
+	}
+	return append(ret, &providerv1.UnregisterEventRequest{EventSelector: sel(key)})
+}
+
+// writeLog writes msgs with capture times starting at offset (one per second)
+//
+//nolint:whitespace // can't get different linters happy
+func writeLog(
+	t *testing.T,
+	offset time.Duration,
+	msgs []proto.Message,
+	opts ...logger.Option,
+) []byte {
+	t.Helper()
+	buf := bytes.Buffer{}
+	w := logger.NewMsgLogger(append(opts, logger.WithWriter(&buf))...)
+	for i, msg := range msgs {
+		ts := start.Add(offset + time.Duration(i)*time.Second)
+		require.NoError(t, w.LogAt(msg.ProtoReflect(), ts))
+	}
+	return buf.Bytes()
+}
+
+func readLog(t *testing.T, data []byte) []*inspect.Record {
+	t.Helper()
+	r := inspect.NewReader(bytes.NewReader(data))
+	ret := []*inspect.Record{}
+	for {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return ret
+		}
+		require.NoError(t, err)
+		ret = append(ret, rec)
+	}
+}
+
+type nopCloser struct{ *bytes.Buffer }
+
+func (nopCloser) Close() error { return nil }
+
+func TestSplit(t *testing.T) {
+	msgs := append(eventMsgs("a", 3), eventMsgs("b", 2)...)
+	data := writeLog(t, 0, msgs, logger.WithStateDelta(2))
+	out := map[string]*bytes.Buffer{}
+	counts, err := Split(bytes.NewReader(data), func(key string) (io.WriteCloser, error) {
+		out[key] = &bytes.Buffer{}
+		return nopCloser{out[key]}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Counts{"a": 5, "b": 4}, counts)
+	require.Len(t, out, 2)
+
+	recs := readLog(t, out["b"].Bytes())
+	require.Len(t, recs, 4)
+	for _, rec := range recs {
+		assert.Equal(t, "b", EventKey(rec.Msg))
+	}
+	// delta encoded states are written completely
+	state := recs[2].Msg.(*racestatev1.PublishStateRequest)
+	assert.Equal(t, int32(1), state.Cars[0].Pos)
+	assert.True(t, start.Add(6*time.Second).Equal(recs[1].Timestamp))
+
+	h, _, err := logger.ReadFileHeader(bytes.NewReader(out["b"].Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, "b", h.EventKey)
+}
+
+func TestMerge(t *testing.T) {
+	a := writeLog(t, 0, eventMsgs("a", 2))
+	// starts while a is running
+	b := writeLog(t, 1500*time.Millisecond, eventMsgs("b", 2))
+	buf := bytes.Buffer{}
+	counts, err := Merge(&buf, []io.Reader{bytes.NewReader(a), bytes.NewReader(b)})
+	require.NoError(t, err)
+	assert.Equal(t, Counts{"a": 4, "b": 4}, counts)
+
+	keys := ""
+	for _, rec := range readLog(t, buf.Bytes()) {
+		keys += EventKey(rec.Msg)
+	}
+	assert.Equal(t, "aabababb", keys)
+}
+
+func TestRekey(t *testing.T) {
+	msgs := append(eventMsgs("a", 1), eventMsgs("b", 1)...)
+	// driver data without event selector belongs to the last registered event
+	msgs = append(msgs[:4], append([]proto.Message{
+		&racestatev1.PublishDriverDataRequest{},
+	}, msgs[4:]...)...)
+	data := writeLog(t, 0, msgs)
+
+	buf := bytes.Buffer{}
+	changed, err := Rekey(bytes.NewReader(data), &buf, "b", "c")
+	require.NoError(t, err)
+	assert.Equal(t, 4, changed)
+
+	keys := ""
+	for _, rec := range readLog(t, buf.Bytes()) {
+		keys += EventKey(rec.Msg)
+	}
+	assert.Equal(t, "aaacccc", keys)
+	reg := readLog(t, buf.Bytes())[3].Msg.(*providerv1.RegisterEventRequest)
+	assert.Equal(t, "c", reg.Event.Key)
+	assert.Equal(t, "c", reg.Key)
+}
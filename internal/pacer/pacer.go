@@ -0,0 +1,49 @@
+// Package pacer delays the processing of data according to its timestamps
+package pacer
+
+import (
+	"context"
+	"time"
+)
+
+// Pacer delays the processing of data according to its timestamps (in seconds).
+// A speed of 1 replays in real time, 2 with double speed and so on.
+// A speed <= 0 disables pacing (as fast as possible)
+type Pacer struct {
+	speed     float64
+	start     time.Time
+	base      float64
+	last      float64
+	now       func() time.Time
+	sleepFunc func(ctx context.Context, d time.Duration)
+}
+
+func New(speed float64) *Pacer {
+	return &Pacer{speed: speed, now: time.Now, sleepFunc: sleepCtx}
+}
+
+// Wait blocks until the data for ts is due.
+// The reference is reset if ts goes backwards (for example a new session)
+func (p *Pacer) Wait(ctx context.Context, ts float64) {
+	if p.speed <= 0 {
+		return
+	}
+	if p.start.IsZero() || ts < p.last {
+		p.start = p.now()
+		p.base = ts
+	}
+	p.last = ts
+	offset := time.Duration((ts - p.base) / p.speed * float64(time.Second))
+	if d := p.start.Add(offset).Sub(p.now()); d > 0 {
+		p.sleepFunc(ctx, d)
+	}
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
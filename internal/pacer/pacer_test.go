@@ -0,0 +1,51 @@
+package pacer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacer_Wait(t *testing.T) {
+	tests := []struct {
+		name         string
+		speed        float64
+		sessionTimes []float64
+		want         []time.Duration
+	}{
+		{
+			"realtime", 1,
+			[]float64{10, 11, 12.5},
+			[]time.Duration{time.Second, 1500 * time.Millisecond},
+		},
+		{
+			"double speed", 2,
+			[]float64{10, 11, 12},
+			[]time.Duration{500 * time.Millisecond, 500 * time.Millisecond},
+		},
+		{"max speed", 0, []float64{10, 11, 12}, []time.Duration{}},
+		{
+			"session reset", 1,
+			[]float64{10, 11, 0, 1},
+			[]time.Duration{time.Second, time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			got := []time.Duration{}
+			p := New(tt.speed)
+			p.now = func() time.Time { return now }
+			p.sleepFunc = func(ctx context.Context, d time.Duration) {
+				got = append(got, d)
+				now = now.Add(d)
+			}
+			for _, st := range tt.sessionTimes {
+				p.Wait(context.Background(), st)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package processor
+
+import (
+	"slices"
+	"strings"
+)
+
+// a battle is reported once the cars were within the battle threshold for this
+// time (seconds). It ends if the cars were apart for this time.
+const battleHoldTime = 5
+
+// Battle is a group of consecutive cars of the same car class where each car
+// is within the battle threshold of the car in front.
+// Times are session times (seconds)
+type Battle struct {
+	ID            int      `json:"id"`
+	CarIdxs       []int32  `json:"carIdxs"` // current order
+	CarNums       []string `json:"carNums"`
+	Pos           int      `json:"pos"` // race position of the first car
+	StartTime     float64  `json:"startTime"`
+	StartLap      int      `json:"startLap"` // lap of the first car at the start
+	Laps          int      `json:"laps"`     // laps since the start
+	Duration      float64  `json:"duration"`
+	PositionSwaps int      `json:"positionSwaps"`
+
+	confirmed bool    // the battle was reported
+	lastSeen  float64 // session time the cars were within the threshold
+}
+
+// BattleListener is called with the current battles whenever the state is published
+type BattleListener func(battles []Battle)
+
+// detectBattles updates the battles by the intervals computed in calcDelta.
+// order is the race order by distance (lap + trackPos).
+func (p *CarProc) detectBattles(order []*CarData) {
+	if p.battleThreshold <= 0 {
+		return
+	}
+	now := p.currentTime
+	seen := map[*Battle]bool{}
+	for _, group := range p.battleGroups(order) {
+		first := group[0]
+		ids := make([]int32, len(group))
+		nums := make([]string, len(group))
+		for i, c := range group {
+			ids[i] = c.carIdx
+			nums[i] = p.carDriverProc.GetCurrentDriver(c.carIdx).CarNumber
+		}
+		b := p.matchBattle(ids, seen)
+		if b == nil {
+			p.nextBattleID++
+			b = &Battle{ID: p.nextBattleID, StartTime: now, StartLap: first.lap}
+			p.battles = append(p.battles, b)
+		} else {
+			b.PositionSwaps += countSwaps(b.CarIdxs, ids)
+		}
+		seen[b] = true
+		b.CarIdxs = ids
+		b.CarNums = nums
+		b.Pos = slices.Index(order, first) + 1
+		b.lastSeen = now
+		b.Duration = now - b.StartTime
+		b.Laps = first.lap - b.StartLap
+		if !b.confirmed && b.Duration >= battleHoldTime {
+			b.confirmed = true
+			p.messageProc.ReportBattleStart(b)
+		}
+	}
+
+	keep := p.battles[:0]
+	for _, b := range p.battles {
+		if !seen[b] && now-b.lastSeen >= battleHoldTime {
+			if b.confirmed {
+				p.messageProc.ReportBattleEnd(b)
+			}
+			continue
+		}
+		keep = append(keep, b)
+	}
+	p.battles = keep
+}
+
+// battleGroups returns the groups of consecutive running cars of the same
+// car class which are within the battle threshold
+func (p *CarProc) battleGroups(order []*CarData) [][]*CarData {
+	isRacing := func(c *CarData) bool {
+		return c.state == CarStateRun || c.state == CarStateSlow
+	}
+	classID := func(c *CarData) int {
+		return p.carDriverProc.GetCurrentDriver(c.carIdx).CarClassID
+	}
+	ret := [][]*CarData{}
+	var group []*CarData
+	for i, car := range order {
+		if i > 0 && isRacing(car) && isRacing(order[i-1]) &&
+			classID(car) == classID(order[i-1]) &&
+			car.interval > 0 && car.interval <= p.battleThreshold {
+
+			if len(group) == 0 {
+				group = []*CarData{order[i-1]}
+			}
+			group = append(group, car)
+			continue
+		}
+		if len(group) > 1 {
+			ret = append(ret, group)
+		}
+		group = nil
+	}
+	if len(group) > 1 {
+		ret = append(ret, group)
+	}
+	return ret
+}
+
+// matchBattle returns the battle which shares the most cars (at least two)
+// with ids. Battles in used are skipped.
+func (p *CarProc) matchBattle(ids []int32, used map[*Battle]bool) *Battle {
+	var ret *Battle
+	best := 1
+	for _, b := range p.battles {
+		if used[b] {
+			continue
+		}
+		shared := 0
+		for _, id := range ids {
+			if slices.Contains(b.CarIdxs, id) {
+				shared++
+			}
+		}
+		if shared > best {
+			ret, best = b, shared
+		}
+	}
+	return ret
+}
+
+// countSwaps returns the number of car pairs whose order changed from prev to cur.
+// Cars which are not in both lists are ignored.
+func countSwaps(prev, cur []int32) int {
+	ret := 0
+	for i, a := range cur {
+		for _, b := range cur[i+1:] {
+			ia, ib := slices.Index(prev, a), slices.Index(prev, b)
+			if ia >= 0 && ib >= 0 && ia > ib {
+				ret++
+			}
+		}
+	}
+	return ret
+}
+
+// Battles returns the current battles (once they were reported)
+func (p *CarProc) Battles() []Battle {
+	ret := make([]Battle, 0, len(p.battles))
+	for _, b := range p.battles {
+		if b.confirmed {
+			ret = append(ret, *b)
+		}
+	}
+	return ret
+}
+
+func (b *Battle) carList() string {
+	return "#" + strings.Join(b.CarNums, ", #")
+}
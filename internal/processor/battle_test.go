@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func battleMessages(t *testing.T, opts ...OptionsFunc) ([]string, *Processor) {
+	t.Helper()
+	opts = append([]OptionsFunc{WithMaxSpeed(500), WithBattleThreshold(1)}, opts...)
+	states, _, proc := runGoldenScenario(t, loadScenarioGenerator(t, "sprint"), opts...)
+	return messagesContaining(stateMessages(states), "Battle "), proc
+}
+
+func TestCarProc_Battles(t *testing.T) {
+	var battles [][]Battle
+	msgs, proc := battleMessages(t, WithBattleListener(func(b []Battle) {
+		battles = append(battles, b)
+	}))
+	// the field starts close together, #42 goes off track
+	assert.Equal(t, []string{
+		"Battle for P1: #7, #11, #23, #42, #99",
+		"Battle for P3: #23, #99",
+		"Battle for P1 ended after 172s (#7, #11, 3 laps, 1 position changes)",
+		"Battle for P2 ended after 211s (#23, #99, 5 laps, 0 position changes)",
+	}, msgs)
+	assert.Empty(t, proc.Battles())
+
+	// the listener receives the current battles with each state message
+	maxCars := 0
+	for _, b := range battles {
+		for _, battle := range b {
+			assert.Len(t, battle.CarNums, len(battle.CarIdxs))
+			maxCars = max(maxCars, len(battle.CarIdxs))
+		}
+	}
+	assert.Equal(t, 5, maxCars)
+}
+
+func TestCarProc_BattlesDisabled(t *testing.T) {
+	msgs, _ := battleMessages(t, WithBattleThreshold(0))
+	assert.Empty(t, msgs)
+}
+
+func TestCountSwaps(t *testing.T) {
+	assert.Equal(t, 0, countSwaps([]int32{1, 2, 3}, []int32{1, 2, 3}))
+	assert.Equal(t, 1, countSwaps([]int32{1, 2, 3}, []int32{2, 1, 3}))
+	assert.Equal(t, 3, countSwaps([]int32{1, 2, 3}, []int32{3, 2, 1}))
+	// new cars are ignored
+	assert.Equal(t, 1, countSwaps([]int32{1, 2}, []int32{4, 2, 1}))
+}
@@ -7,9 +7,9 @@ import (
 	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
 	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
 	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
-	"github.com/mpapenbr/goirsdk/irsdk"
 	"go.uber.org/zap"
 
+	"github.com/mpapenbr/go-racelogger/internal/telemetry"
 	"github.com/mpapenbr/go-racelogger/log"
 )
 
@@ -77,6 +77,7 @@ func (cr *carRun) UpdatePre(cd *CarData, cw *carWorkData) {
 		cd.state = CarStatePit
 		handleInlap(cd, cw)
 		cd.pitstops += 1
+		cd.startPitStop()
 		cd.setState(&carPit{})
 		return
 	}
@@ -122,6 +123,7 @@ func (cs *carSlow) UpdatePre(cd *CarData, cw *carWorkData) {
 		cd.state = CarStatePit
 		handleInlap(cd, cw)
 		cd.pitstops += 1
+		cd.startPitStop()
 		cd.setState(&carPit{})
 		return
 	}
@@ -148,6 +150,7 @@ func (cp *carPit) Exit(cd *CarData) {
 
 func (cp *carPit) UpdatePre(cd *CarData, cw *carWorkData) {
 	if cw.trackPos == -1 {
+		cd.pitStop = nil // lane time would include the time the car was out
 		cd.state = CarStateOut
 		cd.setState(&carOut{})
 		return
@@ -158,11 +161,12 @@ func (cp *carPit) UpdatePre(cd *CarData, cw *carWorkData) {
 		cd.state = CarStateRun
 		cd.stintLap = 1
 		cd.startOutLap = cw.sessionTime
+		cd.finishPitStop()
 		cd.setState(&carRun{})
 		return
 	}
 }
-func (cp *carPit) UpdatePost(cd *CarData) {}
+func (cp *carPit) UpdatePost(cd *CarData) { cd.updatePitStop() }
 
 type carFinished struct{}
 
@@ -229,6 +233,8 @@ type CarData struct {
 	speed           float64
 	interval        float64
 	gap             float64
+	classInterval   float64 // to the car ahead in the same car class
+	classGap        float64 // to the leader of the car class
 	tireCompound    int
 	currentState    carState
 	laptiming       *CarLaptiming
@@ -241,6 +247,12 @@ type CarData struct {
 	inlapTime       float64 // gets computed on pit entry
 	outlapTime      float64 // gets computed after pit exit on s/f
 	log             *log.Logger
+
+	sessionTime   float64    // session time of the current data
+	pitStop       *PitStop   // the current pit stop (nil if not in pit lane)
+	pitStops      []*PitStop // completed pit stops
+	reportPitStop ReportPitStop
+	movedBackward bool // set by CarProc.calcSpeed
 }
 
 //nolint:whitespace // can't get different linters happy
@@ -251,6 +263,7 @@ func NewCarData(
 	pitBoundaryProc *PitBoundaryProc,
 	gpd *GlobalProcessingData,
 	reportLapStatus ReportTimingStatus,
+	reportPitStop ReportPitStop,
 ) *CarData {
 	laptiming := NewCarLaptiming(len(gpd.TrackInfo.Sectors), reportLapStatus)
 	inlaptiming := NewCarLaptiming(len(gpd.TrackInfo.Sectors), nil)
@@ -262,6 +275,7 @@ func NewCarData(
 		pitBoundaryProc: pitBoundaryProc,
 		laptiming:       laptiming,
 		inlaptiming:     inlaptiming,
+		reportPitStop:   reportPitStop,
 		gpd:             gpd,
 		currentSector:   -1,
 		lastLap:         TimeWithMarker{time: -1, marker: ""},
@@ -277,7 +291,7 @@ func NewCarData(
 	return &ret
 }
 
-func (cd *CarData) PreProcess(api *irsdk.Irsdk) {
+func (cd *CarData) PreProcess(api TelemetrySource) {
 	cw := cd.extractIrsdkData(api)
 	cd.currentState.UpdatePre(cd, cw)
 }
@@ -384,6 +398,8 @@ func (cd *CarData) prepareMsgData() {
 	cd.msgData["dist"] = cd.dist
 	cd.msgData["interval"] = cd.interval
 	cd.msgData["gap"] = cd.gap
+	cd.msgData["classInterval"] = cd.classInterval
+	cd.msgData["classGap"] = cd.classGap
 	cd.msgData["last"] = []interface{}{
 		cd.laptiming.lap.duration.time,
 		cd.laptiming.lap.duration.marker,
@@ -428,7 +444,7 @@ func (cd *CarData) startLap(t float64) {
 	// otherwise we can't calculate the inlap time correctly
 	// for tracks where the pit is behind the s/f line
 	// for example: Interlagos, Mount Panorama
-	if cd.trackLoc == int32(irsdk.TrackLocationOnTrack) {
+	if cd.trackLoc == int32(telemetry.TrackLocationOnTrack) {
 		cd.inlaptiming.lap.markStart(t) // we may need this when car enters pit road
 	}
 }
@@ -456,7 +472,7 @@ func (cd *CarData) setStandingsLaptime(t float64) {
 }
 
 //nolint:lll,errcheck // by design
-func (cd *CarData) extractIrsdkData(api *irsdk.Irsdk) *carWorkData {
+func (cd *CarData) extractIrsdkData(api TelemetrySource) *carWorkData {
 	cw := carWorkData{}
 	cw.carIdx = cd.carIdx
 	cw.sessionTime = justValue(api.GetDoubleValue("SessionTime")).(float64)
@@ -484,6 +500,7 @@ func (cd *CarData) copyWorkData(cw *carWorkData) {
 	cd.pic = int(cw.pic)
 	cd.lap = int(cw.lap)
 	cd.lc = int(cw.lc)
+	cd.sessionTime = cw.sessionTime
 
 	cd.tireCompound = int(cw.tireCompound)
 	cd.dist = 0
@@ -9,7 +9,6 @@ import (
 	"sort"
 
 	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
-	"github.com/mpapenbr/goirsdk/irsdk"
 	"github.com/mpapenbr/goirsdk/yaml"
 	"github.com/samber/lo"
 	"golang.org/x/exp/slices"
@@ -22,7 +21,7 @@ import (
 // the data for single cars is processed in CarData
 type CarProc struct {
 	ctx context.Context
-	api *irsdk.Irsdk
+	api TelemetrySource
 	gpd *GlobalProcessingData
 
 	// minimum distance a car has to move to be considered valid
@@ -35,17 +34,31 @@ type CarProc struct {
 	prevLapPos           []int32        // data from previous iteration (CarIdxLap)
 	sessionNum           int32          // current session number
 	carLookup            map[int]*CarData
+	prevRaceDist         map[int32]float64 // lap + trackPos of running cars (overtakes)
 
 	lastStandingsIR []yaml.ResultsPositions
 
+	// lappings in progress (blue flag shown) and completed lappings
+	pendingLappings map[lappingKey]*Lapping
+	lappings        []*Lapping
+	lappingStats    map[int32]*LappingStats
+
+	battles      []*Battle // current battles (including unconfirmed ones)
+	nextBattleID int
+
 	carDriverProc   *CarDriverProc
 	pitBoundaryProc *PitBoundaryProc
 	speedmapProc    *SpeedmapProc
 	messageProc     *MessageProc
 	bestSectionProc *BestSectionProc
-
-	maxSpeed float64
-	log      *log.Logger
+	incidentProc    *IncidentProc
+	lapHistoryProc  *LapHistoryProc
+
+	maxSpeed        float64
+	overtakeFilter  OvertakeFilter
+	blueFlagWindow  float64 // seconds
+	battleThreshold float64 // seconds
+	log             *log.Logger
 }
 
 type finishMarker struct {
@@ -63,6 +76,8 @@ var baseAttributes = []string{
 	"lc",
 	"gap",
 	"interval",
+	"classGap",
+	"classInterval",
 	"trackPos",
 	"speed",
 	"dist",
@@ -99,14 +114,23 @@ func CarManifest(gpd *GlobalProcessingData) []string {
 //nolint:whitespace // can't get different linters happy
 func NewCarProc(
 	ctx context.Context,
-	api *irsdk.Irsdk,
+	api TelemetrySource,
 	gpd *GlobalProcessingData,
 	carDriverProc *CarDriverProc,
 	pitBoundaryProc *PitBoundaryProc,
 	speedmapProc *SpeedmapProc,
 	messageProc *MessageProc,
 	maxSpeed float64,
+	overtakeFilter OvertakeFilter,
+	incidentMessages bool,
+	blueFlagWindow float64,
+	battleThreshold float64,
 ) *CarProc {
+	// incidents are always recorded, the messages are optional
+	var reportIncident ReportIncident
+	if incidentMessages {
+		reportIncident = messageProc.ReportIncident
+	}
 	ret := &CarProc{
 		ctx:             ctx,
 		api:             api,
@@ -115,7 +139,12 @@ func NewCarProc(
 		pitBoundaryProc: pitBoundaryProc,
 		speedmapProc:    speedmapProc,
 		messageProc:     messageProc,
+		incidentProc:    NewIncidentProc(reportIncident),
+		lapHistoryProc:  NewLapHistoryProc(carDriverProc),
 		maxSpeed:        maxSpeed,
+		overtakeFilter:  overtakeFilter,
+		blueFlagWindow:  blueFlagWindow,
+		battleThreshold: battleThreshold,
 		log:             log.GetFromContext(ctx).Named("CarProc"),
 	}
 
@@ -136,6 +165,7 @@ func (p *CarProc) init() {
 	// car must move 10cm to be considered valid
 	p.minMoveDistPct = 0.1 / float64(p.gpd.TrackInfo.Length)
 	p.carLookup = make(map[int]*CarData)
+	p.lappingStats = make(map[int32]*LappingStats)
 
 	p.bestSectionProc = NewBestSectionProc(len(p.gpd.TrackInfo.Sectors),
 		collectInts(p.carDriverProc.byCarClassIDLookup),
@@ -172,7 +202,8 @@ func (p *CarProc) newCarData(carIdx int) *CarData {
 		p.carDriverProc,
 		p.pitBoundaryProc,
 		p.gpd,
-		reportLapStatus)
+		reportLapStatus,
+		p.messageProc.ReportPitStop)
 }
 
 // will be called every tick, we can assume to have valid data (no unexpected -1 values)
@@ -217,14 +248,23 @@ func (p *CarProc) Process() {
 				}
 			}
 			p.computeTimes(carData)
+			p.incidentProc.Process(carData)
 		}
 	}
 	// at this point all cars have been processed
+	raceOrder := p.getInCurrentRaceOrder()
 
 	y := p.api.GetLatestYaml()
 
 	if y.SessionInfo.Sessions[sessionNum].SessionType == "Race" {
-		p.calcDelta()
+		p.calcDelta(raceOrder)
+		p.calcClassDelta(raceOrder)
+		p.detectOvertakes(raceOrder)
+		p.detectLapping(raceOrder)
+		p.detectBattles(raceOrder)
+		for _, idx := range processableCars {
+			p.lapHistoryProc.Process(p.carLookup[idx])
+		}
 	}
 
 	curStandingsIR := y.SessionInfo.Sessions[sessionNum].ResultsPositions
@@ -239,8 +279,9 @@ func (p *CarProc) Process() {
 	for _, c := range processableCars {
 		p.carLookup[c].PostProcess()
 	}
-	if len(p.getInCurrentRaceOrder()) > 0 {
-		p.speedmapProc.SetLeaderTrackPos(p.getInCurrentRaceOrder()[0].trackPos)
+	// the standings may have changed the race order after the winner crossed the line
+	if order := p.getInCurrentRaceOrder(); len(order) > 0 {
+		p.speedmapProc.SetLeaderTrackPos(order[0].trackPos)
 	}
 	// copy data for next iteration
 	p.prevSessionTime = currentTime
@@ -350,6 +391,7 @@ func (p *CarProc) calcSpeed(carData *CarData) float64 {
 		return fmt.Sprintf("%.4f", f)
 	}
 	// carData has already received current trackPos
+	carData.movedBackward = false
 	if len(p.prevLapDistPct) == 0 {
 		return -1
 	}
@@ -363,6 +405,7 @@ func (p *CarProc) calcSpeed(carData *CarData) float64 {
 	moveDist := currentTrackPos - prevTrackPos
 	// issue warning if car moved backward more than minMoveDistPct
 	if moveDist < 0 && math.Abs(moveDist) > p.minMoveDistPct {
+		carData.movedBackward = true
 		carData.log.Warn(
 			"Car moved backward???",
 			// log.String("carNum", p.carDriverProc.GetCurrentDriver(carData.carIdx).CarNumber),
@@ -403,8 +446,9 @@ func (p *CarProc) calcSpeed(carData *CarData) float64 {
 	// compute speed
 }
 
-func (p *CarProc) calcDelta() {
-	currentRaceOrder := p.getInCurrentRaceOrder()
+// calcDelta computes the gap and interval to the car ahead.
+// currentRaceOrder is the race order by distance (lap + trackPos).
+func (p *CarProc) calcDelta(currentRaceOrder []*CarData) {
 	if len(currentRaceOrder) == 0 {
 		return
 	}
@@ -658,6 +702,37 @@ func (p *CarProc) CreatePayloadWamp() [][]interface{} {
 	return payload
 }
 
+// LapChart returns the positions of the cars at each lap completion
+func (p *CarProc) LapChart() []LapChartEntry {
+	return p.lapHistoryProc.LapChart()
+}
+
+// NewLaps returns the lap completions recorded since the last call
+func (p *CarProc) NewLaps() []LapChartEntry {
+	return p.lapHistoryProc.NewLaps()
+}
+
+// Incidents returns the incidents by carIdx
+func (p *CarProc) Incidents() map[int32][]Incident {
+	return p.incidentProc.Incidents()
+}
+
+// IncidentCounts returns the number of incidents of each kind by carIdx
+func (p *CarProc) IncidentCounts() map[int32]map[IncidentKind]int {
+	return p.incidentProc.Counts()
+}
+
+// PitStops returns the completed pit stops by carIdx
+func (p *CarProc) PitStops() map[int32][]PitStop {
+	ret := make(map[int32][]PitStop, len(p.carLookup))
+	for idx, c := range p.carLookup {
+		if len(c.pitStops) > 0 {
+			ret[int32(idx)] = c.PitStops()
+		}
+	}
+	return ret
+}
+
 func (p *CarProc) CreatePayload() []*racestatev1.Car {
 	cars := p.getInCurrentRaceOrder()
 	payload := make([]*racestatev1.Car, len(cars))
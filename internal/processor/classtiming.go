@@ -0,0 +1,93 @@
+package processor
+
+import "math"
+
+// Timing contains the overall and class based gaps and intervals of a car.
+// All values are seconds.
+type Timing struct {
+	CarIdx     int32   `json:"carIdx"`
+	CarNum     string  `json:"carNum"`
+	CarClassID int     `json:"carClassId"`
+	Pos        int     `json:"pos"`
+	Pic        int     `json:"pic"`
+	State      string  `json:"state"`
+	Gap        float64 `json:"gap"`      // to the overall leader
+	Interval   float64 `json:"interval"` // to the car ahead overall
+	// to the leader of the car class
+	ClassGap float64 `json:"classGap"`
+	// to the car ahead in the same car class
+	ClassInterval float64 `json:"classInterval"`
+}
+
+// TimingListener is called with the timing of all cars (in race order)
+// whenever the state is published
+type TimingListener func(timing []Timing)
+
+// calcClassDelta computes the gap to the class leader and the interval to the
+// car ahead in the same car class. The speedmap of the car class is used,
+// whole laps between the cars are added by the lap time of that speedmap.
+// If a value can't be computed the class gap keeps its last valid value.
+// order is the race order by distance (lap + trackPos).
+func (p *CarProc) calcClassDelta(order []*CarData) {
+	leader := map[int]*CarData{}
+	ahead := map[int]*CarData{}
+	raceDist := func(c *CarData) float64 { return float64(c.lap) + c.trackPos }
+	for _, car := range order {
+		if car.pos < 0 || car.state == CarStateOut {
+			continue
+		}
+		classID := p.carDriverProc.GetCurrentDriver(car.carIdx).CarClassID
+		carInFront, ok := ahead[classID]
+		ahead[classID] = car
+		if !ok {
+			leader[classID] = car
+			car.classGap = 0
+			car.classInterval = 0
+			continue
+		}
+		switch {
+		case car.state == CarStateFinish:
+			car.classInterval = car.gap - carInFront.gap
+			car.classGap = car.gap - leader[classID].gap
+			continue
+		case car.speed <= 0:
+			// like the overall interval. The class gap keeps its last valid value.
+			car.classInterval = 999
+			continue
+		}
+		delta := p.speedmapProc.ComputeDeltaTime(
+			classID, carInFront.trackPos, car.trackPos)
+		laps := math.Floor(raceDist(carInFront) - raceDist(car))
+		if laps > 0 {
+			laptime := p.speedmapProc.ClassLaptime(classID)
+			if laptime <= 0 {
+				continue // no class lap time yet, keep the last valid values
+			}
+			delta += laps * laptime
+		}
+		car.classInterval = delta
+		car.classGap = carInFront.classGap + car.classInterval
+	}
+}
+
+// Timing returns the overall and class based gaps and intervals in race order
+func (p *CarProc) Timing() []Timing {
+	cars := p.getInCurrentRaceOrder()
+	ret := make([]Timing, 0, len(cars))
+	for _, c := range cars {
+		driver := p.carDriverProc.GetCurrentDriver(c.carIdx)
+		ret = append(ret, Timing{
+			CarIdx:        c.carIdx,
+			CarNum:        driver.CarNumber,
+			CarClassID:    driver.CarClassID,
+			Pos:           c.pos,
+			Pic:           c.pic,
+			State:         c.state,
+			Gap:           c.gap,
+			Interval:      c.interval,
+			ClassGap:      c.classGap,
+			ClassInterval: c.classInterval,
+		})
+	}
+	return ret
+}
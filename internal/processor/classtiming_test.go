@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/mpapenbr/goirsdk/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassTiming(t *testing.T) {
+	g := loadScenarioGenerator(t, "multiclass")
+	var published [][]Timing
+	runGoldenScenario(t, g, WithMaxSpeed(500),
+		WithTimingListener(func(timing []Timing) {
+			published = append(published, timing)
+		}))
+	require.NotEmpty(t, published)
+
+	isRacing := func(c Timing) bool {
+		return c.State == CarStateRun || c.State == CarStateSlow
+	}
+	otherClassAhead := 0
+	hadInterval := map[int32]bool{}
+	for _, timing := range published {
+		leaders := map[int]Timing{}
+		ahead := map[int]Timing{}
+		for i, c := range timing {
+			if c.Pos < 0 || c.State == CarStateOut {
+				continue
+			}
+			cif, ok := ahead[c.CarClassID]
+			ahead[c.CarClassID] = c
+			if !ok {
+				leaders[c.CarClassID] = c
+				assert.Zero(t, c.ClassGap, "car %s", c.CarNum)
+				assert.Zero(t, c.ClassInterval, "car %s", c.CarNum)
+				hadInterval[c.CarIdx] = false
+				continue
+			}
+			// the interval of a stopped car must not end up in the class gap
+			assert.Less(t, c.ClassGap, 999.0, "car %s", c.CarNum)
+			// the last valid values are kept if they can't be computed
+			if hadInterval[c.CarIdx] && isRacing(c) {
+				assert.NotZero(t, c.ClassInterval, "car %s", c.CarNum)
+			}
+			hadInterval[c.CarIdx] = c.ClassInterval > 0
+			if !isRacing(c) || !isRacing(cif) ||
+				c.ClassInterval <= 0 || c.ClassInterval >= 999 {
+
+				continue // speedmap not ready or car stopped
+			}
+			assert.InDelta(t, cif.ClassGap+c.ClassInterval, c.ClassGap, 0.01,
+				"car %s", c.CarNum)
+			if timing[i-1].CarClassID != c.CarClassID && c.Interval > 0 {
+				otherClassAhead++
+				assert.Greater(t, c.ClassInterval, c.Interval, "car %s", c.CarNum)
+			}
+		}
+		if len(leaders) > 0 {
+			assert.Len(t, leaders, 2)
+		}
+	}
+	assert.Positive(t, otherClassAhead)
+}
+
+func TestCalcClassDelta_KeepLastValid(t *testing.T) {
+	p := &CarProc{
+		carDriverProc: &CarDriverProc{lookup: map[int32]yaml.Drivers{
+			0: {CarClassID: 2},
+			1: {CarClassID: 2},
+		}},
+		speedmapProc: defaultTestSpeedmapProc(), // no speedmap for class 2 yet
+	}
+	leader := &CarData{carIdx: 0, state: CarStateRun}
+	leader.copyWorkData(&carWorkData{carIdx: 0, pos: 1, lap: 3, trackPos: 0.5})
+	car := &CarData{carIdx: 1, state: CarStateRun, classGap: 40, classInterval: 40}
+	car.copyWorkData(&carWorkData{carIdx: 1, pos: 2, lap: 1, trackPos: 0.4})
+	car.speed = 100
+
+	p.calcClassDelta([]*CarData{leader, car})
+	assert.InDelta(t, 40.0, car.classGap, 0.001)
+	assert.InDelta(t, 40.0, car.classInterval, 0.001)
+
+	car.speed = 0
+	p.calcClassDelta([]*CarData{leader, car})
+	assert.InDelta(t, 40.0, car.classGap, 0.001)
+	assert.InDelta(t, 999.0, car.classInterval, 0.001)
+}
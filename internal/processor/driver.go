@@ -8,14 +8,15 @@ import (
 	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
 	driverv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/driver/v1"
 	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
-	"github.com/mpapenbr/goirsdk/irsdk"
 	"github.com/mpapenbr/goirsdk/yaml"
 	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/mpapenbr/go-racelogger/internal/clock"
 )
 
 // CarDriverProc is the main processor for managing driver and team data
 type CarDriverProc struct {
-	api *irsdk.Irsdk
+	api TelemetrySource
 	// maps carIdx to current driver of the car
 	lookup             map[int32]yaml.Drivers
 	byCarIDLookup      map[int32][]yaml.Drivers
@@ -28,26 +29,29 @@ type CarDriverProc struct {
 	output            chan *racestatev1.PublishDriverDataRequest
 	reportChangeFunc  func(carIdx int)
 	gpd               *GlobalProcessingData
+	clock             clock.Clock
 }
 
 //nolint:whitespace // can't get different linters happy
 func NewCarDriverProc(
-	api *irsdk.Irsdk,
+	api TelemetrySource,
 	output chan *racestatev1.PublishDriverDataRequest,
 	gpd *GlobalProcessingData,
+	clk clock.Clock,
 ) *CarDriverProc {
-	return newCarDriverProcInternal(api, output, gpd)
+	return newCarDriverProcInternal(api, output, gpd, clk)
 }
 
 // use this for testing with custom yaml content
 //
 //nolint:whitespace // can't get different linters happy
 func newCarDriverProcInternal(
-	api *irsdk.Irsdk,
+	api TelemetrySource,
 	output chan *racestatev1.PublishDriverDataRequest,
 	gpd *GlobalProcessingData,
+	clk clock.Clock,
 ) *CarDriverProc {
-	ret := CarDriverProc{api: api, output: output, gpd: gpd}
+	ret := CarDriverProc{api: api, output: output, gpd: gpd, clock: clk}
 	ret.init(api.GetLatestYaml())
 	return &ret
 }
@@ -101,6 +105,12 @@ func (d *CarDriverProc) GetCurrentDriver(carIdx int32) yaml.Drivers {
 	return d.lookup[carIdx]
 }
 
+// GetLatestDriverName returns the name of the driver currently driving the car.
+// (GetCurrentDriver returns the driver data of the first driver of the car)
+func (d *CarDriverProc) GetLatestDriverName(carIdx int32) string {
+	return d.latestDriverNames[carIdx]
+}
+
 // gets called when main processor detects new driver data
 //
 //nolint:funlen,gocritic,errcheck// keep things together and simple
@@ -177,7 +187,7 @@ func (d *CarDriverProc) Process(y *yaml.IrsdkYaml) {
 				Key: d.gpd.EventDataInfo.Key,
 			},
 		},
-		Timestamp: timestamppb.Now(),
+		Timestamp: timestamppb.New(d.clock.Now()),
 
 		Cars:           collectCars(y.DriverInfo.Drivers),
 		CarClasses:     collectCarClasses(y.DriverInfo.Drivers),
@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"strconv"
+	"time"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
+	"github.com/mpapenbr/goirsdk/yaml"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/mpapenbr/go-racelogger/version"
+)
+
+// CreateEventInfo creates the event information from the irsdk yaml data.
+// Name, description and key have to be set by the caller.
+func CreateEventInfo(irYaml *yaml.IrsdkYaml, eventTime time.Time) *eventv1.Event {
+	pitSpeed, _ := GetMetricUnit(irYaml.WeekendInfo.TrackPitSpeedLimit)
+	event := eventv1.Event{
+		TrackId:           uint32(irYaml.WeekendInfo.TrackID),
+		MultiClass:        irYaml.WeekendInfo.NumCarClasses > 1,
+		NumCarTypes:       uint32(irYaml.WeekendInfo.NumCarTypes),
+		TeamRacing:        irYaml.WeekendInfo.TeamRacing > 0,
+		IrSessionId:       int32(irYaml.WeekendInfo.SessionID),
+		IrSubSessionId:    int32(irYaml.WeekendInfo.SubSessionID),
+		RaceloggerVersion: version.Version,
+		EventTime:         timestamppb.New(eventTime),
+		Sessions:          convertSessions(irYaml.SessionInfo.Sessions),
+		NumCarClasses:     uint32(irYaml.WeekendInfo.NumCarClasses),
+		PitSpeed:          float32(pitSpeed),
+		TireInfos:         convertTireInfos(irYaml.DriverInfo.DriverTires),
+	}
+	return &event
+}
+
+// CreateTrackInfo creates the track information from the irsdk yaml data.
+func CreateTrackInfo(irYaml *yaml.IrsdkYaml) *trackv1.Track {
+	trackLength, _ := GetTrackLengthInMeters(irYaml.WeekendInfo.TrackLength)
+	pitSpeed, _ := GetMetricUnit(irYaml.WeekendInfo.TrackPitSpeedLimit)
+	ret := trackv1.Track{
+		Id:        uint32(irYaml.WeekendInfo.TrackID),
+		Name:      irYaml.WeekendInfo.TrackDisplayName,
+		ShortName: irYaml.WeekendInfo.TrackDisplayShortName,
+		Config:    irYaml.WeekendInfo.TrackConfigName,
+		Length:    float32(trackLength),
+		PitSpeed:  float32(pitSpeed),
+
+		Sectors: convertSectors(irYaml.SplitTimeInfo.Sectors),
+	}
+	return &ret
+}
+
+func convertSectors(sectors []yaml.Sectors) []*trackv1.Sector {
+	ret := make([]*trackv1.Sector, len(sectors))
+	for i, v := range sectors {
+		ret[i] = &trackv1.Sector{
+			Num:      uint32(v.SectorNum),
+			StartPct: float32(v.SectorStartPct),
+		}
+	}
+	return ret
+}
+
+//nolint:gocritic // by design
+func convertSessions(sessions []yaml.Sessions) []*eventv1.Session {
+	ret := make([]*eventv1.Session, len(sessions))
+	for i, v := range sessions {
+		time := 0.0
+		if v.SessionTime != "unlimited" {
+			// value is "xxx.0000 sec", so we can use our conversion function
+			// (even though it is not a metric depending value)
+			time, _ = GetMetricUnit(v.SessionTime)
+		}
+
+		laps := 0
+		if v.SessionLaps != "unlimited" {
+			laps, _ = strconv.Atoi(v.SessionLaps)
+		}
+
+		ret[i] = &eventv1.Session{
+			Num:         uint32(v.SessionNum),
+			Name:        v.SessionName,
+			SessionTime: int32(time),
+			Laps:        int32(laps), //nolint:gosec // by design
+			Type:        convertSessionType(v.SessionType),
+			SubType:     convertSessionSubType(v.SessionSubType),
+		}
+	}
+	return ret
+}
+
+func convertTireInfos(tires []yaml.DriverTires) []*eventv1.TireInfo {
+	ret := make([]*eventv1.TireInfo, len(tires))
+	for i, v := range tires {
+		ret[i] = &eventv1.TireInfo{
+			Index:        uint32(v.TireIndex),
+			CompoundType: v.TireCompoundType,
+		}
+	}
+	return ret
+}
+
+func convertSessionType(apiValue string) commonv1.SessionType {
+	switch apiValue {
+	case "Practice":
+		return commonv1.SessionType_SESSION_TYPE_PRACTICE
+	case "Open Qualify":
+		return commonv1.SessionType_SESSION_TYPE_OPEN_QUALIFY
+	case "Lone Qualify":
+		return commonv1.SessionType_SESSION_TYPE_LONE_QUALIFY
+	case "Warmup":
+		return commonv1.SessionType_SESSION_TYPE_WARMUP
+	case "Race":
+		return commonv1.SessionType_SESSION_TYPE_RACE
+	default:
+		return commonv1.SessionType_SESSION_TYPE_UNSPECIFIED
+	}
+}
+
+func convertSessionSubType(apiValue string) commonv1.SessionSubType {
+	switch apiValue {
+	case "Heat":
+		return commonv1.SessionSubType_SESSION_SUB_TYPE_HEAT
+	case "Consolation":
+		return commonv1.SessionSubType_SESSION_SUB_TYPE_CONSOLATION
+	case "Feature":
+		return commonv1.SessionSubType_SESSION_SUB_TYPE_FEATURE
+	default:
+		return commonv1.SessionSubType_SESSION_SUB_TYPE_UNSPECIFIED
+	}
+}
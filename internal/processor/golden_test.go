@@ -0,0 +1,243 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/mpapenbr/go-racelogger/internal/clock"
+	"github.com/mpapenbr/go-racelogger/internal/racegen"
+	"github.com/mpapenbr/go-racelogger/log"
+)
+
+var (
+	update          = flag.Bool("update", false, "update golden files")
+	goldenStartTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+)
+
+// TestProcessor_Golden runs the processor on synthetic races
+// (testdata/scenarios) and compares the published state messages with the
+// golden files in testdata/golden.
+// Use -update to recreate the golden files.
+func TestProcessor_Golden(t *testing.T) {
+	files, err := filepath.Glob("testdata/scenarios/*.yml")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	for _, fn := range files {
+		name := strings.TrimSuffix(filepath.Base(fn), ".yml")
+		t.Run(name, func(t *testing.T) {
+			g := loadScenarioGenerator(t, name)
+			states, finishOrder, _ := runGoldenScenario(t, g, WithMaxSpeed(500))
+			assert.Equal(t, g.FinishOrder(), finishOrder, "finish order")
+
+			compareGolden(t, filepath.Join("testdata", "golden", name+".json"),
+				states)
+		})
+	}
+}
+
+// loadScenarioGenerator returns a generator for testdata/scenarios/<name>.yml
+func loadScenarioGenerator(t *testing.T, name string) *racegen.Generator {
+	t.Helper()
+	sc, err := racegen.LoadScenario(filepath.Join("testdata", "scenarios", name+".yml"))
+	require.NoError(t, err)
+	g, err := racegen.NewGenerator(sc)
+	require.NoError(t, err)
+	return g
+}
+
+// stateMessages returns the messages of all states
+func stateMessages(states []*racestatev1.PublishStateRequest) []*racestatev1.Message {
+	ret := []*racestatev1.Message{}
+	for _, s := range states {
+		ret = append(ret, s.Messages...)
+	}
+	return ret
+}
+
+// messagesContaining returns the texts of the messages containing substr
+func messagesContaining(msgs []*racestatev1.Message, substr string) []string {
+	ret := []string{}
+	for _, m := range msgs {
+		if strings.Contains(m.Msg, substr) {
+			ret = append(ret, m.Msg)
+		}
+	}
+	return ret
+}
+
+// runGoldenScenario returns the published state messages, the order in which
+// the processor detected the cars finishing the race and the processor.
+// opts are passed to the processor in addition to the default test options.
+// The processor uses a clock driven by the SessionTime of the generator.
+//
+//nolint:funlen // test setup
+func runGoldenScenario(
+	t *testing.T,
+	g *racegen.Generator,
+	opts ...OptionsFunc,
+) (states []*racestatev1.PublishStateRequest, finishOrder []int, proc *Processor) {
+	t.Helper()
+	src := g.Source()
+	ctx := log.AddToContext(context.Background(), log.New(io.Discard, log.ErrorLevel))
+	clk := clock.NewSessionClock(goldenStartTime)
+	clk.Update(g.SessionTime())
+
+	stateChannel := make(chan *racestatev1.PublishStateRequest, 10)
+	speedmapChannel := make(chan *racestatev1.PublishSpeedmapRequest, 10)
+	carDataChannel := make(chan *racestatev1.PublishDriverDataRequest, 10)
+	extraInfoChannel := make(chan *racestatev1.PublishEventExtraInfoRequest, 10)
+	recordingDoneChannel := make(chan struct{})
+	collect := func() {
+		for {
+			select {
+			case msg := <-stateChannel:
+				states = append(states, msg)
+			case <-speedmapChannel:
+			case <-carDataChannel:
+			case <-extraInfoChannel:
+			default:
+				return
+			}
+		}
+	}
+	y := src.GetLatestYaml()
+	opts = append([]OptionsFunc{
+		WithGlobalProcessingData(&GlobalProcessingData{
+			TrackInfo:     CreateTrackInfo(y),
+			EventDataInfo: CreateEventInfo(y, goldenStartTime),
+		}),
+		WithContext(ctx),
+		WithChunkSize(10),
+		WithClock(clk),
+		WithRecordingDoneChannel(recordingDoneChannel),
+	}, opts...)
+	proc = NewProcessor(src,
+		stateChannel, speedmapChannel, carDataChannel, extraInfoChannel, opts...)
+
+	finished := map[int]bool{}
+	var err error
+	for ok := true; ok; ok, err = g.Step() {
+		require.NoError(t, err)
+		clk.Update(g.SessionTime())
+		proc.Process()
+		collect()
+
+		newFinished := []int{}
+		for idx, cd := range proc.carProc.carLookup {
+			if cd.state == CarStateFinish && !finished[idx] {
+				finished[idx] = true
+				newFinished = append(newFinished, idx)
+			}
+		}
+		slices.Sort(newFinished)
+		finishOrder = append(finishOrder, newFinished...)
+	}
+	select {
+	case <-recordingDoneChannel:
+	default:
+		t.Error("recording not done at end of scenario")
+	}
+	for _, s := range states {
+		roundFloats(s.ProtoReflect())
+	}
+	return states, finishOrder, proc
+}
+
+// roundFloats rounds all float values to 3 decimals.
+// This way the golden files do not depend on platform specific float arithmetic
+func roundFloats(m protoreflect.Message) {
+	round := func(v float64) float64 { return math.Round(v*1000) / 1000 }
+	roundValue := func(fd protoreflect.FieldDescriptor, v protoreflect.Value) (
+		protoreflect.Value, bool,
+	) {
+		//nolint:exhaustive // only float kinds are relevant
+		switch fd.Kind() {
+		case protoreflect.FloatKind:
+			return protoreflect.ValueOfFloat32(float32(round(v.Float()))), true
+		case protoreflect.DoubleKind:
+			return protoreflect.ValueOfFloat64(round(v.Float())), true
+		case protoreflect.MessageKind:
+			roundFloats(v.Message())
+		}
+		return v, false
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			l := v.List()
+			for i := range l.Len() {
+				if nv, changed := roundValue(fd, l.Get(i)); changed {
+					l.Set(i, nv)
+				}
+			}
+		case fd.IsMap():
+		default:
+			if nv, changed := roundValue(fd, v); changed {
+				m.Set(fd, nv)
+			}
+		}
+		return true
+	})
+}
+
+func compareGolden(t *testing.T, fn string, got []*racestatev1.PublishStateRequest) {
+	t.Helper()
+	if *update {
+		writeGolden(t, fn, got)
+		t.Logf("golden file %s written", fn)
+		return
+	}
+	data, err := os.ReadFile(fn)
+	if os.IsNotExist(err) {
+		t.Fatalf("golden file %s missing, run the test with -update", fn)
+	}
+	require.NoError(t, err)
+
+	want := []*racestatev1.PublishStateRequest{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		msg := &racestatev1.PublishStateRequest{}
+		require.NoError(t, protojson.Unmarshal(scanner.Bytes(), msg))
+		want = append(want, msg)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, got, len(want), "number of messages")
+	for i := range want {
+		if !proto.Equal(want[i], got[i]) {
+			t.Fatalf("message %d differs\nwant: %v\ngot:  %v", i, want[i], got[i])
+		}
+	}
+}
+
+func writeGolden(t *testing.T, fn string, msgs []*racestatev1.PublishStateRequest) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(fn), 0o755))
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		data, err := protojson.Marshal(msg)
+		require.NoError(t, err)
+		// protojson output is not stable, compact it to keep diffs small
+		require.NoError(t, json.Compact(&buf, data))
+		buf.WriteByte('\n')
+	}
+	require.NoError(t, os.WriteFile(fn, buf.Bytes(), 0o600))
+}
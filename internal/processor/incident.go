@@ -0,0 +1,178 @@
+package processor
+
+import (
+	"maps"
+
+	"github.com/mpapenbr/go-racelogger/internal/telemetry"
+	"github.com/mpapenbr/go-racelogger/log"
+)
+
+type IncidentKind string
+
+const (
+	IncidentOffTrack IncidentKind = "offtrack"
+	IncidentSpin     IncidentKind = "spin"
+	IncidentStopped  IncidentKind = "stopped"
+)
+
+const (
+	// a spin is a backward movement shortly after the car was faster than this (km/h)
+	spinMinSpeed = 60
+	// max time (seconds) between the car being fast and moving backward
+	spinWindow = 3
+	// a car is considered stopped if it is slower than this (km/h)
+	stoppedSpeed = 1
+	// a car has to stand still for this time (seconds) to be reported
+	stoppedMinDuration = 5
+)
+
+// Incident contains an off track excursion, spin or stopped car.
+// Times are session times (seconds)
+type Incident struct {
+	CarIdx      int32        `json:"carIdx"`
+	Kind        IncidentKind `json:"kind"`
+	SessionTime float64      `json:"sessionTime"`
+	Lap         int          `json:"lap"`
+	TrackPos    float64      `json:"trackPos"`
+	// duration of off track excursions and stops (0 for spins or if ongoing)
+	Duration float64 `json:"duration"`
+}
+
+// ReportIncident is called when an incident is detected
+type ReportIncident func(inc *Incident)
+
+type carIncidents struct {
+	offTrack     *Incident // current off track excursion
+	stopped      *Incident // current stop (once reported)
+	stoppedSince float64
+	lastFast     float64 // last session time the car was faster than spinMinSpeed
+	lastSpin     float64
+	incidents    []*Incident
+	counts       map[IncidentKind]int
+}
+
+// IncidentProc detects incidents of cars running on track
+type IncidentProc struct {
+	cars   map[int32]*carIncidents
+	report ReportIncident
+}
+
+func NewIncidentProc(report ReportIncident) *IncidentProc {
+	return &IncidentProc{cars: map[int32]*carIncidents{}, report: report}
+}
+
+// Process is called for each car after the speed was computed
+func (p *IncidentProc) Process(cd *CarData) {
+	ci, ok := p.cars[cd.carIdx]
+	if !ok {
+		ci = &carIncidents{counts: map[IncidentKind]int{}}
+		p.cars[cd.carIdx] = ci
+	}
+	now := cd.sessionTime
+	if cd.state != CarStateRun && cd.state != CarStateSlow {
+		ci.endOffTrack(now)
+		ci.endStopped(now)
+		return
+	}
+
+	if cd.trackLoc == int32(telemetry.TrackLocationOffTrack) {
+		if ci.offTrack == nil {
+			ci.offTrack = p.add(ci, cd, IncidentOffTrack, now)
+		}
+	} else {
+		ci.endOffTrack(now)
+	}
+
+	if cd.speed > spinMinSpeed {
+		ci.lastFast = now
+	}
+	if cd.movedBackward && now-ci.lastFast <= spinWindow && now-ci.lastSpin > spinWindow {
+		ci.lastSpin = now
+		p.add(ci, cd, IncidentSpin, now)
+	}
+
+	if cd.speed >= stoppedSpeed {
+		ci.endStopped(now)
+		return
+	}
+	if ci.stoppedSince == 0 {
+		ci.stoppedSince = now
+	}
+	if ci.stopped == nil && now-ci.stoppedSince >= stoppedMinDuration {
+		ci.stopped = p.add(ci, cd, IncidentStopped, ci.stoppedSince)
+	}
+}
+
+//nolint:whitespace // can't get different linters happy
+func (p *IncidentProc) add(
+	ci *carIncidents,
+	cd *CarData,
+	kind IncidentKind,
+	sessionTime float64,
+) *Incident {
+	inc := &Incident{
+		CarIdx:      cd.carIdx,
+		Kind:        kind,
+		SessionTime: sessionTime,
+		Lap:         cd.lap,
+		TrackPos:    cd.trackPos,
+	}
+	cd.log.Debug("Incident detected", log.String("kind", string(kind)))
+	ci.incidents = append(ci.incidents, inc)
+	ci.counts[kind]++
+	if p.report != nil {
+		p.report(inc)
+	}
+	return inc
+}
+
+func (ci *carIncidents) endOffTrack(now float64) {
+	if ci.offTrack != nil {
+		ci.offTrack.Duration = now - ci.offTrack.SessionTime
+		ci.offTrack = nil
+	}
+}
+
+func (ci *carIncidents) endStopped(now float64) {
+	if ci.stopped != nil {
+		ci.stopped.Duration = now - ci.stopped.SessionTime
+		ci.stopped = nil
+	}
+	ci.stoppedSince = 0
+}
+
+// IncidentReport contains the incidents and lappings of a race session
+type IncidentReport struct {
+	Counts       map[int32]map[IncidentKind]int `json:"counts"`
+	Incidents    map[int32][]Incident           `json:"incidents"`
+	LappingStats map[int32]LappingStats         `json:"lappingStats"`
+	Lappings     []Lapping                      `json:"lappings"`
+}
+
+// Incidents returns the incidents by carIdx
+func (p *IncidentProc) Incidents() map[int32][]Incident {
+	ret := make(map[int32][]Incident, len(p.cars))
+	for carIdx, ci := range p.cars {
+		if len(ci.incidents) == 0 {
+			continue
+		}
+		list := make([]Incident, len(ci.incidents))
+		for i, inc := range ci.incidents {
+			list[i] = *inc
+		}
+		ret[carIdx] = list
+	}
+	return ret
+}
+
+// Counts returns the number of incidents of each kind by carIdx
+func (p *IncidentProc) Counts() map[int32]map[IncidentKind]int {
+	ret := make(map[int32]map[IncidentKind]int, len(p.cars))
+	for carIdx, ci := range p.cars {
+		if len(ci.incidents) == 0 {
+			continue
+		}
+		ret[carIdx] = maps.Clone(ci.counts)
+	}
+	return ret
+}
@@ -0,0 +1,47 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncidentProc(t *testing.T) {
+	states, _, proc := runGoldenScenario(t, loadScenarioGenerator(t, "incidents"),
+		WithMaxSpeed(500), WithIncidentMessages(true))
+
+	assert.Equal(t, map[int32]map[IncidentKind]int{
+		2: {IncidentOffTrack: 1},
+		3: {IncidentSpin: 1},
+		4: {IncidentStopped: 1},
+	}, proc.IncidentCounts())
+
+	incidents := proc.Incidents()
+	require.Len(t, incidents[2], 1)
+	assert.InDelta(t, 5, incidents[2][0].Duration, 0.5)
+	assert.InDelta(t, 0.3, incidents[2][0].TrackPos, 0.01)
+	require.Len(t, incidents[4], 1)
+	assert.InDelta(t, 12, incidents[4][0].Duration, 0.5)
+	assert.Equal(t, 4, incidents[4][0].Lap)
+
+	msgs := stateMessages(states)
+	assert.Equal(t, []string{"#8 (Gus Golf) off track (lap 2, 30%)"},
+		messagesContaining(msgs, " off track "))
+	assert.Equal(t, []string{"#15 (Hal Hotel) spun (lap 3, 45%)"},
+		messagesContaining(msgs, " spun "))
+	assert.Equal(t, []string{"#31 (Ida India) stopped on track (lap 4, 60%)"},
+		messagesContaining(msgs, " stopped on track "))
+}
+
+func TestIncidentProc_MessagesDisabled(t *testing.T) {
+	states, _, proc := runGoldenScenario(t, loadScenarioGenerator(t, "incidents"),
+		WithMaxSpeed(500))
+
+	// the incidents are recorded anyway
+	assert.Len(t, proc.IncidentCounts(), 3)
+	msgs := stateMessages(states)
+	assert.Empty(t, messagesContaining(msgs, " off track "))
+	assert.Empty(t, messagesContaining(msgs, " spun "))
+	assert.Empty(t, messagesContaining(msgs, " stopped on track "))
+}
@@ -0,0 +1,143 @@
+package processor
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// LapChartEntry contains the position of a car when it completed a lap.
+// Times are session times (seconds)
+type LapChartEntry struct {
+	CarIdx int32  `json:"carIdx"`
+	CarNum string `json:"carNum"`
+	Lap    int    `json:"lap"`
+	// positions by the order the cars completed this lap
+	Pos     int     `json:"pos"`
+	Pic     int     `json:"pic"`
+	LapTime float64 `json:"lapTime"` // -1 if unknown
+	// time since the first car completed this lap
+	Gap         float64 `json:"gap"`
+	Pit         bool    `json:"pit"` // the car was in the pit lane during this lap
+	SessionTime float64 `json:"sessionTime"`
+}
+
+// LapChartListener is called with the lap completions recorded since the last
+// call whenever the state is published. It is not called if there are none.
+type LapChartListener func(laps []LapChartEntry)
+
+type lapHistoryCar struct {
+	lc       int
+	pitstops int
+	pit      bool // car was in the pit lane during the current lap
+}
+
+// LapHistoryProc records the position of each car at every lap completion
+type LapHistoryProc struct {
+	carDriverProc *CarDriverProc
+	cars          map[int32]*lapHistoryCar
+	entries       []LapChartEntry
+	sent          int             // number of entries returned by NewLaps
+	firstTime     map[int]float64 // session time the first car completed the lap
+	completed     map[int]int     // number of cars which completed the lap
+	completedPic  map[int]map[int]int
+}
+
+func NewLapHistoryProc(carDriverProc *CarDriverProc) *LapHistoryProc {
+	return &LapHistoryProc{
+		carDriverProc: carDriverProc,
+		cars:          map[int32]*lapHistoryCar{},
+		firstTime:     map[int]float64{},
+		completed:     map[int]int{},
+		completedPic:  map[int]map[int]int{},
+	}
+}
+
+// Process is called for each car once its data is processed
+func (p *LapHistoryProc) Process(cd *CarData) {
+	car, ok := p.cars[cd.carIdx]
+	if !ok {
+		// the first lap seen may not be complete
+		p.cars[cd.carIdx] = &lapHistoryCar{lc: cd.lc, pitstops: cd.pitstops}
+		return
+	}
+	if cd.state == CarStatePit {
+		car.pit = true
+	}
+	if cd.lc <= car.lc {
+		return
+	}
+	car.lc = cd.lc
+	if cd.lc < 1 {
+		return // crossed the start line
+	}
+	now := cd.sessionTime
+	if _, ok := p.firstTime[cd.lc]; !ok {
+		p.firstTime[cd.lc] = now
+		p.completedPic[cd.lc] = map[int]int{}
+	}
+	classID := p.carDriverProc.GetCurrentDriver(cd.carIdx).CarClassID
+	p.completed[cd.lc]++
+	p.completedPic[cd.lc][classID]++
+	lapTime := cd.laptiming.lap.duration.time
+	if lapTime <= 0 {
+		lapTime = -1
+	}
+	p.entries = append(p.entries, LapChartEntry{
+		CarIdx:      cd.carIdx,
+		CarNum:      p.carDriverProc.GetCurrentDriver(cd.carIdx).CarNumber,
+		Lap:         cd.lc,
+		Pos:         p.completed[cd.lc],
+		Pic:         p.completedPic[cd.lc][classID],
+		LapTime:     lapTime,
+		Gap:         now - p.firstTime[cd.lc],
+		Pit:         car.pit || cd.pitstops > car.pitstops,
+		SessionTime: now,
+	})
+	car.pit = cd.state == CarStatePit
+	car.pitstops = cd.pitstops
+}
+
+// LapChart returns all recorded lap completions in the order they happened
+func (p *LapHistoryProc) LapChart() []LapChartEntry {
+	ret := make([]LapChartEntry, len(p.entries))
+	copy(ret, p.entries)
+	return ret
+}
+
+// NewLaps returns the lap completions recorded since the last call
+func (p *LapHistoryProc) NewLaps() []LapChartEntry {
+	ret := make([]LapChartEntry, len(p.entries)-p.sent)
+	copy(ret, p.entries[p.sent:])
+	p.sent = len(p.entries)
+	return ret
+}
+
+// WriteLapChartJSON writes the lap chart as indented JSON
+func WriteLapChartJSON(w io.Writer, chart []LapChartEntry) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(chart)
+}
+
+// WriteLapChartCSV writes one line per car and lap
+func WriteLapChartCSV(w io.Writer, chart []LapChartEntry) error {
+	out := csv.NewWriter(w)
+	//nolint:errcheck // errors are reported by out.Error
+	out.Write([]string{
+		"carIdx", "carNum", "lap", "pos", "pic", "lapTime", "gap", "pit",
+		"sessionTime",
+	})
+	ftoa := func(f float64) string { return strconv.FormatFloat(f, 'f', 3, 64) }
+	for _, e := range chart {
+		//nolint:errcheck // errors are reported by out.Error
+		out.Write([]string{
+			strconv.Itoa(int(e.CarIdx)), e.CarNum, strconv.Itoa(e.Lap),
+			strconv.Itoa(e.Pos), strconv.Itoa(e.Pic), ftoa(e.LapTime), ftoa(e.Gap),
+			strconv.FormatBool(e.Pit), ftoa(e.SessionTime),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
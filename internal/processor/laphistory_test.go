@@ -0,0 +1,56 @@
+package processor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLapHistoryProc(t *testing.T) {
+	g := loadScenarioGenerator(t, "sprint")
+	var published []LapChartEntry
+	calls := 0
+	_, _, proc := runGoldenScenario(t, g, WithMaxSpeed(500),
+		WithLapChartListener(func(laps []LapChartEntry) {
+			// only the laps added since the last call
+			assert.NotEmpty(t, laps)
+			published = append(published, laps...)
+			calls++
+		}))
+
+	chart := proc.LapChart()
+	assert.Equal(t, chart, published)
+	assert.Greater(t, calls, 1)
+	require.Len(t, chart, 8*5)
+
+	order := map[int][]string{}
+	for _, e := range chart {
+		order[e.Lap] = append(order[e.Lap], e.CarNum)
+		assert.Equal(t, len(order[e.Lap]), e.Pos)
+		assert.Equal(t, e.Pos, e.Pic)
+		if e.Pos == 1 {
+			assert.Zero(t, e.Gap)
+		} else {
+			assert.Positive(t, e.Gap)
+		}
+		if e.Lap > 1 {
+			// laps under caution and pit laps are slower
+			assert.Greater(t, e.LapTime, 39.0, "car %s lap %d", e.CarNum, e.Lap)
+		}
+		// #11 pits at the end of lap 4 (in lap) and leaves in lap 5 (out lap)
+		assert.Equal(t, e.CarNum == "11" && (e.Lap == 4 || e.Lap == 5), e.Pit,
+			"car %s lap %d", e.CarNum, e.Lap)
+	}
+	assert.Equal(t, []string{"7", "11", "23", "42", "99"}, order[1])
+	assert.Equal(t, []string{"7", "23", "99", "42", "11"}, order[8])
+
+	buf := bytes.Buffer{}
+	require.NoError(t, WriteLapChartCSV(&buf, chart))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, len(chart)+1)
+	assert.Equal(t, "carIdx,carNum,lap,pos,pic,lapTime,gap,pit,sessionTime", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "1,7,1,1,1,-1.000,0.000,false,"), lines[1])
+}
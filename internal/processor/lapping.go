@@ -0,0 +1,177 @@
+package processor
+
+import (
+	"math"
+
+	"github.com/mpapenbr/go-racelogger/log"
+)
+
+// a pending lapping is dropped if the lapping car falls back further than
+// this multiple of the blue flag window (or one of the cars leaves the track)
+const lappingAbortFactor = 2
+
+// Lapping contains the lapping of a car by a faster car.
+// Times are session times (seconds)
+type Lapping struct {
+	CarIdx       int32 `json:"carIdx"` // the car which laps the other one
+	LappedCarIdx int32 `json:"lappedCarIdx"`
+	// the lapping car got within the blue flag window
+	BlueFlagTime float64 `json:"blueFlagTime"`
+	PassTime     float64 `json:"passTime"`
+	Lap          int     `json:"lap"` // lap of the lapping car at the pass
+	// time lost by the lapping car compared to the speedmap of its class
+	TimeLost float64 `json:"timeLost"`
+
+	startPos float64 // trackPos of the lapping car at BlueFlagTime
+	laps     int     // laps ahead of the lapped car after the pass
+}
+
+// LappingStats contains the blue flag and lapping counters of a car
+type LappingStats struct {
+	BlueFlags int     `json:"blueFlags"` // times the blue flag was shown to the car
+	Lapped    int     `json:"lapped"`    // times the car was lapped
+	Lapping   int     `json:"lapping"`   // number of cars lapped by this car
+	TimeLost  float64 `json:"timeLost"`  // time lost while lapping other cars
+}
+
+type lappingKey struct {
+	carIdx, lappedCarIdx int32
+}
+
+// detectLapping reports cars which are about to be lapped (blue flag) and
+// cars which lapped other cars since the last call.
+// A car gets the blue flag if a car which is at least one lap ahead is within
+// blueFlagWindow seconds behind it on track.
+// order is the race order by distance (lap + trackPos).
+// The speedmap is only used for pairs of cars which are close enough on track
+// (see reach).
+//
+//nolint:cyclop // by design
+func (p *CarProc) detectLapping(order []*CarData) {
+	if p.blueFlagWindow <= 0 || p.winnerCrossedTheLine {
+		return
+	}
+	racing := make([]*CarData, 0, len(order))
+	for _, c := range order {
+		if c.state == CarStateRun || c.state == CarStateSlow {
+			racing = append(racing, c)
+		}
+	}
+	raceDist := func(c *CarData) float64 { return float64(c.lap) + c.trackPos }
+	trackLength := float64(p.gpd.TrackInfo.Length)
+	blueFlagReach := p.reach(p.blueFlagWindow)
+	abortReach := p.reach(p.blueFlagWindow * lappingAbortFactor)
+
+	pending := make(map[lappingKey]*Lapping, len(p.pendingLappings))
+	for i, car := range racing {
+		for _, other := range racing[i+1:] {
+			key := lappingKey{car.carIdx, other.carIdx}
+			ahead := raceDist(car) - raceDist(other)
+			// distance on track from car to other (other is in front)
+			trackGap := math.Mod(other.trackPos-car.trackPos+1, 1)
+			if l, ok := p.pendingLappings[key]; ok {
+				if ahead >= float64(l.laps) {
+					p.finishLapping(l, car)
+					continue
+				}
+				if trackGap*trackLength <= abortReach &&
+					p.timeBehind(car, other) <= p.blueFlagWindow*lappingAbortFactor {
+
+					pending[key] = l
+				}
+				continue
+			}
+			laps := int(math.Round(ahead + trackGap))
+			if laps < 1 || trackGap > 0.5 || trackGap*trackLength > blueFlagReach ||
+				p.timeBehind(car, other) > p.blueFlagWindow {
+
+				continue
+			}
+			l := &Lapping{
+				CarIdx:       car.carIdx,
+				LappedCarIdx: other.carIdx,
+				BlueFlagTime: p.currentTime,
+				startPos:     car.trackPos,
+				laps:         laps,
+			}
+			pending[key] = l
+			p.lappingStatsOf(other.carIdx).BlueFlags++
+			p.messageProc.ReportBlueFlag(l)
+		}
+	}
+	p.pendingLappings = pending
+}
+
+// reach returns the track distance (meters) a car can cover within d seconds.
+// Speeds above maxSpeed are ignored, so cars further apart are more than d
+// seconds apart.
+func (p *CarProc) reach(d float64) float64 {
+	if p.maxSpeed <= 0 {
+		return math.MaxFloat64
+	}
+	return d * p.maxSpeed / 3.6
+}
+
+// timeBehind returns the time car needs to reach the current position of
+// carInFront. The speedmap of the car class is used if available.
+func (p *CarProc) timeBehind(car, carInFront *CarData) float64 {
+	classID := p.carDriverProc.GetCurrentDriver(car.carIdx).CarClassID
+	delta := p.speedmapProc.ComputeDeltaTime(classID, carInFront.trackPos, car.trackPos)
+	if delta > 0 {
+		return delta
+	}
+	if car.speed <= 0 {
+		return math.MaxFloat64
+	}
+	dist := math.Mod(carInFront.trackPos-car.trackPos+1, 1) *
+		float64(p.gpd.TrackInfo.Length)
+	return dist / car.speed * 3.6
+}
+
+// finishLapping is called when car passed the lapped car of l
+func (p *CarProc) finishLapping(l *Lapping, car *CarData) {
+	l.PassTime = p.currentTime
+	l.Lap = car.lap
+	classID := p.carDriverProc.GetCurrentDriver(car.carIdx).CarClassID
+	expected := p.speedmapProc.ComputeDeltaTime(classID, car.trackPos, l.startPos)
+	if expected > 0 {
+		l.TimeLost = math.Max(0, l.PassTime-l.BlueFlagTime-expected)
+	}
+	p.log.Debug("Lapping done",
+		log.Int32("carIdx", l.CarIdx),
+		log.Int32("lappedCarIdx", l.LappedCarIdx),
+		log.Float64("timeLost", l.TimeLost))
+	p.lappings = append(p.lappings, l)
+	p.lappingStatsOf(l.LappedCarIdx).Lapped++
+	stats := p.lappingStatsOf(l.CarIdx)
+	stats.Lapping++
+	stats.TimeLost += l.TimeLost
+	p.messageProc.ReportLapping(l)
+}
+
+func (p *CarProc) lappingStatsOf(carIdx int32) *LappingStats {
+	stats, ok := p.lappingStats[carIdx]
+	if !ok {
+		stats = &LappingStats{}
+		p.lappingStats[carIdx] = stats
+	}
+	return stats
+}
+
+// Lappings returns the completed lappings
+func (p *CarProc) Lappings() []Lapping {
+	ret := make([]Lapping, len(p.lappings))
+	for i, l := range p.lappings {
+		ret[i] = *l
+	}
+	return ret
+}
+
+// LappingStats returns the blue flag and lapping counters by carIdx
+func (p *CarProc) LappingStats() map[int32]LappingStats {
+	ret := make(map[int32]LappingStats, len(p.lappingStats))
+	for carIdx, stats := range p.lappingStats {
+		ret[carIdx] = *stats
+	}
+	return ret
+}
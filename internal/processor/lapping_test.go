@@ -0,0 +1,60 @@
+package processor
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCarProc_Lapping(t *testing.T) {
+	states, _, proc := runGoldenScenario(t, loadScenarioGenerator(t, "lapping"),
+		WithMaxSpeed(500), WithBlueFlagWindow(2))
+
+	// both prototypes lap both GT cars twice
+	assert.Equal(t, map[int32]LappingStats{
+		1: {Lapping: 4},
+		2: {Lapping: 4},
+		3: {BlueFlags: 4, Lapped: 4},
+		4: {BlueFlags: 4, Lapped: 4},
+	}, zeroTimeLost(proc.LappingStats()))
+
+	lappings := proc.Lappings()
+	require.Len(t, lappings, 8)
+	for _, l := range lappings {
+		assert.Greater(t, l.PassTime, l.BlueFlagTime)
+		// the generator doesn't simulate traffic, only noise is expected
+		assert.Less(t, l.TimeLost, 0.5)
+	}
+	assert.Equal(t, int32(1), lappings[0].CarIdx)
+	assert.Equal(t, int32(4), lappings[0].LappedCarIdx)
+	assert.Equal(t, 4, lappings[0].Lap)
+
+	msgs := stateMessages(states)
+	assert.Len(t, messagesContaining(msgs, "Blue flag for #51 (#5 approaching)"), 2)
+	assert.Len(t, messagesContaining(msgs, "#5 laps #51 (time lost: 0.0s)"), 2)
+}
+
+func TestCarProc_LappingDisabled(t *testing.T) {
+	_, _, proc := runGoldenScenario(t, loadScenarioGenerator(t, "lapping"),
+		WithMaxSpeed(500), WithBlueFlagWindow(0))
+	assert.Empty(t, proc.Lappings())
+	assert.Empty(t, proc.LappingStats())
+}
+
+func zeroTimeLost(stats map[int32]LappingStats) map[int32]LappingStats {
+	for k, v := range stats {
+		v.TimeLost = 0
+		stats[k] = v
+	}
+	return stats
+}
+
+func TestCarProc_Reach(t *testing.T) {
+	p := &CarProc{maxSpeed: 360}
+	// 100 m/s
+	assert.InDelta(t, 200.0, p.reach(2), 0.001)
+	p.maxSpeed = 0
+	assert.Equal(t, math.MaxFloat64, p.reach(2))
+}
@@ -2,6 +2,7 @@ package processor
 
 import (
 	"fmt"
+	"strings"
 
 	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
 
@@ -61,6 +62,138 @@ func (p *MessageProc) ReportDriverLap(carIdx int, twm TimeWithMarker) {
 	})
 }
 
+func (p *MessageProc) ReportPitStop(ps *PitStop) {
+	log.Debug("Report pit stop", log.Int32("carIdx", ps.CarIdx))
+	driver := p.carDriverProc.GetCurrentDriver(ps.CarIdx)
+	var changes strings.Builder
+	if ps.DriverChanged {
+		changes.WriteString(", driver change")
+	}
+	if ps.TireCompoundChanged {
+		changes.WriteString(", tire compound change")
+	}
+	p.buffer = append(p.buffer, &racestatev1.Message{
+		Type:     racestatev1.MessageType_MESSAGE_TYPE_PITS,
+		SubType:  racestatev1.MessageSubType_MESSAGE_SUB_TYPE_DRIVER,
+		CarIdx:   uint32(ps.CarIdx),
+		CarNum:   driver.CarNumber,
+		CarClass: driver.CarClassShortName,
+		Msg: fmt.Sprintf("#%s (%s) pit stop: %.1fs pit lane, %.1fs stationary%s",
+			driver.CarNumber,
+			p.carDriverProc.GetLatestDriverName(ps.CarIdx),
+			ps.LaneTime,
+			ps.StationaryTime,
+			changes.String(),
+		),
+	})
+}
+
+// ReportOvertake reports that car passed another car for position pos.
+// The class position pic is added if it is > 0.
+func (p *MessageProc) ReportOvertake(carIdx, passedCarIdx int32, pos, pic int) {
+	log.Debug("Report overtake",
+		log.Int32("carIdx", carIdx), log.Int32("passedCarIdx", passedCarIdx))
+	msg := fmt.Sprintf("#%s passes #%s for P%d",
+		p.carDriverProc.GetCurrentDriver(carIdx).CarNumber,
+		p.carDriverProc.GetCurrentDriver(passedCarIdx).CarNumber,
+		pos)
+	if pic > 0 {
+		msg += fmt.Sprintf(" (class P%d)", pic)
+	}
+	p.buffer = append(p.buffer, &racestatev1.Message{
+		Type:     racestatev1.MessageType_MESSAGE_TYPE_TIMING,
+		SubType:  racestatev1.MessageSubType_MESSAGE_SUB_TYPE_RACE_CONTROL,
+		CarIdx:   uint32(carIdx),
+		CarNum:   p.carDriverProc.GetCurrentDriver(carIdx).CarNumber,
+		CarClass: p.carDriverProc.GetCurrentDriver(carIdx).CarClassShortName,
+		Msg:      msg,
+	})
+}
+
+// ReportBattleStart reports a new battle
+func (p *MessageProc) ReportBattleStart(b *Battle) {
+	log.Debug("Report battle start", log.Int("id", b.ID))
+	p.reportBattle(b, fmt.Sprintf("Battle for P%d: %s", b.Pos, b.carList()))
+}
+
+// ReportBattleEnd reports the end of a battle
+func (p *MessageProc) ReportBattleEnd(b *Battle) {
+	log.Debug("Report battle end", log.Int("id", b.ID))
+	p.reportBattle(b, fmt.Sprintf(
+		"Battle for P%d ended after %.0fs (%s, %d laps, %d position changes)",
+		b.Pos, b.Duration, b.carList(), b.Laps, b.PositionSwaps))
+}
+
+func (p *MessageProc) reportBattle(b *Battle, msg string) {
+	p.buffer = append(p.buffer, &racestatev1.Message{
+		Type:     racestatev1.MessageType_MESSAGE_TYPE_TIMING,
+		SubType:  racestatev1.MessageSubType_MESSAGE_SUB_TYPE_RACE_CONTROL,
+		CarIdx:   uint32(b.CarIdxs[0]),
+		CarNum:   b.CarNums[0],
+		CarClass: p.carDriverProc.GetCurrentDriver(b.CarIdxs[0]).CarClassShortName,
+		Msg:      msg,
+	})
+}
+
+// ReportBlueFlag reports that a car which is about to be lapped gets the blue flag
+func (p *MessageProc) ReportBlueFlag(l *Lapping) {
+	log.Debug("Report blue flag",
+		log.Int32("carIdx", l.LappedCarIdx), log.Int32("lappingCarIdx", l.CarIdx))
+	lapped := p.carDriverProc.GetCurrentDriver(l.LappedCarIdx)
+	p.buffer = append(p.buffer, &racestatev1.Message{
+		Type:     racestatev1.MessageType_MESSAGE_TYPE_TIMING,
+		SubType:  racestatev1.MessageSubType_MESSAGE_SUB_TYPE_RACE_CONTROL,
+		CarIdx:   uint32(l.LappedCarIdx),
+		CarNum:   lapped.CarNumber,
+		CarClass: lapped.CarClassShortName,
+		Msg: fmt.Sprintf("Blue flag for #%s (#%s approaching)",
+			lapped.CarNumber,
+			p.carDriverProc.GetCurrentDriver(l.CarIdx).CarNumber),
+	})
+}
+
+// ReportLapping reports that a car lapped another car
+func (p *MessageProc) ReportLapping(l *Lapping) {
+	log.Debug("Report lapping",
+		log.Int32("carIdx", l.CarIdx), log.Int32("lappedCarIdx", l.LappedCarIdx))
+	car := p.carDriverProc.GetCurrentDriver(l.CarIdx)
+	p.buffer = append(p.buffer, &racestatev1.Message{
+		Type:     racestatev1.MessageType_MESSAGE_TYPE_TIMING,
+		SubType:  racestatev1.MessageSubType_MESSAGE_SUB_TYPE_RACE_CONTROL,
+		CarIdx:   uint32(l.CarIdx),
+		CarNum:   car.CarNumber,
+		CarClass: car.CarClassShortName,
+		Msg: fmt.Sprintf("#%s laps #%s (time lost: %.1fs)",
+			car.CarNumber,
+			p.carDriverProc.GetCurrentDriver(l.LappedCarIdx).CarNumber,
+			l.TimeLost),
+	})
+}
+
+func (p *MessageProc) ReportIncident(inc *Incident) {
+	log.Debug("Report incident",
+		log.Int32("carIdx", inc.CarIdx), log.String("kind", string(inc.Kind)))
+	what := map[IncidentKind]string{
+		IncidentOffTrack: "off track",
+		IncidentSpin:     "spun",
+		IncidentStopped:  "stopped on track",
+	}[inc.Kind]
+	p.buffer = append(p.buffer, &racestatev1.Message{
+		Type:     racestatev1.MessageType_MESSAGE_TYPE_TIMING,
+		SubType:  racestatev1.MessageSubType_MESSAGE_SUB_TYPE_RACE_CONTROL,
+		CarIdx:   uint32(inc.CarIdx),
+		CarNum:   p.carDriverProc.GetCurrentDriver(inc.CarIdx).CarNumber,
+		CarClass: p.carDriverProc.GetCurrentDriver(inc.CarIdx).CarClassShortName,
+		Msg: fmt.Sprintf("#%s (%s) %s (lap %d, %.0f%%)",
+			p.carDriverProc.GetCurrentDriver(inc.CarIdx).CarNumber,
+			p.carDriverProc.GetLatestDriverName(inc.CarIdx),
+			what,
+			inc.Lap,
+			inc.TrackPos*100,
+		),
+	})
+}
+
 func (p *MessageProc) RaceStarts() {
 	p.buffer = append(p.buffer, &racestatev1.Message{
 		Type:    racestatev1.MessageType_MESSAGE_TYPE_TIMING,
@@ -0,0 +1,99 @@
+package processor
+
+// cars have to be within this distance (meters) before and after a position
+// change to be considered a pass on track
+const overtakeMaxDist = 100
+
+// OvertakeFilter selects the overtakes which are reported as messages
+type OvertakeFilter struct {
+	// report passes at all (default: off)
+	Enabled bool
+	// report only passes for this position or better (0: all).
+	// If SameClass is set this refers to the class position.
+	TopN int
+	// report only passes between cars of the same car class
+	SameClass bool
+}
+
+func (f OvertakeFilter) accept(sameClass bool, pos, pic int) bool {
+	if f.SameClass && !sameClass {
+		return false
+	}
+	if f.SameClass {
+		pos = pic
+	}
+	return f.TopN <= 0 || pos <= f.TopN
+}
+
+// detectOvertakes reports cars which passed other cars on track since the
+// last call.
+// order is the race order by distance (lap + trackPos), so lapping and
+// unlapping as well as corrections of the iRacing standings don't change it.
+// Only cars which were running (not in the pit lane, out or finished) in both
+// calls are compared. This way position changes caused by pit stops are ignored.
+func (p *CarProc) detectOvertakes(order []*CarData) {
+	if !p.overtakeFilter.Enabled {
+		return
+	}
+	isRacing := func(c *CarData) bool {
+		return c.state == CarStateRun || c.state == CarStateSlow
+	}
+	cur := make(map[int32]float64, len(order))
+	for _, c := range order {
+		if isRacing(c) {
+			cur[c.carIdx] = float64(c.lap) + c.trackPos
+		}
+	}
+	prev := p.prevRaceDist
+	p.prevRaceDist = cur
+	if prev == nil || p.winnerCrossedTheLine {
+		return
+	}
+	maxDist := overtakeMaxDist / float64(p.gpd.TrackInfo.Length)
+	for i, car := range order {
+		carCur, ok := cur[car.carIdx]
+		if !ok {
+			continue
+		}
+		carPrev, ok := prev[car.carIdx]
+		if !ok {
+			continue
+		}
+		for _, other := range order[i+1:] {
+			otherCur, ok := cur[other.carIdx]
+			if !ok {
+				continue
+			}
+			if carCur-otherCur > maxDist {
+				break
+			}
+			otherPrev, ok := prev[other.carIdx]
+			if !ok || carPrev >= otherPrev || otherPrev-carPrev > maxDist {
+				continue
+			}
+			p.reportOvertake(order, i, other)
+		}
+	}
+}
+
+// reportOvertake reports that order[i] passed other
+func (p *CarProc) reportOvertake(order []*CarData, i int, other *CarData) {
+	car := order[i]
+	classID := func(c *CarData) int {
+		return p.carDriverProc.GetCurrentDriver(c.carIdx).CarClassID
+	}
+	pic := 0
+	for _, c := range order[:i+1] {
+		if classID(c) == classID(car) {
+			pic++
+		}
+	}
+	sameClass := classID(car) == classID(other)
+	if !p.overtakeFilter.accept(sameClass, i+1, pic) {
+		return
+	}
+	if !p.gpd.EventDataInfo.GetMultiClass() {
+		pic = 0
+	}
+	p.messageProc.ReportOvertake(car.carIdx, other.carIdx, i+1, pic)
+}
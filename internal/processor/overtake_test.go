@@ -0,0 +1,45 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func overtakeMessages(t *testing.T, scenario string, f OvertakeFilter) []string {
+	t.Helper()
+	states, _, _ := runGoldenScenario(t, loadScenarioGenerator(t, scenario),
+		WithMaxSpeed(500), WithOvertakeFilter(f))
+	return messagesContaining(stateMessages(states), " passes ")
+}
+
+func TestCarProc_Overtakes(t *testing.T) {
+	// #42 goes off track, #11 stops in the pits (not reported as passes)
+	assert.Equal(t, []string{"#99 passes #42 for P4"},
+		overtakeMessages(t, "sprint", OvertakeFilter{Enabled: true}))
+
+	// lapping the GT cars is not reported
+	assert.Equal(t, []string{
+		"#32 passes #31 for P4 (class P2)",
+		"#31 passes #32 for P4 (class P2)",
+		"#33 passes #32 for P5 (class P3)",
+		"#1 passes #2 for P1 (class P1)",
+	}, overtakeMessages(t, "multiclass", OvertakeFilter{Enabled: true}))
+}
+
+func TestCarProc_OvertakesDisabled(t *testing.T) {
+	assert.Empty(t, overtakeMessages(t, "sprint", OvertakeFilter{}))
+}
+
+func TestCarProc_OvertakeFilter(t *testing.T) {
+	assert.Equal(t, []string{
+		"#32 passes #31 for P4 (class P2)",
+		"#31 passes #32 for P4 (class P2)",
+		"#1 passes #2 for P1 (class P1)",
+	}, overtakeMessages(t, "multiclass", OvertakeFilter{Enabled: true, TopN: 4}))
+	assert.Equal(t, []string{
+		"#32 passes #31 for P4 (class P2)",
+		"#31 passes #32 for P4 (class P2)",
+		"#1 passes #2 for P1 (class P1)",
+	}, overtakeMessages(t, "multiclass", OvertakeFilter{Enabled: true, TopN: 2, SameClass: true}))
+}
@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/mpapenbr/go-racelogger/log"
+)
+
+// a car in the pit lane is considered stationary if it is slower than this (km/h)
+const PitStationarySpeed = 1
+
+// ReportPitStop is called when a car leaves the pit lane after a pit stop
+type ReportPitStop func(ps *PitStop)
+
+// PitStop contains the analytics of a single pit stop.
+// Times are session times (seconds)
+type PitStop struct {
+	CarIdx    int32   `json:"carIdx"`
+	CarNum    string  `json:"carNum"`
+	EntryTime float64 `json:"entryTime"`
+	ExitTime  float64 `json:"exitTime"`
+	// time spent in the pit lane
+	LaneTime float64 `json:"laneTime"`
+	// time spent in the pit lane with (nearly) zero speed
+	StationaryTime      float64 `json:"stationaryTime"`
+	EntryLap            int     `json:"entryLap"`
+	ExitLap             int     `json:"exitLap"`
+	DriverChanged       bool    `json:"driverChanged"`
+	TireCompoundChanged bool    `json:"tireCompoundChanged"`
+
+	driverName   string
+	tireCompound int
+	lastTime     float64
+}
+
+// startPitStop is called when the car enters the pit lane from the track
+func (cd *CarData) startPitStop() {
+	cd.pitStop = &PitStop{
+		CarIdx:       cd.carIdx,
+		CarNum:       cd.carDriverProc.GetCurrentDriver(cd.carIdx).CarNumber,
+		EntryTime:    cd.sessionTime,
+		EntryLap:     cd.lap,
+		driverName:   cd.carDriverProc.GetLatestDriverName(cd.carIdx),
+		tireCompound: cd.tireCompound,
+		lastTime:     cd.sessionTime,
+	}
+}
+
+// updatePitStop accumulates the stationary time while the car is in the pit lane
+func (cd *CarData) updatePitStop() {
+	ps := cd.pitStop
+	if ps == nil {
+		return
+	}
+	if cd.speed < PitStationarySpeed {
+		ps.StationaryTime += cd.sessionTime - ps.lastTime
+	}
+	ps.lastTime = cd.sessionTime
+}
+
+// finishPitStop is called when the car leaves the pit lane.
+// The pit stop is stored and reported.
+func (cd *CarData) finishPitStop() {
+	ps := cd.pitStop
+	if ps == nil {
+		return
+	}
+	cd.pitStop = nil
+	ps.ExitTime = cd.sessionTime
+	ps.LaneTime = ps.ExitTime - ps.EntryTime
+	ps.ExitLap = cd.lap
+	ps.DriverChanged = ps.driverName != cd.carDriverProc.GetLatestDriverName(cd.carIdx)
+	ps.TireCompoundChanged = ps.tireCompound != cd.tireCompound
+	cd.log.Debug("Pit stop done",
+		log.Float64("laneTime", ps.LaneTime),
+		log.Float64("stationaryTime", ps.StationaryTime))
+	cd.pitStops = append(cd.pitStops, ps)
+	if cd.reportPitStop != nil {
+		cd.reportPitStop(ps)
+	}
+}
+
+// PitStops returns the pit stops of the car
+func (cd *CarData) PitStops() []PitStop {
+	ret := make([]PitStop, len(cd.pitStops))
+	for i, ps := range cd.pitStops {
+		ret[i] = *ps
+	}
+	return ret
+}
+
+// PitStopList returns the pit stops of all cars ordered by entry time
+func PitStopList(stops map[int32][]PitStop) []PitStop {
+	ret := make([]PitStop, 0, len(stops))
+	for _, list := range stops {
+		ret = append(ret, list...)
+	}
+	slices.SortFunc(ret, func(a, b PitStop) int {
+		return cmp.Or(cmp.Compare(a.EntryTime, b.EntryTime), cmp.Compare(a.CarIdx, b.CarIdx))
+	})
+	return ret
+}
+
+// WritePitStopsJSON writes the pit stops as indented JSON
+func WritePitStopsJSON(w io.Writer, stops []PitStop) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(stops)
+}
+
+// WritePitStopsCSV writes one line per pit stop
+func WritePitStopsCSV(w io.Writer, stops []PitStop) error {
+	out := csv.NewWriter(w)
+	//nolint:errcheck // errors are reported by out.Error
+	out.Write([]string{
+		"carIdx", "carNum", "entryLap", "exitLap", "entryTime", "exitTime",
+		"laneTime", "stationaryTime", "driverChanged", "tireCompoundChanged",
+	})
+	ftoa := func(f float64) string { return strconv.FormatFloat(f, 'f', 3, 64) }
+	for _, ps := range stops {
+		//nolint:errcheck // errors are reported by out.Error
+		out.Write([]string{
+			strconv.Itoa(int(ps.CarIdx)), ps.CarNum, strconv.Itoa(ps.EntryLap),
+			strconv.Itoa(ps.ExitLap), ftoa(ps.EntryTime), ftoa(ps.ExitTime),
+			ftoa(ps.LaneTime), ftoa(ps.StationaryTime),
+			strconv.FormatBool(ps.DriverChanged), strconv.FormatBool(ps.TireCompoundChanged),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,57 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCarData_PitStops(t *testing.T) {
+	states, _, proc := runGoldenScenario(t, loadScenarioGenerator(t, "multiclass"),
+		WithMaxSpeed(500))
+
+	stops := proc.PitStops()
+	// stop durations and driver swaps from the scenario
+	for carIdx, want := range map[int32]struct {
+		duration float64
+		swap     bool
+	}{
+		1: {20, true},
+		2: {18, true},
+		3: {22, true},
+		4: {15, false},
+	} {
+		require.Len(t, stops[carIdx], 1, "carIdx %d", carIdx)
+		ps := stops[carIdx][0]
+		assert.InDelta(t, want.duration, ps.StationaryTime, 1, "carIdx %d", carIdx)
+		assert.Greater(t, ps.LaneTime, ps.StationaryTime, "carIdx %d", carIdx)
+		assert.InDelta(t, ps.LaneTime, ps.ExitTime-ps.EntryTime, 0.001)
+		assert.Equal(t, want.swap, ps.DriverChanged, "carIdx %d", carIdx)
+		assert.False(t, ps.TireCompoundChanged)
+		assert.NotEmpty(t, ps.CarNum)
+	}
+
+	// #31 (carIdx 4) stops without a driver change
+	msgs := messagesContaining(stateMessages(states), "#31 (Sam S) pit stop:")
+	require.Len(t, msgs, 1)
+	assert.Contains(t, msgs[0], "stationary")
+	assert.NotContains(t, msgs[0], "driver change")
+
+	list := PitStopList(stops)
+	require.Len(t, list, 4)
+	for i := 1; i < len(list); i++ {
+		assert.LessOrEqual(t, list[i-1].EntryTime, list[i].EntryTime)
+	}
+	buf := bytes.Buffer{}
+	require.NoError(t, WritePitStopsCSV(&buf, list))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, len(list)+1)
+	assert.Equal(t, "carIdx,carNum,entryLap,exitLap,entryTime,exitTime,"+
+		"laneTime,stationaryTime,driverChanged,tireCompoundChanged", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1],
+		fmt.Sprintf("%d,%s,", list[0].CarIdx, list[0].CarNum)), lines[1])
+}
@@ -8,11 +8,11 @@ import (
 	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
 	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
 	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
-	"github.com/mpapenbr/goirsdk/irsdk"
 	iryaml "github.com/mpapenbr/goirsdk/yaml"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"gopkg.in/yaml.v3"
 
+	"github.com/mpapenbr/go-racelogger/internal/clock"
 	"github.com/mpapenbr/go-racelogger/log"
 )
 
@@ -33,6 +33,14 @@ type Options struct {
 	MaxSpeed                float64 // speeds above this value (km/h) are not processed
 	GlobalProcessingData    *GlobalProcessingData
 	RecordingDoneChannel    chan struct{}
+	OvertakeFilter          OvertakeFilter
+	IncidentMessages        bool    // report incidents as race control messages
+	BlueFlagWindow          float64 // seconds a lapping car may be behind to show the blue flag (0: off)
+	BattleThreshold         float64 // max interval (seconds) between cars in a battle (0: off)
+	BattleListener          BattleListener
+	LapChartListener        LapChartListener
+	TimingListener          TimingListener
+	Clock                   clock.Clock // time source for publishing and timestamps
 	ctx                     context.Context
 }
 
@@ -41,6 +49,7 @@ func defaultOptions() *Options {
 		StatePublishInterval:    1 * time.Second,
 		SpeedmapPublishInterval: 30 * time.Second,
 		CarDataPublishInterval:  1 * time.Second,
+		Clock:                   clock.Real(),
 	}
 }
 
@@ -71,6 +80,54 @@ func WithMaxSpeed(f float64) OptionsFunc {
 	}
 }
 
+func WithOvertakeFilter(f OvertakeFilter) OptionsFunc {
+	return func(o *Options) {
+		o.OvertakeFilter = f
+	}
+}
+
+func WithIncidentMessages(b bool) OptionsFunc {
+	return func(o *Options) {
+		o.IncidentMessages = b
+	}
+}
+
+func WithBlueFlagWindow(f float64) OptionsFunc {
+	return func(o *Options) {
+		o.BlueFlagWindow = f
+	}
+}
+
+func WithBattleThreshold(f float64) OptionsFunc {
+	return func(o *Options) {
+		o.BattleThreshold = f
+	}
+}
+
+// WithBattleListener sets a listener which receives the current battles
+// whenever the state is published
+func WithBattleListener(l BattleListener) OptionsFunc {
+	return func(o *Options) {
+		o.BattleListener = l
+	}
+}
+
+// WithLapChartListener sets a listener which receives the new lap completions
+// whenever the state is published
+func WithLapChartListener(l LapChartListener) OptionsFunc {
+	return func(o *Options) {
+		o.LapChartListener = l
+	}
+}
+
+// WithTimingListener sets a listener which receives the overall and class
+// based gaps and intervals whenever the state is published
+func WithTimingListener(l TimingListener) OptionsFunc {
+	return func(o *Options) {
+		o.TimingListener = l
+	}
+}
+
 func WithCarDataPublishInterval(d time.Duration) OptionsFunc {
 	return func(o *Options) {
 		o.CarDataPublishInterval = d
@@ -95,6 +152,12 @@ func WithRecordingDoneChannel(c chan struct{}) OptionsFunc {
 	}
 }
 
+func WithClock(c clock.Clock) OptionsFunc {
+	return func(o *Options) {
+		o.Clock = c
+	}
+}
+
 func WithContext(ctx context.Context) OptionsFunc {
 	return func(o *Options) {
 		o.ctx = ctx
@@ -102,7 +165,7 @@ func WithContext(ctx context.Context) OptionsFunc {
 }
 
 type Processor struct {
-	api                  *irsdk.Irsdk
+	api                  TelemetrySource
 	options              *Options
 	lastTimeSendState    time.Time
 	lastTimeSendSpeedmap time.Time
@@ -119,12 +182,13 @@ type Processor struct {
 	extraInfoOutput      chan *racestatev1.PublishEventExtraInfoRequest
 	recording            bool
 	racing               bool
+	clock                clock.Clock
 	log                  *log.Logger
 }
 
 //nolint:whitespace,funlen // can't get different linters happy
 func NewProcessor(
-	api *irsdk.Irsdk,
+	api TelemetrySource,
 	stateOutput chan *racestatev1.PublishStateRequest,
 	speedmapOutput chan *racestatev1.PublishSpeedmapRequest,
 	cardataOutput chan *racestatev1.PublishDriverDataRequest,
@@ -137,7 +201,8 @@ func NewProcessor(
 	}
 	SetSpeedmapSpeedThreshold(opts.SpeedmapSpeedThreshold)
 	pitBoundaryProc := NewPitBoundaryProc()
-	carDriverProc := NewCarDriverProc(api, cardataOutput, opts.GlobalProcessingData)
+	carDriverProc := NewCarDriverProc(
+		api, cardataOutput, opts.GlobalProcessingData, opts.Clock)
 	messageProc := NewMessageProc(carDriverProc)
 	carDriverProc.SetReportChangeFunc(messageProc.DriverEnteredCar)
 	speedmapProc := NewSpeedmapProc(api, opts.ChunkSize, opts.GlobalProcessingData)
@@ -150,18 +215,23 @@ func NewProcessor(
 		speedmapProc,
 		messageProc,
 		opts.MaxSpeed,
+		opts.OvertakeFilter,
+		opts.IncidentMessages,
+		opts.BlueFlagWindow,
+		opts.BattleThreshold,
 	)
 	raceProc := NewRaceProc(
 		opts.ctx,
 		api,
 		carProc,
 		messageProc,
+		opts.Clock,
 		nil)
 	ret := Processor{
 		api:                  api,
 		options:              opts,
 		lastTimeSendState:    time.Time{},
-		lastTimeSendSpeedmap: time.Now(),
+		lastTimeSendSpeedmap: opts.Clock.Now(),
 		stateOutput:          stateOutput,
 		speedmapOutput:       speedmapOutput,
 		extraInfoOutput:      extraInfoOutput,
@@ -174,6 +244,7 @@ func NewProcessor(
 		pitBoundaryProc:      pitBoundaryProc,
 		recording:            true,
 		racing:               false,
+		clock:                opts.Clock,
 		log:                  log.GetFromContext(opts.ctx).Named("processor"),
 	}
 	ret.init()
@@ -183,7 +254,7 @@ func NewProcessor(
 func (p *Processor) init() {
 	p.raceProc.RaceRunCallback = func() {
 		p.racing = true
-		p.lastTimeSendSpeedmap = time.Now().Add(p.options.SpeedmapPublishInterval)
+		p.lastTimeSendSpeedmap = p.clock.Now().Add(p.options.SpeedmapPublishInterval)
 	}
 	p.raceProc.RaceDoneCallback = func() {
 		p.sendSpeedmapMessage()
@@ -213,14 +284,14 @@ func (p *Processor) init() {
 						Key: p.options.GlobalProcessingData.EventDataInfo.Key,
 					},
 				},
-				Timestamp: timestamppb.Now(),
+				Timestamp: timestamppb.New(p.clock.Now()),
 				ExtraInfo: &racestatev1.ExtraInfo{PitInfo: &pitInfo},
 			}
 			p.extraInfoOutput <- &msg
 		}
-		time.Sleep(1 * time.Second) // wait a little to get outstandig messages transmitted
-		p.recording = false         // signal recording done
-		p.racing = false            // signal racing done
+		p.clock.Sleep(1 * time.Second) // wait a little to get outstandig messages transmitted
+		p.recording = false            // signal recording done
+		p.racing = false               // signal racing done
 		if p.options.RecordingDoneChannel != nil {
 			p.log.Debug("Signaling recording done")
 			close(p.options.RecordingDoneChannel)
@@ -250,18 +321,68 @@ func (p *Processor) Process() {
 	}
 
 	if p.recording &&
-		time.Now().After(p.lastTimeSendState.Add(p.options.StatePublishInterval)) {
+		p.clock.Now().After(p.lastTimeSendState.Add(p.options.StatePublishInterval)) {
 
 		p.sendStateMessage()
 	}
 
 	if p.recording && p.racing &&
-		time.Now().After(p.lastTimeSendSpeedmap.Add(p.options.SpeedmapPublishInterval)) {
+		p.clock.Now().After(p.lastTimeSendSpeedmap.Add(p.options.SpeedmapPublishInterval)) {
 
 		p.sendSpeedmapMessage()
 	}
 }
 
+// Incidents returns the incidents of all cars by carIdx
+func (p *Processor) Incidents() map[int32][]Incident {
+	return p.carProc.Incidents()
+}
+
+// IncidentCounts returns the number of incidents of each kind by carIdx
+func (p *Processor) IncidentCounts() map[int32]map[IncidentKind]int {
+	return p.carProc.IncidentCounts()
+}
+
+// IncidentReport returns the incidents and lappings of all cars
+func (p *Processor) IncidentReport() IncidentReport {
+	return IncidentReport{
+		Counts:       p.IncidentCounts(),
+		Incidents:    p.Incidents(),
+		LappingStats: p.LappingStats(),
+		Lappings:     p.Lappings(),
+	}
+}
+
+// LapChart returns the positions of all cars at each lap completion
+func (p *Processor) LapChart() []LapChartEntry {
+	return p.carProc.LapChart()
+}
+
+// Timing returns the overall and class based gaps and intervals in race order
+func (p *Processor) Timing() []Timing {
+	return p.carProc.Timing()
+}
+
+// Battles returns the current battles
+func (p *Processor) Battles() []Battle {
+	return p.carProc.Battles()
+}
+
+// Lappings returns the completed lappings of all cars
+func (p *Processor) Lappings() []Lapping {
+	return p.carProc.Lappings()
+}
+
+// LappingStats returns the blue flag and lapping counters by carIdx
+func (p *Processor) LappingStats() map[int32]LappingStats {
+	return p.carProc.LappingStats()
+}
+
+// PitStops returns the completed pit stops of all cars by carIdx
+func (p *Processor) PitStops() map[int32][]PitStop {
+	return p.carProc.PitStops()
+}
+
 func (p *Processor) sendSpeedmapMessage() {
 	msg := racestatev1.PublishSpeedmapRequest{
 		Event: &commonv1.EventSelector{
@@ -270,11 +391,11 @@ func (p *Processor) sendSpeedmapMessage() {
 			},
 		},
 		Speedmap:  p.speedmapProc.CreatePayload(),
-		Timestamp: timestamppb.Now(),
+		Timestamp: timestamppb.New(p.clock.Now()),
 	}
 
 	p.speedmapOutput <- &msg
-	p.lastTimeSendSpeedmap = time.Now()
+	p.lastTimeSendSpeedmap = p.clock.Now()
 }
 
 func (p *Processor) sendStateMessage() {
@@ -287,10 +408,21 @@ func (p *Processor) sendStateMessage() {
 		Cars:      p.carProc.CreatePayload(),
 		Session:   p.sessionProc.CreatePayload(),
 		Messages:  p.messageProc.CreatePayload(),
-		Timestamp: timestamppb.Now(),
+		Timestamp: timestamppb.New(p.clock.Now()),
 	}
 
 	p.stateOutput <- &msg
-	p.lastTimeSendState = time.Now()
+	p.lastTimeSendState = p.clock.Now()
 	p.messageProc.Clear()
+	if p.options.BattleListener != nil {
+		p.options.BattleListener(p.carProc.Battles())
+	}
+	if p.options.LapChartListener != nil {
+		if laps := p.carProc.NewLaps(); len(laps) > 0 {
+			p.options.LapChartListener(laps)
+		}
+	}
+	if p.options.TimingListener != nil {
+		p.options.TimingListener(p.carProc.Timing())
+	}
 }
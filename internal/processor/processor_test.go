@@ -0,0 +1,52 @@
+package processor
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mpapenbr/go-racelogger/internal/clock"
+	"github.com/mpapenbr/go-racelogger/log"
+)
+
+func TestProcessor_PublishCadence(t *testing.T) {
+	g := loadScenarioGenerator(t, "sprint")
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	stateChannel := make(chan *racestatev1.PublishStateRequest, 10)
+	y := g.Source().GetLatestYaml()
+	proc := NewProcessor(g.Source(),
+		stateChannel,
+		make(chan *racestatev1.PublishSpeedmapRequest, 10),
+		make(chan *racestatev1.PublishDriverDataRequest, 10),
+		make(chan *racestatev1.PublishEventExtraInfoRequest, 10),
+		WithGlobalProcessingData(&GlobalProcessingData{
+			TrackInfo:     CreateTrackInfo(y),
+			EventDataInfo: CreateEventInfo(y, start),
+		}),
+		WithContext(log.AddToContext(context.Background(),
+			log.New(io.Discard, log.ErrorLevel))),
+		WithClock(fake),
+	)
+
+	proc.Process()
+	require.Len(t, stateChannel, 1)
+	msg := <-stateChannel
+	assert.Equal(t, start, msg.Timestamp.AsTime())
+
+	fake.Advance(500 * time.Millisecond)
+	proc.Process()
+	assert.Empty(t, stateChannel, "state published before interval elapsed")
+
+	fake.Advance(600 * time.Millisecond)
+	proc.Process()
+	require.Len(t, stateChannel, 1)
+	msg = <-stateChannel
+	assert.Equal(t, start.Add(1100*time.Millisecond), msg.Timestamp.AsTime())
+}
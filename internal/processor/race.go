@@ -5,8 +5,8 @@ import (
 	"context"
 	"time"
 
-	"github.com/mpapenbr/goirsdk/irsdk"
-
+	"github.com/mpapenbr/go-racelogger/internal/clock"
+	"github.com/mpapenbr/go-racelogger/internal/telemetry"
 	"github.com/mpapenbr/go-racelogger/log"
 )
 
@@ -17,11 +17,13 @@ type raceState interface {
 }
 
 type RaceProc struct {
-	api              *irsdk.Irsdk
+	api              TelemetrySource
 	currentState     raceState
 	cooldownEntered  time.Time
+	doneHandled      bool
 	carProc          *CarProc
 	messageProc      *MessageProc
+	clock            clock.Clock
 	RaceRunCallback  func()
 	RaceDoneCallback func()
 
@@ -39,7 +41,7 @@ func (ri *RaceInvalid) Update(rp *RaceProc) {
 	sessionNum := justValue(rp.api.GetIntValue("SessionNum")).(int32)
 	if y.SessionInfo.Sessions[sessionNum].SessionType == "Race" {
 		sessionSate := justValue(rp.api.GetIntValue("SessionState")).(int32)
-		if sessionSate == int32(irsdk.StateRacing) {
+		if sessionSate == int32(telemetry.StateRacing) {
 			rp.messageProc.RaceStarts()
 			rp.carProc.RaceStarts()
 			rp.setState(rp.stateRun)
@@ -60,7 +62,7 @@ func (rr *RaceRun) Exit()  { rr.log.Info("exit state") }
 // as long as we don't detect the checkered flag we stay in this state
 func (rr *RaceRun) Update(rp *RaceProc) {
 	sessionSate := justValue(rp.api.GetIntValue("SessionState")).(int32)
-	if sessionSate == int32(irsdk.StateCheckered) {
+	if sessionSate == int32(telemetry.StateCheckered) {
 		rp.messageProc.CheckeredFlagIssued()
 		rp.carProc.CheckeredFlagIssued()
 		rp.setState(rp.stateFinishing)
@@ -78,7 +80,7 @@ func (rf *RaceFinishing) Enter() { rf.log.Info("enter state") }
 func (rf *RaceFinishing) Exit()  { rf.log.Info("exit state") }
 func (rf *RaceFinishing) Update(rp *RaceProc) {
 	sessionSate := justValue(rp.api.GetIntValue("SessionState")).(int32)
-	if sessionSate == int32(irsdk.StateCoolDown) {
+	if sessionSate == int32(telemetry.StateCoolDown) {
 		rp.markEnterCooldown()
 		rp.setState(rp.stateCooldown)
 		return
@@ -93,7 +95,7 @@ type RaceCooldown struct {
 func (rc *RaceCooldown) Enter() { rc.log.Info("enter state") }
 func (rc *RaceCooldown) Exit()  { rc.log.Info("exist state") }
 func (rc *RaceCooldown) Update(rp *RaceProc) {
-	if time.Since(rp.cooldownEntered) > time.Second*5 {
+	if rp.clock.Since(rp.cooldownEntered) > time.Second*5 {
 		rp.messageProc.RecordingDone()
 		rp.setState(rp.stateDone)
 		return
@@ -114,9 +116,10 @@ func (rd *RaceDone) Update(rp *RaceProc) {
 //nolint:whitespace // can't get different linters happy
 func NewRaceProc(
 	ctx context.Context,
-	api *irsdk.Irsdk,
+	api TelemetrySource,
 	carProc *CarProc,
 	messageProc *MessageProc,
+	clk clock.Clock,
 	raceDoneCallback func(),
 ) *RaceProc {
 	createLogger := func(name string) *log.Logger {
@@ -126,6 +129,7 @@ func NewRaceProc(
 		api:              api,
 		carProc:          carProc,
 		messageProc:      messageProc,
+		clock:            clk,
 		RaceDoneCallback: raceDoneCallback,
 		stateInvalid:     &RaceInvalid{createLogger("invalid")},
 		stateRun:         &RaceRun{createLogger("run")},
@@ -144,10 +148,15 @@ func (rp *RaceProc) setState(s raceState) {
 }
 
 func (rp *RaceProc) markEnterCooldown() {
-	rp.cooldownEntered = time.Now()
+	rp.cooldownEntered = rp.clock.Now()
 }
 
 func (rp *RaceProc) onRaceDone() {
+	// the done state is kept until the processor is stopped
+	if rp.doneHandled {
+		return
+	}
+	rp.doneHandled = true
 	// if handler registered, do something with it
 	if rp.RaceDoneCallback != nil {
 		rp.RaceDoneCallback()
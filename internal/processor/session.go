@@ -2,14 +2,13 @@ package processor
 
 import (
 	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
-	"github.com/mpapenbr/goirsdk/irsdk"
 )
 
 type SessionProc struct {
-	api *irsdk.Irsdk
+	api TelemetrySource
 }
 
-func NewSessionProc(api *irsdk.Irsdk) *SessionProc {
+func NewSessionProc(api TelemetrySource) *SessionProc {
 	return &SessionProc{api: api}
 }
 
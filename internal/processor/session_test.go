@@ -0,0 +1,40 @@
+package processor
+
+import (
+	"testing"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpapenbr/go-racelogger/internal/telemetry"
+)
+
+func TestSessionProc_CreatePayload(t *testing.T) {
+	api := telemetry.NewMemory()
+	api.SetValues(map[string]any{
+		"SessionNum":          int32(2),
+		"SessionTime":         float64(120.5),
+		"SessionTimeRemain":   float64(600),
+		"SessionLapsRemainEx": int32(10),
+		"SessionTimeOfDay":    float32(43200),
+		"AirTemp":             float32(20),
+		"AirDensity":          float32(1.2),
+		"AirPressure":         float32(29.9),
+		"TrackTempCrew":       float32(30),
+		"WindDir":             float32(1.5),
+		"WindVel":             float32(3),
+		"TrackWetness":        int32(telemetry.TrackWetnessDry),
+		"Precipitation":       float32(0),
+		"SessionState":        int32(telemetry.StateRacing),
+		"SessionFlags":        int32(telemetry.FlagGreen),
+	})
+	got := NewSessionProc(api).CreatePayload()
+	assert.Equal(t, uint32(2), got.SessionNum)
+	assert.Equal(t, float32(120.5), got.SessionTime)
+	assert.Equal(t, int32(10), got.LapsRemain)
+	assert.Equal(t, uint32(43200), got.TimeOfDay)
+	assert.Equal(t, float32(30), got.TrackTemp)
+	assert.Equal(t, commonv1.TrackWetness_TRACK_WETNESS_DRY, got.TrackWetness)
+	assert.Equal(t, GREEN, got.FlagState)
+	assert.Equal(t, int32(telemetry.StateRacing), got.SessionStateRaw)
+}
@@ -6,7 +6,6 @@ import (
 	"slices"
 
 	speedmapv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/speedmap/v1"
-	"github.com/mpapenbr/goirsdk/irsdk"
 	"github.com/samber/lo"
 
 	"github.com/mpapenbr/go-racelogger/log"
@@ -75,7 +74,7 @@ func (p *ChunkData) compute() {
 // It is used by the Processor struct.
 
 type SpeedmapProc struct {
-	api            *irsdk.Irsdk
+	api            TelemetrySource
 	chunkSize      int
 	gpd            *GlobalProcessingData
 	numChunks      int
@@ -91,7 +90,7 @@ func SetSpeedmapSpeedThreshold(pct float64) {
 
 //nolint:whitespace // can't get different linters happy
 func NewSpeedmapProc(
-	api *irsdk.Irsdk,
+	api TelemetrySource,
 	chunkSize int,
 	gpd *GlobalProcessingData,
 ) *SpeedmapProc {
@@ -210,6 +209,16 @@ func (s *SpeedmapProc) CreateOutput() map[string]*speedmapv1.ClassData {
 	return ret
 }
 
+// ClassLaptime returns the lap time of a car class computed by the speedmap.
+// 0 is returned if there is not enough data yet.
+func (s *SpeedmapProc) ClassLaptime(carClassID int) float64 {
+	chunks, ok := s.carClassLookup[carClassID]
+	if !ok {
+		return 0
+	}
+	return s.computeLaptime(chunks)
+}
+
 func (s *SpeedmapProc) computeLaptime(chunks []*ChunkData) float64 {
 	if !s.hasValidAvgs(chunks) {
 		return 0
@@ -0,0 +1,20 @@
+package processor
+
+import (
+	"github.com/mpapenbr/goirsdk/yaml"
+)
+
+// TelemetrySource provides the telemetry values and yaml data the processor reads.
+// irsdk.Irsdk is the implementation used for live recordings.
+// For tests and offline processing see telemetry.Memory.
+type TelemetrySource interface {
+	GetValue(name string) (any, error)
+	GetIntValue(name string) (int32, error)
+	GetIntValues(name string) ([]int32, error)
+	GetFloatValue(name string) (float32, error)
+	GetFloatValues(name string) ([]float32, error)
+	GetDoubleValue(name string) (float64, error)
+	GetLatestYaml() *yaml.IrsdkYaml
+	GetYamlString() string
+	RepairedYaml(s string) string
+}
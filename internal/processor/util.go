@@ -10,9 +10,9 @@ import (
 
 	carv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/car/v1"
 	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
-	"github.com/mpapenbr/goirsdk/irsdk"
 	"github.com/mpapenbr/goirsdk/yaml"
 
+	"github.com/mpapenbr/go-racelogger/internal/telemetry"
 	"github.com/mpapenbr/go-racelogger/log"
 )
 
@@ -32,7 +32,7 @@ func justValue(v any, _ error) any {
 	return v
 }
 
-func getRaceState(api *irsdk.Irsdk) string {
+func getRaceState(api TelemetrySource) string {
 	state, _ := api.GetIntValue("SessionState")
 	flags, _ := api.GetIntValue("SessionFlags")
 	return computeFlagState(state, int64(flags))
@@ -44,26 +44,26 @@ func isBitSet(v, bit int64) bool {
 
 //nolint:cyclop,gocritic,nestif // ok this way
 func computeFlagState(state int32, flags int64) string {
-	if state == int32(irsdk.StateRacing) {
-		if isBitSet(flags, int64(irsdk.FlagStartGo)) {
+	if state == int32(telemetry.StateRacing) {
+		if isBitSet(flags, int64(telemetry.FlagStartGo)) {
 			return GREEN
-		} else if isBitSet(flags, int64(irsdk.FlagStartHidden)) {
-			if isBitSet(flags, int64(irsdk.FlagCaution)) ||
-				isBitSet(flags, int64(irsdk.FlagCautionWaving)) {
+		} else if isBitSet(flags, int64(telemetry.FlagStartHidden)) {
+			if isBitSet(flags, int64(telemetry.FlagCaution)) ||
+				isBitSet(flags, int64(telemetry.FlagCautionWaving)) {
 
 				return YELLOW
 			}
 		}
 		return GREEN
-	} else if state == int32(irsdk.StateCheckered) {
+	} else if state == int32(telemetry.StateCheckered) {
 		return CHECKERED
-	} else if state == int32(irsdk.StateCoolDown) {
+	} else if state == int32(telemetry.StateCoolDown) {
 		return CHECKERED
-	} else if state == int32(irsdk.StateGetInCar) {
+	} else if state == int32(telemetry.StateGetInCar) {
 		return PREP
-	} else if state == int32(irsdk.StateParadeLaps) {
+	} else if state == int32(telemetry.StateParadeLaps) {
 		return PARADE
-	} else if state == int32(irsdk.StateInvalid) {
+	} else if state == int32(telemetry.StateInvalid) {
 		return INVALID
 	}
 	return "NONE"
@@ -72,7 +72,7 @@ func computeFlagState(state int32, flags int64) string {
 // returns true if we should record data
 //
 //nolint:goconst // by design
-func shouldRecord(api *irsdk.Irsdk) bool {
+func shouldRecord(api TelemetrySource) bool {
 	return slices.Contains([]string{"GREEN", "YELLOW", "CHECKERED"}, getRaceState(api))
 }
 
@@ -242,7 +242,7 @@ func HasDriverChange(current, last *yaml.DriverInfo) bool {
 	return changeDetected
 }
 
-func readUint32(api *irsdk.Irsdk, key string) uint32 {
+func readUint32(api TelemetrySource, key string) uint32 {
 	val, err := api.GetIntValue(key)
 	if err != nil {
 		log.Error("error reading var", log.String("key", key), log.ErrorField(err))
@@ -250,7 +250,7 @@ func readUint32(api *irsdk.Irsdk, key string) uint32 {
 	return uint32(val)
 }
 
-func readInt32(api *irsdk.Irsdk, key string) int32 {
+func readInt32(api TelemetrySource, key string) int32 {
 	val, err := api.GetIntValue(key)
 	if err != nil {
 		log.Error("error reading var", log.String("key", key), log.ErrorField(err))
@@ -258,7 +258,7 @@ func readInt32(api *irsdk.Irsdk, key string) int32 {
 	return val
 }
 
-func readFloat32(api *irsdk.Irsdk, key string) float32 {
+func readFloat32(api TelemetrySource, key string) float32 {
 	val, err := api.GetFloatValue(key)
 	if err != nil {
 		log.Error("error reading var", log.String("key", key), log.ErrorField(err))
@@ -266,7 +266,7 @@ func readFloat32(api *irsdk.Irsdk, key string) float32 {
 	return val
 }
 
-func readFloat64(api *irsdk.Irsdk, key string) float64 {
+func readFloat64(api TelemetrySource, key string) float64 {
 	val, err := api.GetDoubleValue(key)
 	if err != nil {
 		log.Error("error reading var", log.String("key", key), log.ErrorField(err))
@@ -274,24 +274,24 @@ func readFloat64(api *irsdk.Irsdk, key string) float64 {
 	return val
 }
 
-func convertTrackWetness(api *irsdk.Irsdk) commonv1.TrackWetness {
+func convertTrackWetness(api TelemetrySource) commonv1.TrackWetness {
 	val, _ := api.GetIntValue("TrackWetness")
 	switch val {
-	case irsdk.TrackWetnessUnknown:
+	case telemetry.TrackWetnessUnknown:
 		return commonv1.TrackWetness_TRACK_WETNESS_UNSPECIFIED
-	case irsdk.TrackWetnessDry:
+	case telemetry.TrackWetnessDry:
 		return commonv1.TrackWetness_TRACK_WETNESS_DRY
-	case irsdk.TrackWetnessMostlyDry:
+	case telemetry.TrackWetnessMostlyDry:
 		return commonv1.TrackWetness_TRACK_WETNESS_MOSTLY_DRY
-	case irsdk.TrackWetnessVeryLightlyWet:
+	case telemetry.TrackWetnessVeryLightlyWet:
 		return commonv1.TrackWetness_TRACK_WETNESS_VERY_LIGHTLY_WET
-	case irsdk.TrackWetnessLightlyWet:
+	case telemetry.TrackWetnessLightlyWet:
 		return commonv1.TrackWetness_TRACK_WETNESS_LIGHTLY_WET
-	case irsdk.TrackWetnessModeratelyWet:
+	case telemetry.TrackWetnessModeratelyWet:
 		return commonv1.TrackWetness_TRACK_WETNESS_MODERATELY_WET
-	case irsdk.TrackWetnessVeryWet:
+	case telemetry.TrackWetnessVeryWet:
 		return commonv1.TrackWetness_TRACK_WETNESS_VERY_WET
-	case irsdk.TrackWetnessExtremeWet:
+	case telemetry.TrackWetnessExtremeWet:
 		return commonv1.TrackWetness_TRACK_WETNESS_EXTREMELY_WET
 	}
 	return commonv1.TrackWetness_TRACK_WETNESS_UNSPECIFIED
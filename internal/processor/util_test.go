@@ -4,9 +4,10 @@ package processor
 import (
 	"testing"
 
-	"github.com/mpapenbr/goirsdk/irsdk"
 	iryaml "github.com/mpapenbr/goirsdk/yaml"
 	"gopkg.in/yaml.v3"
+
+	"github.com/mpapenbr/go-racelogger/internal/telemetry"
 )
 
 func TestGetMetricUnit(t *testing.T) {
@@ -149,15 +150,15 @@ func Test_computeFlagState(t *testing.T) {
 		args args
 		want string
 	}{
-		{"prep", args{int32(irsdk.StateGetInCar), 0}, "PREP"},
-		{"parade", args{int32(irsdk.StateParadeLaps), 0}, "PARADE"},
-		{"parade (1 to green)", args{int32(irsdk.StateParadeLaps), 0x80000604}, "PARADE"},
-		{"green (switch state,keep flags)", args{int32(irsdk.StateRacing), 0x80000604}, "GREEN"},
-		{"green (state + green flag)", args{int32(irsdk.StateRacing), 0x80000004}, "GREEN"},
-		{"green (no extra flag)", args{int32(irsdk.StateRacing), int64(0x10000000)}, "GREEN"},
-		{"green (green flag)", args{int32(irsdk.StateRacing), int64(0x10000004)}, "GREEN"},
-		{"yellow (caution waving)", args{int32(irsdk.StateRacing), int64(0x10008000)}, "YELLOW"},
-		{"yellow (caution)", args{int32(irsdk.StateRacing), int64(0x10004000)}, "YELLOW"},
+		{"prep", args{int32(telemetry.StateGetInCar), 0}, "PREP"},
+		{"parade", args{int32(telemetry.StateParadeLaps), 0}, "PARADE"},
+		{"parade (1 to green)", args{int32(telemetry.StateParadeLaps), 0x80000604}, "PARADE"},
+		{"green (switch state,keep flags)", args{int32(telemetry.StateRacing), 0x80000604}, "GREEN"},
+		{"green (state + green flag)", args{int32(telemetry.StateRacing), 0x80000004}, "GREEN"},
+		{"green (no extra flag)", args{int32(telemetry.StateRacing), int64(0x10000000)}, "GREEN"},
+		{"green (green flag)", args{int32(telemetry.StateRacing), int64(0x10000004)}, "GREEN"},
+		{"yellow (caution waving)", args{int32(telemetry.StateRacing), int64(0x10008000)}, "YELLOW"},
+		{"yellow (caution)", args{int32(telemetry.StateRacing), int64(0x10004000)}, "YELLOW"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,674 @@
+// Package racegen generates telemetry data for a synthetic race.
+// The race is described by a Scenario (see scenario.go).
+// The generated data is provided by a telemetry.Memory which can be used
+// as processor.TelemetrySource.
+package racegen
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+
+	"github.com/mpapenbr/goirsdk/yaml"
+
+	"github.com/mpapenbr/go-racelogger/internal/telemetry"
+)
+
+const (
+	maxCars           = 64
+	startFlagDuration = 5.0 // seconds the start flag (green) is shown
+	offTrackFactor    = 0.2 // speed factor while off track
+	spinSpeed         = 5.0 // speed (m/s) while spinning backwards
+)
+
+type carPhase int
+
+const (
+	phaseRun          carPhase = iota
+	phasePitIn                 // on pit road, driving to the pit stall
+	phasePitStop               // standing in the pit stall
+	phasePitOut                // on pit road, driving to the pit exit
+	phaseOffTrack              // off track, losing time
+	phaseSpin                  // spinning, moving backwards
+	phaseStopped               // standing on track
+	phaseDisconnected          // not in world, will come back
+	phaseOut                   // not in world, will not come back
+)
+
+type carSim struct {
+	cfg          *Car
+	class        *Class
+	grid         int
+	driver       int       // index of current driver in cfg.Drivers
+	dist         float64   // distance in laps, the start/finish line is at 0
+	lapTime      float64   // target lap time of the current lap
+	phase        carPhase  //
+	until        float64   // session time when the current timed phase ends
+	stallDist    float64   // pit stop: dist of the pit stall
+	exitDist     float64   // pit stop: dist of the pit exit
+	stopDuration float64   // pit stop: duration of the stop
+	swapDriver   bool      // pit stop: next driver takes over
+	events       []*Event  // pending events of this car
+	crossings    []float64 // session time of line crossings (first one is the start)
+	lastLap      float64
+	bestLap      float64
+	bestLapNum   int
+	finished     bool
+	pos          int32
+	classPos     int32
+}
+
+func (c *carSim) lap() int32 {
+	return int32(math.Floor(c.dist)) + 1
+}
+
+func (c *carSim) trackPos() float64 {
+	return c.dist - math.Floor(c.dist)
+}
+
+func (c *carSim) lapsCompleted() int {
+	return len(c.crossings) - 1
+}
+
+func (c *carSim) inWorld() bool {
+	return c.phase != phaseDisconnected && c.phase != phaseOut
+}
+
+func (c *carSim) onPitRoad() bool {
+	return c.phase == phasePitIn || c.phase == phasePitStop || c.phase == phasePitOut
+}
+
+// Generator produces the telemetry data tick by tick
+type Generator struct {
+	sc              *Scenario
+	rnd             *rand.Rand
+	src             *telemetry.Memory
+	cars            []*carSim // ordered by carIdx
+	cautions        []*Event
+	tick            int
+	time            float64
+	state           telemetry.SessionState
+	raceStart       float64
+	checkeredTime   float64
+	cooldownStart   float64
+	finishOrder     []int
+	yamlChanged     bool
+	done            bool
+	classesByID     map[int]*Class
+	standingsActive bool
+}
+
+// NewGenerator creates a generator for the scenario.
+// The source already contains the data of the first tick
+func NewGenerator(sc *Scenario) (*Generator, error) {
+	g := &Generator{
+		sc:          sc,
+		rnd:         rand.New(rand.NewPCG(sc.Seed, sc.Seed)), //nolint:gosec // no crypto
+		src:         telemetry.NewMemory(),
+		state:       telemetry.StateGetInCar,
+		classesByID: make(map[int]*Class),
+		yamlChanged: true,
+	}
+	for i := range sc.Classes {
+		g.classesByID[sc.Classes[i].ID] = &sc.Classes[i]
+	}
+	for i := range sc.Cars {
+		c := &carSim{
+			cfg:     &sc.Cars[i],
+			class:   g.classesByID[sc.Cars[i].Class],
+			grid:    i,
+			dist:    -float64(i+1) * sc.Race.GridSpacing,
+			bestLap: -1,
+			lastLap: -1,
+			pos:     int32(i + 1),
+		}
+		c.lapTime = g.sampleLapTime(c)
+		g.cars = append(g.cars, c)
+	}
+	g.computeClassPositions()
+	slices.SortFunc(g.cars, func(a, b *carSim) int {
+		return cmp.Compare(a.cfg.CarIdx, b.cfg.CarIdx)
+	})
+	carLookup := make(map[int]*carSim)
+	for _, c := range g.cars {
+		carLookup[c.cfg.CarIdx] = c
+	}
+	for i := range sc.Events {
+		e := &sc.Events[i]
+		if e.Type == EventCaution {
+			g.cautions = append(g.cautions, e)
+		} else {
+			carLookup[e.CarIdx].events = append(carLookup[e.CarIdx].events, e)
+		}
+	}
+	for _, c := range g.cars {
+		slices.SortStableFunc(c.events, func(a, b *Event) int {
+			return cmp.Compare(g.triggerDist(a), g.triggerDist(b))
+		})
+	}
+	if err := g.publish(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Source returns the telemetry source containing the data of the current tick
+func (g *Generator) Source() *telemetry.Memory {
+	return g.src
+}
+
+// SessionTime returns the session time of the current tick
+func (g *Generator) SessionTime() float64 {
+	return g.time
+}
+
+// TickRate returns the number of ticks per second
+func (g *Generator) TickRate() int {
+	return g.sc.TickRate
+}
+
+// FinishOrder returns the carIdx of the cars in the order they took the checkered flag
+func (g *Generator) FinishOrder() []int {
+	return g.finishOrder
+}
+
+// Step advances the race by one tick.
+// Returns false if the race is over (no new data was produced)
+func (g *Generator) Step() (bool, error) {
+	if g.done {
+		return false, nil
+	}
+	g.tick++
+	dt := 1 / float64(g.sc.TickRate)
+	g.time = float64(g.tick) / float64(g.sc.TickRate)
+
+	switch g.state { //nolint:exhaustive // only some states need handling
+	case telemetry.StateGetInCar:
+		if g.time >= g.sc.Race.StartDelay {
+			g.state = telemetry.StateRacing
+			g.raceStart = g.time
+		}
+	case telemetry.StateCoolDown:
+		if g.time >= g.cooldownStart+g.sc.Race.Cooldown {
+			g.done = true
+			return false, nil
+		}
+	}
+
+	if g.state != telemetry.StateGetInCar {
+		crossed := false
+		for _, c := range g.cars {
+			if g.advance(c, dt) {
+				crossed = true
+			}
+		}
+		if crossed {
+			g.computePositions()
+			g.yamlChanged = true
+		}
+	}
+
+	if g.state == telemetry.StateCheckered &&
+		(g.allFinished() || g.time > g.checkeredTime+g.maxFinishWait()) {
+
+		g.state = telemetry.StateCoolDown
+		g.cooldownStart = g.time
+	}
+	return true, g.publish()
+}
+
+// advance moves the car for dt seconds. Returns true if the car crossed the line
+//
+//nolint:cyclop // by design
+func (g *Generator) advance(c *carSim, dt float64) bool {
+	switch c.phase { //nolint:exhaustive // only timed phases need handling
+	case phaseOut:
+		return false
+	case phaseDisconnected:
+		if g.time < c.until {
+			return false
+		}
+		c.phase = phaseRun
+	case phasePitStop:
+		if g.time < c.until {
+			return false
+		}
+		c.phase = phasePitOut
+	case phaseOffTrack, phaseSpin, phaseStopped:
+		if g.time >= c.until {
+			c.phase = phaseRun
+		}
+	}
+
+	newDist := c.dist + g.speed(c)*dt
+	switch c.phase { //nolint:exhaustive // only some phases need handling
+	case phasePitIn:
+		if newDist >= c.stallDist {
+			newDist = c.stallDist
+			c.phase = phasePitStop
+			c.until = g.time + c.stopDuration
+			if c.swapDriver && len(c.cfg.Drivers) > 1 {
+				c.driver = (c.driver + 1) % len(c.cfg.Drivers)
+				g.yamlChanged = true
+			}
+		}
+	case phasePitOut:
+		if newDist >= c.exitDist {
+			c.phase = phaseRun
+		}
+	case phaseRun:
+		if e := g.nextEvent(c); e != nil {
+			trigger := g.triggerDist(e)
+			if newDist >= trigger {
+				newDist = trigger
+				g.startEvent(c, e)
+			}
+		}
+	}
+
+	oldLap := math.Floor(c.dist)
+	c.dist = newDist
+	if math.Floor(newDist) > oldLap {
+		return g.crossLine(c)
+	}
+	return false
+}
+
+// nextEvent returns the next pending event of the car.
+// Events which can't be triggered anymore are discarded
+func (g *Generator) nextEvent(c *carSim) *Event {
+	if c.finished {
+		return nil
+	}
+	for len(c.events) > 0 {
+		if g.triggerDist(c.events[0]) >= c.dist {
+			return c.events[0]
+		}
+		c.events = c.events[1:]
+	}
+	return nil
+}
+
+func (g *Generator) triggerDist(e *Event) float64 {
+	pos := e.TrackPos
+	if e.Type == EventPit {
+		pos = g.sc.Track.PitEntry
+	}
+	return float64(e.Lap-1) + pos
+}
+
+func (g *Generator) startEvent(c *carSim, e *Event) {
+	c.events = c.events[1:]
+	switch e.Type {
+	case EventPit:
+		entry := g.triggerDist(e)
+		c.phase = phasePitIn
+		c.stallDist = entry + deltaPct(g.sc.Track.PitStall, g.sc.Track.PitEntry)
+		c.exitDist = entry + deltaPct(g.sc.Track.PitExit, g.sc.Track.PitEntry)
+		c.stopDuration = e.Duration
+		c.swapDriver = e.DriverSwap
+	case EventOffTrack:
+		c.phase = phaseOffTrack
+		c.until = g.time + e.Duration
+	case EventSpin:
+		c.phase = phaseSpin
+		c.until = g.time + e.Duration
+	case EventStop:
+		c.phase = phaseStopped
+		c.until = g.time + e.Duration
+	case EventDisconnect:
+		if e.Duration > 0 {
+			c.phase = phaseDisconnected
+			c.until = g.time + e.Duration
+		} else {
+			c.phase = phaseOut
+		}
+	}
+}
+
+// returns the speed of the car in laps per second
+func (g *Generator) speed(c *carSim) float64 {
+	switch c.phase { //nolint:exhaustive // other phases use the regular speed
+	case phasePitIn, phasePitOut:
+		return g.sc.Track.PitSpeed / 3.6 / g.sc.Track.Length
+	case phaseOffTrack:
+		return offTrackFactor / c.lapTime
+	case phaseSpin:
+		return -spinSpeed / g.sc.Track.Length
+	case phaseStopped:
+		return 0
+	}
+	if c.finished || g.cautionActive() {
+		return 1 / (c.class.LapTime * g.sc.Race.CautionFactor)
+	}
+	return 1 / c.lapTime
+}
+
+// crossLine handles the line crossing of a car.
+// Returns true if the standings changed
+func (g *Generator) crossLine(c *carSim) bool {
+	if c.finished {
+		return false
+	}
+	c.crossings = append(c.crossings, g.time)
+	if n := len(c.crossings); n > 1 {
+		c.lastLap = c.crossings[n-1] - c.crossings[n-2]
+		if c.bestLap < 0 || c.lastLap < c.bestLap {
+			c.bestLap = c.lastLap
+			c.bestLapNum = c.lapsCompleted()
+		}
+		g.standingsActive = true
+	}
+	c.lapTime = g.sampleLapTime(c)
+
+	switch g.state { //nolint:exhaustive // only racing and checkered are relevant
+	case telemetry.StateRacing:
+		if c.lapsCompleted() >= g.sc.Race.Laps {
+			g.state = telemetry.StateCheckered
+			g.checkeredTime = g.time
+			c.finished = true
+			g.finishOrder = append(g.finishOrder, c.cfg.CarIdx)
+		}
+	case telemetry.StateCheckered:
+		c.finished = true
+		g.finishOrder = append(g.finishOrder, c.cfg.CarIdx)
+	}
+	return true
+}
+
+func (g *Generator) sampleLapTime(c *carSim) float64 {
+	base := c.class.LapTime + c.cfg.LapTimeOffset
+	return math.Max(base+c.class.Noise*g.rnd.NormFloat64(), base*0.5)
+}
+
+func (g *Generator) cautionActive() bool {
+	if g.state != telemetry.StateRacing {
+		return false
+	}
+	leaderLap := int32(0)
+	for _, c := range g.cars {
+		if c.phase != phaseOut {
+			leaderLap = max(leaderLap, c.lap())
+		}
+	}
+	for _, e := range g.cautions {
+		if leaderLap >= int32(e.Lap) && leaderLap < int32(e.Lap+e.Laps) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Generator) allFinished() bool {
+	for _, c := range g.cars {
+		if !c.finished && c.phase != phaseOut {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Generator) maxFinishWait() float64 {
+	ret := 0.0
+	for i := range g.sc.Classes {
+		ret = max(ret, g.sc.Classes[i].LapTime)
+	}
+	return 3 * ret * g.sc.Race.CautionFactor
+}
+
+// computePositions orders the cars by laps completed and time of crossing the line
+func (g *Generator) computePositions() {
+	work := slices.Clone(g.cars)
+	slices.SortStableFunc(work, func(a, b *carSim) int {
+		if a.lapsCompleted() != b.lapsCompleted() {
+			return cmp.Compare(b.lapsCompleted(), a.lapsCompleted())
+		}
+		if a.lapsCompleted() >= 0 {
+			la, lb := a.crossings[a.lapsCompleted()], b.crossings[b.lapsCompleted()]
+			if la != lb {
+				return cmp.Compare(la, lb)
+			}
+		}
+		return cmp.Compare(a.grid, b.grid)
+	})
+	for i, c := range work {
+		c.pos = int32(i + 1)
+	}
+	g.computeClassPositions()
+}
+
+func (g *Generator) computeClassPositions() {
+	work := slices.Clone(g.cars)
+	slices.SortFunc(work, func(a, b *carSim) int { return cmp.Compare(a.pos, b.pos) })
+	classPos := make(map[int]int32)
+	for _, c := range work {
+		classPos[c.class.ID]++
+		c.classPos = classPos[c.class.ID]
+	}
+}
+
+func (g *Generator) flags() telemetry.Flags {
+	switch g.state { //nolint:exhaustive // other states use no flags
+	case telemetry.StateGetInCar:
+		return telemetry.FlagStartReady
+	case telemetry.StateRacing:
+		if g.time-g.raceStart < startFlagDuration {
+			return telemetry.FlagStartGo | telemetry.FlagGreen
+		}
+		if g.cautionActive() {
+			return telemetry.FlagStartHidden | telemetry.FlagCaution
+		}
+		return telemetry.FlagStartHidden | telemetry.FlagGreen
+	case telemetry.StateCheckered, telemetry.StateCoolDown:
+		return telemetry.FlagStartHidden | telemetry.FlagCheckered
+	}
+	return 0
+}
+
+func (g *Generator) publish() error {
+	lapDistPct := make([]float32, maxCars)
+	trackSurface := make([]int32, maxCars)
+	position := make([]int32, maxCars)
+	classPosition := make([]int32, maxCars)
+	lap := make([]int32, maxCars)
+	lapCompleted := make([]int32, maxCars)
+	onPitRoad := make([]bool, maxCars)
+	tireCompound := make([]int32, maxCars)
+	for i := range maxCars {
+		lapDistPct[i] = -1
+		trackSurface[i] = int32(telemetry.TrackLocationNotInWorld)
+		lap[i] = -1
+		lapCompleted[i] = -1
+	}
+	leaderLapsCompleted := -1
+	for _, c := range g.cars {
+		idx := c.cfg.CarIdx
+		position[idx] = c.pos
+		classPosition[idx] = c.classPos
+		lapCompleted[idx] = int32(c.lapsCompleted())
+		leaderLapsCompleted = max(leaderLapsCompleted, c.lapsCompleted())
+		if !c.inWorld() {
+			continue
+		}
+		lapDistPct[idx] = float32(c.trackPos())
+		lap[idx] = c.lap()
+		onPitRoad[idx] = c.onPitRoad()
+		switch c.phase { //nolint:exhaustive // other phases are on track
+		case phasePitStop:
+			trackSurface[idx] = int32(telemetry.TrackLocationInPitStall)
+		case phasePitIn, phasePitOut:
+			trackSurface[idx] = int32(telemetry.TrackLocationAproachingPits)
+		case phaseOffTrack:
+			trackSurface[idx] = int32(telemetry.TrackLocationOffTrack)
+		default:
+			trackSurface[idx] = int32(telemetry.TrackLocationOnTrack)
+		}
+	}
+	w := g.sc.Weather
+	flags := g.flags()
+	g.src.SetValues(map[string]any{
+		"SessionNum":          int32(0),
+		"SessionTime":         g.time,
+		"SessionTimeRemain":   float64(604800),
+		"SessionLapsRemainEx": int32(max(g.sc.Race.Laps-leaderLapsCompleted, 0)),
+		"SessionTimeOfDay":    w.TimeOfDay + float32(g.time),
+		"SessionState":        int32(g.state),
+		"SessionFlags":        int32(uint32(flags)), //nolint:gosec // bitfield
+		"AirTemp":             w.AirTemp,
+		"AirDensity":          w.AirDensity,
+		"AirPressure":         w.AirPressure,
+		"TrackTempCrew":       w.TrackTemp,
+		"WindDir":             w.WindDir,
+		"WindVel":             w.WindVel,
+		"TrackWetness":        w.TrackWetness,
+		"Precipitation":       w.Precipitation,
+		"CarIdxLapDistPct":    lapDistPct,
+		"CarIdxTrackSurface":  trackSurface,
+		"CarIdxPosition":      position,
+		"CarIdxClassPosition": classPosition,
+		"CarIdxLap":           lap,
+		"CarIdxLapCompleted":  lapCompleted,
+		"CarIdxOnPitRoad":     onPitRoad,
+		"CarIdxTireCompound":  tireCompound,
+	})
+	if g.yamlChanged {
+		g.yamlChanged = false
+		return g.src.SetYaml(g.createYaml())
+	}
+	return nil
+}
+
+// createYaml creates a fresh yaml structure (no shared slices with previous calls)
+func (g *Generator) createYaml() *yaml.IrsdkYaml {
+	carIDs := map[int]bool{}
+	classIDs := map[int]bool{}
+	teamRacing := 0
+	drivers := make([]yaml.Drivers, 0, len(g.cars))
+	for _, c := range g.cars {
+		carIDs[c.class.CarID] = true
+		classIDs[c.class.ID] = true
+		if len(c.cfg.Drivers) > 1 {
+			teamRacing = 1
+		}
+		drivers = append(drivers, g.createDriver(c))
+	}
+	sectors := make([]yaml.Sectors, len(g.sc.Track.Sectors))
+	for i, s := range g.sc.Track.Sectors {
+		sectors[i] = yaml.Sectors{SectorNum: i, SectorStartPct: s}
+	}
+	ret := &yaml.IrsdkYaml{
+		WeekendInfo: yaml.WeekendInfo{
+			TrackID:               g.sc.Track.ID,
+			TrackName:             g.sc.Track.Name,
+			TrackDisplayName:      g.sc.Track.Name,
+			TrackDisplayShortName: g.sc.Track.Name,
+			TrackLength:           fmt.Sprintf("%.3f km", g.sc.Track.Length/1000),
+			TrackPitSpeedLimit:    fmt.Sprintf("%.2f kph", g.sc.Track.PitSpeed),
+			NumCarClasses:         len(classIDs),
+			NumCarTypes:           len(carIDs),
+			TeamRacing:            teamRacing,
+			SessionID:             1,
+			SubSessionID:          1,
+		},
+		SessionInfo: yaml.SessionInfo{
+			Sessions: []yaml.Sessions{
+				{
+					SessionNum:       0,
+					SessionName:      "RACE",
+					SessionType:      "Race",
+					SessionLaps:      strconv.Itoa(g.sc.Race.Laps),
+					SessionTime:      "unlimited",
+					ResultsPositions: g.createResultsPositions(),
+				},
+			},
+		},
+		SplitTimeInfo: yaml.SplitTimeInfo{Sectors: sectors},
+		DriverInfo:    yaml.DriverInfo{Drivers: drivers},
+	}
+	return ret
+}
+
+func (g *Generator) createDriver(c *carSim) yaml.Drivers {
+	name := c.cfg.Drivers[c.driver]
+	team := c.cfg.Team
+	if team == "" {
+		team = name
+	}
+	carNumberRaw, _ := strconv.Atoi(c.cfg.Number)
+	return yaml.Drivers{
+		CarIdx:                  c.cfg.CarIdx,
+		UserName:                name,
+		AbbrevName:              name,
+		UserID:                  c.cfg.CarIdx*100 + c.driver + 1,
+		TeamID:                  c.cfg.CarIdx,
+		TeamName:                team,
+		CarNumber:               c.cfg.Number,
+		CarNumberRaw:            carNumberRaw,
+		CarID:                   c.class.CarID,
+		CarClassID:              c.class.ID,
+		CarScreenName:           c.class.Car,
+		CarScreenNameShort:      c.class.Car,
+		CarClassShortName:       c.class.Name,
+		CarClassEstLapTime:      c.class.LapTime,
+		CarClassMaxFuelPct:      "1.000 %",
+		CarClassPowerAdjust:     "0.000 %",
+		CarClassWeightPenalty:   "0.000 kg",
+		CarClassDryTireSetLimit: "0 %",
+		LicString:               "A 4.99",
+		IRating:                 2000,
+	}
+}
+
+// createResultsPositions creates the standings.
+// Standings are available after the first lap was completed by any car.
+// Like iRacing the class position is 0-based after the checkered flag.
+func (g *Generator) createResultsPositions() []yaml.ResultsPositions {
+	if !g.standingsActive {
+		return nil
+	}
+	work := slices.Clone(g.cars)
+	slices.SortFunc(work, func(a, b *carSim) int { return cmp.Compare(a.pos, b.pos) })
+	leader := work[0]
+	classPosOffset := 0
+	if g.state == telemetry.StateCheckered || g.state == telemetry.StateCoolDown {
+		classPosOffset = 1
+	}
+	ret := make([]yaml.ResultsPositions, len(work))
+	for i, c := range work {
+		lc := c.lapsCompleted()
+		gap := 0.0
+		if lc >= 0 && lc < len(leader.crossings) {
+			gap = c.crossings[lc] - leader.crossings[lc]
+		}
+		reasonOut := "Running"
+		reasonOutID := 0
+		if c.phase == phaseOut {
+			reasonOut = "Disconnected"
+			reasonOutID = 32
+		}
+		ret[i] = yaml.ResultsPositions{
+			Position:      int(c.pos),
+			ClassPosition: int(c.classPos) - classPosOffset,
+			CarIdx:        c.cfg.CarIdx,
+			Lap:           max(lc, 0),
+			LapsComplete:  max(lc, 0),
+			Time:          gap,
+			FastestLap:    c.bestLapNum,
+			FastestTime:   c.bestLap,
+			LastTime:      c.lastLap,
+			ReasonOutId:   reasonOutID,
+			ReasonOutStr:  reasonOut,
+		}
+	}
+	return ret
+}
+
+func deltaPct(a, b float64) float64 {
+	if a >= b {
+		return a - b
+	}
+	return a + 1 - b
+}
@@ -0,0 +1,149 @@
+package racegen
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mpapenbr/go-racelogger/internal/telemetry"
+)
+
+const testScenario = `
+name: test
+seed: 42
+track:
+  length: 1000
+  pitEntry: 0.9
+  pitStall: 0.05
+  pitExit: 0.15
+race:
+  laps: 5
+  cooldown: 5
+classes:
+  - {id: 1, name: GT3, carId: 10, car: GT3 car, lapTime: 30, noise: 0.2}
+  - {id: 2, name: GT4, carId: 20, car: GT4 car, lapTime: 33, noise: 0.2}
+cars:
+  - {carIdx: 1, number: "1", class: 1, drivers: [Alice, Bob], team: Team 1}
+  - {carIdx: 2, number: "2", class: 1, drivers: [Carl], lapTimeOffset: 1}
+  - {carIdx: 3, number: "3", class: 2, drivers: [Dora]}
+  - {carIdx: 4, number: "4", class: 2, drivers: [Emil]}
+events:
+  - {type: pit, carIdx: 1, lap: 2, duration: 10, driverSwap: true}
+  - {type: offTrack, carIdx: 3, lap: 3, duration: 3}
+  - {type: disconnect, carIdx: 4, lap: 2}
+  - {type: caution, lap: 3}
+`
+
+type tickInfo struct {
+	states    map[telemetry.SessionState]bool
+	userNames map[string]bool
+	onPitRoad bool
+	offTrack  bool
+	ticks     int
+}
+
+func runScenario(t *testing.T, sc *Scenario) (*Generator, tickInfo) {
+	t.Helper()
+	g, err := NewGenerator(sc)
+	require.NoError(t, err)
+	info := tickInfo{
+		states:    map[telemetry.SessionState]bool{},
+		userNames: map[string]bool{},
+	}
+	for {
+		src := g.Source()
+		state, _ := src.GetIntValue("SessionState")
+		info.states[telemetry.SessionState(state)] = true
+		for _, d := range src.GetLatestYaml().DriverInfo.Drivers {
+			info.userNames[d.UserName] = true
+		}
+		pit, _ := src.GetBoolValues("CarIdxOnPitRoad")
+		info.onPitRoad = info.onPitRoad || pit[1]
+		surface, _ := src.GetIntValues("CarIdxTrackSurface")
+		info.offTrack = info.offTrack ||
+			surface[3] == int32(telemetry.TrackLocationOffTrack)
+		info.ticks++
+
+		ok, err := g.Step()
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+	}
+	return g, info
+}
+
+func TestGenerator_Race(t *testing.T) {
+	sc, err := ParseScenario([]byte(testScenario))
+	require.NoError(t, err)
+
+	g, info := runScenario(t, sc)
+	for _, s := range []telemetry.SessionState{
+		telemetry.StateGetInCar,
+		telemetry.StateRacing,
+		telemetry.StateCheckered,
+		telemetry.StateCoolDown,
+	} {
+		assert.True(t, info.states[s], "state %v not reached", s)
+	}
+	assert.True(t, info.userNames["Bob"], "driver swap not happened")
+	assert.True(t, info.onPitRoad, "car 1 was never on pit road")
+	assert.True(t, info.offTrack, "car 3 was never off track")
+	// car 4 disconnected permanently, car 1 lost time in the pits
+	assert.Equal(t, []int{2, 1, 3}, g.FinishOrder())
+
+	standings := g.Source().GetLatestYaml().SessionInfo.Sessions[0].ResultsPositions
+	require.Len(t, standings, 4)
+	assert.Equal(t, 2, standings[0].CarIdx)
+	assert.Equal(t, 5, standings[0].LapsComplete)
+	assert.Equal(t, 0, standings[0].ClassPosition)
+	assert.Equal(t, 4, standings[3].CarIdx)
+	assert.Equal(t, "Disconnected", standings[3].ReasonOutStr)
+}
+
+func TestGenerator_Deterministic(t *testing.T) {
+	run := func() (*Generator, tickInfo) {
+		sc, err := ParseScenario([]byte(testScenario))
+		require.NoError(t, err)
+		return runScenario(t, sc)
+	}
+	g1, info1 := run()
+	g2, info2 := run()
+	assert.Equal(t, info1.ticks, info2.ticks)
+	assert.Equal(t, g1.FinishOrder(), g2.FinishOrder())
+	assert.Equal(t, g1.Source().GetYamlString(), g2.Source().GetYamlString())
+}
+
+func TestParseScenario_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"no track length", "race: {laps: 1}"},
+		{"no laps", "track: {length: 1000}"},
+		{"no cars", "track: {length: 1000}\nrace: {laps: 1}"},
+		{
+			"unknown class",
+			`track: {length: 1000}
+race: {laps: 1}
+classes: [{id: 1, lapTime: 30}]
+cars: [{carIdx: 1, class: 2, drivers: [A]}]`,
+		},
+		{
+			"unknown event",
+			`track: {length: 1000}
+race: {laps: 1}
+classes: [{id: 1, lapTime: 30}]
+cars: [{carIdx: 1, class: 1, drivers: [A]}]
+events: [{type: crash, carIdx: 1}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseScenario([]byte(tt.data))
+			assert.True(t, errors.Is(err, ErrInvalidScenario), "got %v", err)
+		})
+	}
+}
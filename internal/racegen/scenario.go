@@ -0,0 +1,217 @@
+package racegen
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EventPit        = "pit"
+	EventOffTrack   = "offTrack"
+	EventDisconnect = "disconnect"
+	EventSpin       = "spin"
+	EventStop       = "stop"
+	EventCaution    = "caution"
+)
+
+var ErrInvalidScenario = errors.New("invalid scenario")
+
+type (
+	// Scenario describes a race to be generated.
+	Scenario struct {
+		Name     string  `yaml:"name"`
+		Seed     uint64  `yaml:"seed"`
+		TickRate int     `yaml:"tickRate"` // ticks per second
+		Track    Track   `yaml:"track"`
+		Race     Race    `yaml:"race"`
+		Weather  Weather `yaml:"weather"`
+		Classes  []Class `yaml:"classes"`
+		Cars     []Car   `yaml:"cars"`
+		Events   []Event `yaml:"events"`
+	}
+	Track struct {
+		ID       int       `yaml:"id"`
+		Name     string    `yaml:"name"`
+		Length   float64   `yaml:"length"`   // meters
+		Sectors  []float64 `yaml:"sectors"`  // start pct of each sector, first must be 0
+		PitEntry float64   `yaml:"pitEntry"` // pct
+		PitStall float64   `yaml:"pitStall"` // pct
+		PitExit  float64   `yaml:"pitExit"`  // pct
+		PitSpeed float64   `yaml:"pitSpeed"` // km/h
+	}
+	Race struct {
+		Laps          int     `yaml:"laps"`
+		StartDelay    float64 `yaml:"startDelay"`    // seconds in state GetInCar
+		Cooldown      float64 `yaml:"cooldown"`      // seconds in state CoolDown
+		CautionFactor float64 `yaml:"cautionFactor"` // lap time factor during cautions
+		GridSpacing   float64 `yaml:"gridSpacing"`   // pct between two cars on the grid
+	}
+	Weather struct {
+		AirTemp       float32 `yaml:"airTemp"`
+		TrackTemp     float32 `yaml:"trackTemp"`
+		AirDensity    float32 `yaml:"airDensity"`
+		AirPressure   float32 `yaml:"airPressure"`
+		WindDir       float32 `yaml:"windDir"`
+		WindVel       float32 `yaml:"windVel"`
+		TrackWetness  int32   `yaml:"trackWetness"`
+		Precipitation float32 `yaml:"precipitation"`
+		TimeOfDay     float32 `yaml:"timeOfDay"` // seconds since midnight at session start
+	}
+	Class struct {
+		ID      int     `yaml:"id"`
+		Name    string  `yaml:"name"`
+		CarID   int     `yaml:"carId"`
+		Car     string  `yaml:"car"`
+		LapTime float64 `yaml:"lapTime"` // seconds
+		Noise   float64 `yaml:"noise"`   // std deviation of lap times in seconds
+	}
+	Car struct {
+		CarIdx        int      `yaml:"carIdx"`
+		Number        string   `yaml:"number"`
+		Class         int      `yaml:"class"`
+		Team          string   `yaml:"team"`
+		Drivers       []string `yaml:"drivers"`
+		LapTimeOffset float64  `yaml:"lapTimeOffset"` // added to the class lap time
+	}
+	// Event describes something that happens during the race.
+	// Lap refers to the value of CarIdxLap of the car
+	// (for cautions: the lap of the leader)
+	Event struct {
+		Type       string  `yaml:"type"`
+		CarIdx     int     `yaml:"carIdx"`
+		Lap        int     `yaml:"lap"`
+		TrackPos   float64 `yaml:"trackPos"`   // all but pit, caution (default 0.5)
+		Duration   float64 `yaml:"duration"`   // seconds (disconnect: 0 = permanent)
+		DriverSwap bool    `yaml:"driverSwap"` // pit: next driver takes over
+		Laps       int     `yaml:"laps"`       // caution: number of laps
+	}
+)
+
+// LoadScenario reads a scenario from a YAML file
+func LoadScenario(fn string) (*Scenario, error) {
+	data, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScenario(data)
+}
+
+// ParseScenario parses the YAML data, applies defaults and validates the scenario
+func ParseScenario(data []byte) (*Scenario, error) {
+	var s Scenario
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	s.applyDefaults()
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+//nolint:cyclop // simple defaults
+func (s *Scenario) applyDefaults() {
+	if s.TickRate == 0 {
+		s.TickRate = 10
+	}
+	if s.Track.ID == 0 {
+		s.Track.ID = 1
+	}
+	if s.Track.Name == "" {
+		s.Track.Name = "Generated Track"
+	}
+	if len(s.Track.Sectors) == 0 {
+		s.Track.Sectors = []float64{0, 0.33, 0.66}
+	}
+	if s.Track.PitSpeed == 0 {
+		s.Track.PitSpeed = 80
+	}
+	if s.Race.StartDelay == 0 {
+		s.Race.StartDelay = 2
+	}
+	if s.Race.Cooldown == 0 {
+		s.Race.Cooldown = 10
+	}
+	if s.Race.CautionFactor == 0 {
+		s.Race.CautionFactor = 1.5
+	}
+	if s.Race.GridSpacing == 0 {
+		s.Race.GridSpacing = 0.004
+	}
+	if s.Weather.AirDensity == 0 {
+		s.Weather.AirDensity = 1.2
+	}
+	if s.Weather.AirPressure == 0 {
+		s.Weather.AirPressure = 29.9
+	}
+	if s.Weather.TrackWetness == 0 {
+		s.Weather.TrackWetness = 1 // dry
+	}
+	for i := range s.Events {
+		e := &s.Events[i]
+		if e.TrackPos == 0 && e.Type != EventPit && e.Type != EventCaution {
+			e.TrackPos = 0.5
+		}
+		if e.Type == EventCaution && e.Laps == 0 {
+			e.Laps = 1
+		}
+	}
+}
+
+//nolint:cyclop // simple checks
+func (s *Scenario) validate() error {
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("%w: %s", ErrInvalidScenario, fmt.Sprintf(format, args...))
+	}
+	if s.Track.Length <= 0 {
+		return invalid("track length must be > 0")
+	}
+	if s.Track.Sectors[0] != 0 || !slices.IsSorted(s.Track.Sectors) {
+		return invalid("sectors must start with 0 and be sorted")
+	}
+	if s.Race.Laps <= 0 {
+		return invalid("race laps must be > 0")
+	}
+	if len(s.Cars) == 0 {
+		return invalid("no cars")
+	}
+	classes := map[int]bool{}
+	for _, c := range s.Classes {
+		if c.LapTime <= 0 {
+			return invalid("class %d: lap time must be > 0", c.ID)
+		}
+		classes[c.ID] = true
+	}
+	carIdxs := map[int]bool{}
+	for _, c := range s.Cars {
+		if c.CarIdx < 1 || c.CarIdx >= maxCars {
+			return invalid("car %s: carIdx must be in range 1..%d", c.Number, maxCars-1)
+		}
+		if carIdxs[c.CarIdx] {
+			return invalid("duplicate carIdx %d", c.CarIdx)
+		}
+		carIdxs[c.CarIdx] = true
+		if !classes[c.Class] {
+			return invalid("car %s: unknown class %d", c.Number, c.Class)
+		}
+		if len(c.Drivers) == 0 {
+			return invalid("car %s: no drivers", c.Number)
+		}
+	}
+	for _, e := range s.Events {
+		switch e.Type {
+		case EventPit, EventOffTrack, EventDisconnect, EventSpin, EventStop:
+			if !carIdxs[e.CarIdx] {
+				return invalid("event %s: unknown carIdx %d", e.Type, e.CarIdx)
+			}
+		case EventCaution:
+		default:
+			return invalid("unknown event type %q", e.Type)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package racelogger
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+
+	"github.com/mpapenbr/go-racelogger/internal/processor"
+	"github.com/mpapenbr/go-racelogger/log"
+)
+
+// nameExportFiles determines the export files for event (if configured)
+func (r *Racelogger) nameExportFiles(event *eventv1.Event, sessionName string) {
+	if r.config.incidentsNamer != nil {
+		r.incidentsFile = r.config.incidentsNamer.Name(event, sessionName)
+	}
+	if r.config.lapChartNamer != nil {
+		r.lapChartFile = r.config.lapChartNamer.Name(event, sessionName)
+	}
+	if r.config.pitStopsNamer != nil {
+		r.pitStopsFile = r.config.pitStopsNamer.Name(event, sessionName)
+	}
+}
+
+// writeExports writes the export files when the recording is done.
+// Errors are logged, the recording is not affected.
+func (r *Racelogger) writeExports(proc *processor.Processor) {
+	r.writeExport(r.incidentsFile, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(proc.IncidentReport())
+	})
+	r.writeExport(r.lapChartFile, func(w io.Writer) error {
+		if strings.EqualFold(filepath.Ext(r.lapChartFile), ".csv") {
+			return processor.WriteLapChartCSV(w, proc.LapChart())
+		}
+		return processor.WriteLapChartJSON(w, proc.LapChart())
+	})
+	r.writeExport(r.pitStopsFile, func(w io.Writer) error {
+		stops := processor.PitStopList(proc.PitStops())
+		if strings.EqualFold(filepath.Ext(r.pitStopsFile), ".csv") {
+			return processor.WritePitStopsCSV(w, stops)
+		}
+		return processor.WritePitStopsJSON(w, stops)
+	})
+}
+
+func (r *Racelogger) writeExport(fn string, write func(io.Writer) error) {
+	if fn == "" {
+		return
+	}
+	f, err := os.Create(fn)
+	if err == nil {
+		err = write(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.Warn("Could not write export file",
+			log.String("file", fn), log.ErrorField(err))
+		return
+	}
+	log.Info("Export file written", log.String("file", fn))
+}
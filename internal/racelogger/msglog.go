@@ -0,0 +1,61 @@
+package racelogger
+
+import (
+	"io"
+	"time"
+
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+
+	"github.com/mpapenbr/go-racelogger/log"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+// msgLogFile is the writer of the grpc msg log.
+// The file is created when the event is registered since its name depends on
+// the event. Data written before (there shouldn't be any) is dropped.
+type msgLogFile struct {
+	namer *logger.FileNamer
+	cfg   logger.FileConfig
+	f     io.WriteCloser
+}
+
+var _ logger.Segmenter = (*msgLogFile)(nil)
+
+func (m *msgLogFile) Write(b []byte) (int, error) {
+	if m.f == nil {
+		return len(b), nil
+	}
+	return m.f.Write(b)
+}
+
+// Rotate is passed to the file if it is split into segments
+func (m *msgLogFile) Rotate(ts time.Time) (bool, error) {
+	if s, ok := m.f.(logger.Segmenter); ok {
+		return s.Rotate(ts)
+	}
+	return false, nil
+}
+
+// open creates the file for event. Errors are logged, the recording is not
+// affected.
+func (m *msgLogFile) open(event *eventv1.Event, session string) {
+	if m.f != nil {
+		return
+	}
+	name := m.namer.Name(event, session)
+	f, err := logger.CreateFile(name, m.cfg)
+	if err != nil {
+		log.Warn("Could not create grpc log file",
+			log.String("file", name), log.ErrorField(err))
+		return
+	}
+	log.Info("Writing grpc messages", log.String("file", name))
+	m.f = f
+}
+
+func (m *msgLogFile) Close() error {
+	if m.f == nil {
+		return nil
+	}
+	return m.f.Close()
+}
@@ -8,28 +8,26 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 	"time"
 
-	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
 	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
 	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
 	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
-	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
 	"github.com/google/uuid"
 	"github.com/mpapenbr/goirsdk/irsdk"
-	"github.com/mpapenbr/goirsdk/yaml"
 	"google.golang.org/grpc"
-	"google.golang.org/protobuf/types/known/timestamppb"
 	goyaml "gopkg.in/yaml.v3"
 
+	"github.com/mpapenbr/go-racelogger/internal/clock"
 	"github.com/mpapenbr/go-racelogger/internal/processor"
+	"github.com/mpapenbr/go-racelogger/internal/telemetry"
 	"github.com/mpapenbr/go-racelogger/log"
 	grpcDataclient "github.com/mpapenbr/go-racelogger/pkg/grpc"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+	"github.com/mpapenbr/go-racelogger/pkg/publisher"
 	"github.com/mpapenbr/go-racelogger/pkg/util"
-	"github.com/mpapenbr/go-racelogger/version"
 )
 
 type (
@@ -44,13 +42,31 @@ type (
 		speedmapPublishInterval time.Duration
 		speedmapSpeedThreshold  float64
 		maxSpeed                float64
+		overtakeFilter          processor.OvertakeFilter
+		incidentMessages        bool
+		blueFlagWindow          float64
+		battleThreshold         float64
+		battleListener          processor.BattleListener
 		recordingMode           providerv1.RecordingMode
 		token                   string
-		grpcLogFile             string
+		grpcLogNamer            *logger.FileNamer
+		grpcLogFileConfig       logger.FileConfig
+		incidentsNamer          *logger.FileNamer
+		lapChartNamer           *logger.FileNamer
+		pitStopsNamer           *logger.FileNamer
+		lapChartListener        processor.LapChartListener
+		timingListener          processor.TimingListener
+		captureFile             string
 		ensureLiveData          bool
 		ensureLiveDataInterval  time.Duration
 		watchdogInterval        time.Duration
 		raceSessionRecordedChan chan int32
+		clock                   clock.Clock
+		publishers              []publisher.Publisher
+		primaryPublisher        publisher.Publisher
+		retryQueue              *grpcDataclient.RetryQueue
+		streamPublish           bool
+		stateKeyframeInterval   int
 	}
 )
 type ConfigFunc func(cfg *Config)
@@ -59,11 +75,17 @@ type ConfigFunc func(cfg *Config)
 type Racelogger struct {
 	eventKey      string
 	api           *irsdk.Irsdk
-	dataprovider  *grpcDataclient.DataProviderClient
+	dataprovider  publisher.Publisher
+	publishers    []publisher.Publisher // primary and others, used for publishing
 	simIsRunning  bool
 	config        *Config
 	globalData    processor.GlobalProcessingData
-	msgLogger     *os.File
+	msgLogger     *msgLogFile
+	stream        *grpcDataclient.StreamClient
+	capture       *telemetry.CaptureWriter
+	incidentsFile string
+	lapChartFile  string
+	pitStopsFile  string
 	log           *log.Logger
 	simStatusChan chan bool
 	httpClient    *http.Client
@@ -73,6 +95,8 @@ const (
 	RACE = "Race"
 )
 
+var _ processor.TelemetrySource = (*irsdk.Irsdk)(nil)
+
 func defaultConfig() *Config {
 	return &Config{
 		eventKeyFunc:            uuidBasedEventKeyFunc,
@@ -83,6 +107,7 @@ func defaultConfig() *Config {
 		recordingMode:           providerv1.RecordingMode_RECORDING_MODE_PERSIST,
 		ensureLiveData:          true,
 		ensureLiveDataInterval:  0,
+		clock:                   clock.Real(),
 	}
 }
 
@@ -134,6 +159,34 @@ func WithMaxSpeed(f float64) ConfigFunc {
 	return func(cfg *Config) { cfg.maxSpeed = f }
 }
 
+func WithOvertakeFilter(f processor.OvertakeFilter) ConfigFunc {
+	return func(cfg *Config) { cfg.overtakeFilter = f }
+}
+
+func WithIncidentMessages(b bool) ConfigFunc {
+	return func(cfg *Config) { cfg.incidentMessages = b }
+}
+
+func WithBlueFlagWindow(f float64) ConfigFunc {
+	return func(cfg *Config) { cfg.blueFlagWindow = f }
+}
+
+func WithBattleThreshold(f float64) ConfigFunc {
+	return func(cfg *Config) { cfg.battleThreshold = f }
+}
+
+func WithBattleListener(l processor.BattleListener) ConfigFunc {
+	return func(cfg *Config) { cfg.battleListener = l }
+}
+
+func WithLapChartListener(l processor.LapChartListener) ConfigFunc {
+	return func(cfg *Config) { cfg.lapChartListener = l }
+}
+
+func WithTimingListener(l processor.TimingListener) ConfigFunc {
+	return func(cfg *Config) { cfg.timingListener = l }
+}
+
 func WithRecordingMode(mode providerv1.RecordingMode) ConfigFunc {
 	return func(cfg *Config) { cfg.recordingMode = mode }
 }
@@ -142,8 +195,36 @@ func WithToken(token string) ConfigFunc {
 	return func(cfg *Config) { cfg.token = token }
 }
 
-func WithGrpcLogFile(grpcLogFile string) ConfigFunc {
-	return func(cfg *Config) { cfg.grpcLogFile = grpcLogFile }
+// WithGrpcLogFile writes the grpc messages of the event to a file.
+// The name is created by namer when the event is registered.
+func WithGrpcLogFile(namer *logger.FileNamer) ConfigFunc {
+	return func(cfg *Config) { cfg.grpcLogNamer = namer }
+}
+
+// WithIncidentsFile writes the incidents of each recorded race session as JSON
+func WithIncidentsFile(namer *logger.FileNamer) ConfigFunc {
+	return func(cfg *Config) { cfg.incidentsNamer = namer }
+}
+
+// WithLapChartFile writes the lap chart of each recorded race session.
+// Files with extension .csv are written as CSV, otherwise JSON is used.
+func WithLapChartFile(namer *logger.FileNamer) ConfigFunc {
+	return func(cfg *Config) { cfg.lapChartNamer = namer }
+}
+
+// WithPitStopsFile writes the pit stops of each recorded race session.
+// Files with extension .csv are written as CSV, otherwise JSON is used.
+func WithPitStopsFile(namer *logger.FileNamer) ConfigFunc {
+	return func(cfg *Config) { cfg.pitStopsNamer = namer }
+}
+
+// WithGrpcLogFileConfig sets the compression and rotation of the grpc log file
+func WithGrpcLogFileConfig(fileCfg logger.FileConfig) ConfigFunc {
+	return func(cfg *Config) { cfg.grpcLogFileConfig = fileCfg }
+}
+
+func WithCaptureFile(captureFile string) ConfigFunc {
+	return func(cfg *Config) { cfg.captureFile = captureFile }
 }
 
 func WithEnsureLiveData(b bool) ConfigFunc {
@@ -162,6 +243,37 @@ func WithRaceSessionRecorded(c chan int32) ConfigFunc {
 	return func(cfg *Config) { cfg.raceSessionRecordedChan = c }
 }
 
+func WithClock(c clock.Clock) ConfigFunc {
+	return func(cfg *Config) { cfg.clock = c }
+}
+
+// WithPublishers adds publishers which receive the data in addition to the backend
+func WithPublishers(p ...publisher.Publisher) ConfigFunc {
+	return func(cfg *Config) { cfg.publishers = append(cfg.publishers, p...) }
+}
+
+// WithPrimaryPublisher replaces the backend as primary destination of the data.
+// Used for offline recording where the data is written to a spool file first.
+func WithPrimaryPublisher(p publisher.Publisher) ConfigFunc {
+	return func(cfg *Config) { cfg.primaryPublisher = p }
+}
+
+// WithRetryQueue queues messages for later redelivery if the backend is not reachable
+func WithRetryQueue(q *grpcDataclient.RetryQueue) ConfigFunc {
+	return func(cfg *Config) { cfg.retryQueue = q }
+}
+
+// WithStreamPublish sends state, speedmap and driver data via the publish stream
+func WithStreamPublish(b bool) ConfigFunc {
+	return func(cfg *Config) { cfg.streamPublish = b }
+}
+
+// WithStateKeyframeInterval writes state messages to the grpc log file as deltas
+// with a keyframe every n messages. 0 disables the delta encoding.
+func WithStateKeyframeInterval(n int) ConfigFunc {
+	return func(cfg *Config) { cfg.stateKeyframeInterval = n }
+}
+
 func WithEventKeyFunc(f EventKeyFunc) ConfigFunc {
 	return func(cfg *Config) { cfg.eventKeyFunc = f }
 }
@@ -179,24 +291,49 @@ func NewRaceLogger(cfg ...ConfigFunc) *Racelogger {
 	for _, fn := range cfg {
 		fn(c)
 	}
-	var grpcMsgLog *os.File = nil
-	if c.grpcLogFile != "" {
-		f, err := os.Create(c.grpcLogFile)
+	var grpcMsgLog *msgLogFile = nil
+	if c.grpcLogNamer != nil {
+		grpcMsgLog = &msgLogFile{namer: c.grpcLogNamer, cfg: c.grpcLogFileConfig}
+	}
+	var capture *telemetry.CaptureWriter
+	if c.captureFile != "" {
+		cw, err := telemetry.OpenCaptureFile(c.captureFile)
 		if err != nil {
-			log.Warn("Could not create grpc log file", log.ErrorField(err))
+			log.Warn("Could not create capture file", log.ErrorField(err))
 		} else {
-			grpcMsgLog = f
+			capture = cw
 		}
 	}
-	ret := &Racelogger{
-		simIsRunning: false,
-		dataprovider: grpcDataclient.NewDataProviderClient(
+	var primary publisher.Publisher
+	var stream *grpcDataclient.StreamClient
+	// the grpc msg log is a publisher of its own. This way it gets all messages
+	// even if the backend can't keep up (see publisher.ForwardDecoupled)
+	if grpcMsgLog != nil {
+		c.publishers = append(c.publishers, publisher.NewMsgLog(grpcMsgLog,
+			logger.WithStateDelta(c.stateKeyframeInterval)))
+	}
+	if c.primaryPublisher != nil {
+		primary = c.primaryPublisher
+	} else {
+		dpc := grpcDataclient.NewDataProviderClient(
 			grpcDataclient.WithConnection(c.conn),
 			grpcDataclient.WithToken(c.token),
-			grpcDataclient.WithMsgLogFile(grpcMsgLog),
-		),
+			grpcDataclient.WithRetryQueue(c.retryQueue),
+		)
+		primary = dpc
+		if c.streamPublish {
+			stream = grpcDataclient.NewStreamClient(dpc)
+			primary = stream
+		}
+	}
+	ret := &Racelogger{
+		simIsRunning:  false,
+		dataprovider:  publisher.Combine(primary, c.publishers...),
+		publishers:    append([]publisher.Publisher{primary}, c.publishers...),
 		config:        c,
 		msgLogger:     grpcMsgLog,
+		stream:        stream,
+		capture:       capture,
 		log:           log.GetFromContext(c.ctx).Named("rl"),
 		simStatusChan: make(chan bool, 1),
 		httpClient:    &http.Client{Timeout: 10 * time.Second},
@@ -213,9 +350,19 @@ func (r *Racelogger) Close() {
 	r.log.Debug("Closing Racelogger")
 	r.api.Close()
 
+	if r.stream != nil {
+		if err := r.stream.Close(10 * time.Second); err != nil {
+			r.log.Warn("Could not close publish stream", log.ErrorField(err))
+		}
+	}
 	if r.msgLogger != nil {
 		r.msgLogger.Close()
 	}
+	if r.capture != nil {
+		if err := r.capture.Close(); err != nil {
+			r.log.Warn("Could not close capture file", log.ErrorField(err))
+		}
+	}
 }
 
 func (r *Racelogger) GetRaceSessions() (all []int, current int32, err error) {
@@ -246,9 +393,9 @@ func (r *Racelogger) RegisterProvider(eventName, eventDescription string) error
 	if err != nil {
 		return err
 	}
-	event := r.createEventInfo(irYaml)
+	event := processor.CreateEventInfo(irYaml, r.config.clock.Now())
 
-	track := r.createTrackInfo(irYaml)
+	track := processor.CreateTrackInfo(irYaml)
 
 	if eventName != "" {
 		event.Name = eventName
@@ -263,6 +410,9 @@ func (r *Racelogger) RegisterProvider(eventName, eventDescription string) error
 
 	r.eventKey = r.config.eventKeyFunc(r.api)
 	event.Key = r.eventKey
+	sessionNum, _ := r.api.GetIntValue("SessionNum")
+	r.openMsgLog(event, r.GetSessionName(sessionNum))
+	r.nameExportFiles(event, r.GetSessionName(sessionNum))
 
 	resp, err := r.dataprovider.RegisterProvider(event, track, r.config.recordingMode)
 	if err != nil {
@@ -284,9 +434,9 @@ func (r *Racelogger) RegisterProviderHeat(
 	if err != nil {
 		return err
 	}
-	event := r.createEventInfo(irYaml)
+	event := processor.CreateEventInfo(irYaml, r.config.clock.Now())
 
-	track := r.createTrackInfo(irYaml)
+	track := processor.CreateTrackInfo(irYaml)
 
 	if eventName != "" {
 		event.Name = eventName
@@ -302,6 +452,8 @@ func (r *Racelogger) RegisterProviderHeat(
 
 	r.eventKey = r.config.eventKeyFunc(r.api)
 	event.Key = r.eventKey
+	r.openMsgLog(event, sessionName)
+	r.nameExportFiles(event, sessionName)
 
 	resp, err := r.dataprovider.RegisterProvider(event, track, r.config.recordingMode)
 	if err != nil {
@@ -315,6 +467,13 @@ func (r *Racelogger) RegisterProviderHeat(
 	return nil
 }
 
+// openMsgLog creates the grpc msg log file for event (if configured)
+func (r *Racelogger) openMsgLog(event *eventv1.Event, sessionName string) {
+	if r.msgLogger != nil {
+		r.msgLogger.open(event, sessionName)
+	}
+}
+
 func (r *Racelogger) UnregisterProvider() {
 	if err := r.dataprovider.UnregisterProvider(r.eventKey); err != nil {
 		log.Warn("Could not unregister event",
@@ -424,97 +583,28 @@ func (r *Racelogger) initConnectionToSim(ctx context.Context, result chan<- bool
 	}
 }
 
-func (r *Racelogger) createEventInfo(irYaml *yaml.IrsdkYaml) *eventv1.Event {
-	pitSpeed, _ := processor.GetMetricUnit(irYaml.WeekendInfo.TrackPitSpeedLimit)
-	event := eventv1.Event{
-		TrackId:           uint32(irYaml.WeekendInfo.TrackID),
-		MultiClass:        irYaml.WeekendInfo.NumCarClasses > 1,
-		NumCarTypes:       uint32(irYaml.WeekendInfo.NumCarTypes),
-		TeamRacing:        irYaml.WeekendInfo.TeamRacing > 0,
-		IrSessionId:       int32(irYaml.WeekendInfo.SessionID),
-		IrSubSessionId:    int32(irYaml.WeekendInfo.SubSessionID),
-		RaceloggerVersion: version.Version,
-		EventTime:         timestamppb.Now(),
-		Sessions:          r.convertSessions(irYaml.SessionInfo.Sessions),
-		NumCarClasses:     uint32(irYaml.WeekendInfo.NumCarClasses),
-		PitSpeed:          float32(pitSpeed),
-		TireInfos:         r.convertTireInfos(irYaml.DriverInfo.DriverTires),
-	}
-	return &event
-}
-
-func (r *Racelogger) createTrackInfo(irYaml *yaml.IrsdkYaml) *trackv1.Track {
-	trackLength, _ := processor.GetTrackLengthInMeters(irYaml.WeekendInfo.TrackLength)
-	pitSpeed, _ := processor.GetMetricUnit(irYaml.WeekendInfo.TrackPitSpeedLimit)
-	ret := trackv1.Track{
-		Id:        uint32(irYaml.WeekendInfo.TrackID),
-		Name:      irYaml.WeekendInfo.TrackDisplayName,
-		ShortName: irYaml.WeekendInfo.TrackDisplayShortName,
-		Config:    irYaml.WeekendInfo.TrackConfigName,
-		Length:    float32(trackLength),
-		PitSpeed:  float32(pitSpeed),
-
-		Sectors: r.convertSectors(irYaml.SplitTimeInfo.Sectors),
-	}
-	return &ret
-}
-
-func (r *Racelogger) convertSectors(sectors []yaml.Sectors) []*trackv1.Sector {
-	ret := make([]*trackv1.Sector, len(sectors))
-	for i, v := range sectors {
-		ret[i] = &trackv1.Sector{
-			Num:      uint32(v.SectorNum),
-			StartPct: float32(v.SectorStartPct),
-		}
-	}
-	return ret
-}
-
-//nolint:gocritic // by design
-func (r *Racelogger) convertSessions(sessions []yaml.Sessions) []*eventv1.Session {
-	ret := make([]*eventv1.Session, len(sessions))
-	for i, v := range sessions {
-		time := 0.0
-		if v.SessionTime != "unlimited" {
-			// value is "xxx.0000 sec", so we can use our conversion function
-			// (even though it is not a metric depending value)
-			time, _ = processor.GetMetricUnit(v.SessionTime)
-		}
-
-		laps := 0
-		if v.SessionLaps != "unlimited" {
-			laps, _ = strconv.Atoi(v.SessionLaps)
-		}
-
-		ret[i] = &eventv1.Session{
-			Num:         uint32(v.SessionNum),
-			Name:        v.SessionName,
-			SessionTime: int32(time),
-			Laps:        int32(laps), //nolint:gosec // by design
-			Type:        convertSessionType(v.SessionType),
-			SubType:     convertSessionSubType(v.SessionSubType),
-		}
-	}
-	return ret
-}
-
-func (r *Racelogger) convertTireInfos(tires []yaml.DriverTires) []*eventv1.TireInfo {
-	ret := make([]*eventv1.TireInfo, len(tires))
-	for i, v := range tires {
-		ret[i] = &eventv1.TireInfo{
-			Index:        uint32(v.TireIndex),
-			CompoundType: v.TireCompoundType,
-		}
-	}
-	return ret
-}
-
 //nolint:gocognit,cyclop // by design
 func (r *Racelogger) setupMainLoop() {
 	stateChannel := make(chan *racestatev1.PublishStateRequest, 2)
 	speedmapChannel := make(chan *racestatev1.PublishSpeedmapRequest, 1)
 	carDataChannel := make(chan *racestatev1.PublishDriverDataRequest, 1)
 	extraInfoChannel := make(chan *racestatev1.PublishEventExtraInfoRequest, 1)
+	localChannel := make(chan *publisher.LocalMessage, 1)
+
+	// the new lap chart rows are also passed to the local publishers (json sink)
+	lapChartListener := func(laps []processor.LapChartEntry) {
+		localChannel <- &publisher.LocalMessage{Type: publisher.LocalLapChart, Data: laps}
+		if r.config.lapChartListener != nil {
+			r.config.lapChartListener(laps)
+		}
+	}
+	// the class gaps and intervals are not part of the backend messages
+	timingListener := func(timing []processor.Timing) {
+		localChannel <- &publisher.LocalMessage{Type: publisher.LocalTiming, Data: timing}
+		if r.config.timingListener != nil {
+			r.config.timingListener(timing)
+		}
+	}
 
 	recordingDoneChannel := make(chan struct{}, 1)
 
@@ -530,13 +620,24 @@ func (r *Racelogger) setupMainLoop() {
 		processor.WithSpeedmapPublishInterval(r.config.speedmapPublishInterval),
 		processor.WithSpeedmapSpeedThreshold(r.config.speedmapSpeedThreshold),
 		processor.WithMaxSpeed(r.config.maxSpeed),
+		processor.WithOvertakeFilter(r.config.overtakeFilter),
+		processor.WithIncidentMessages(r.config.incidentMessages),
+		processor.WithBlueFlagWindow(r.config.blueFlagWindow),
+		processor.WithBattleThreshold(r.config.battleThreshold),
+		processor.WithBattleListener(r.config.battleListener),
+		processor.WithLapChartListener(lapChartListener),
+		processor.WithTimingListener(timingListener),
+		processor.WithClock(r.config.clock),
 		processor.WithContext(r.config.ctx),
 	)
 
-	r.dataprovider.PublishStateFromChannel(r.eventKey, stateChannel)
-	r.dataprovider.PublishSpeedmapDataFromChannel(r.eventKey, speedmapChannel)
-	r.dataprovider.PublishCarDataFromChannel(r.eventKey, carDataChannel)
-	r.dataprovider.SendExtraInfoFromChannel(r.eventKey, extraInfoChannel)
+	publisher.ForwardDecoupled(publisher.Channels{
+		State:      stateChannel,
+		Speedmap:   speedmapChannel,
+		DriverData: carDataChannel,
+		ExtraInfo:  extraInfoChannel,
+		Local:      localChannel,
+	}, r.publishers...)
 
 	mainLoop := func(ctx context.Context) {
 		procDurations := []time.Duration{}
@@ -550,6 +651,7 @@ func (r *Racelogger) setupMainLoop() {
 				r.log.Debug("mainLoop received recordingDoneChannel", log.Bool("more", more))
 				if !more {
 					r.log.Info("Recording done.")
+					r.writeExports(proc)
 					current, _ := r.api.GetIntValue("SessionNum")
 					r.config.raceSessionRecordedChan <- current
 					return
@@ -576,6 +678,7 @@ func (r *Racelogger) setupMainLoop() {
 					getDataDurations = []time.Duration{}
 				}
 				if ok {
+					r.captureTick()
 					startProc := time.Now()
 					proc.Process()
 					procDurations = append(procDurations, time.Since(startProc))
@@ -594,6 +697,20 @@ func (r *Racelogger) setupMainLoop() {
 	go mainLoop(r.config.ctx)
 }
 
+// captureTick writes the current telemetry data to the capture file (if configured).
+// On errors the capture is stopped, the recording itself continues.
+func (r *Racelogger) captureTick() {
+	if r.capture == nil {
+		return
+	}
+	if err := r.capture.WriteTick(r.api); err != nil {
+		r.log.Warn("Could not write capture data. Stopping capture", log.ErrorField(err))
+		//nolint:errcheck // by design
+		r.capture.Close()
+		r.capture = nil
+	}
+}
+
 //nolint:gocognit // by design
 func (r *Racelogger) WaitForNextRaceSession(lastRaceSessionNum int32) int32 {
 	ticker := time.NewTicker(2 * time.Second)
@@ -680,33 +797,3 @@ func (r *Racelogger) logDurations(msg string, durations []time.Duration) {
 		log.Duration("avg", avg),
 		log.String("durations", strings.Join(durationsStrs, ",")))
 }
-
-func convertSessionType(apiValue string) commonv1.SessionType {
-	switch apiValue {
-	case "Practice":
-		return commonv1.SessionType_SESSION_TYPE_PRACTICE
-	case "Open Qualify":
-		return commonv1.SessionType_SESSION_TYPE_OPEN_QUALIFY
-	case "Lone Qualify":
-		return commonv1.SessionType_SESSION_TYPE_LONE_QUALIFY
-	case "Warmup":
-		return commonv1.SessionType_SESSION_TYPE_WARMUP
-	case RACE:
-		return commonv1.SessionType_SESSION_TYPE_RACE
-	default:
-		return commonv1.SessionType_SESSION_TYPE_UNSPECIFIED
-	}
-}
-
-func convertSessionSubType(apiValue string) commonv1.SessionSubType {
-	switch apiValue {
-	case "Heat":
-		return commonv1.SessionSubType_SESSION_SUB_TYPE_HEAT
-	case "Consolation":
-		return commonv1.SessionSubType_SESSION_SUB_TYPE_CONSOLATION
-	case "Feature":
-		return commonv1.SessionSubType_SESSION_SUB_TYPE_FEATURE
-	default:
-		return commonv1.SessionSubType_SESSION_SUB_TYPE_UNSPECIFIED
-	}
-}
@@ -2,14 +2,21 @@ package recorder
 
 import (
 	"context"
+	"io"
 	"time"
 
 	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
 	"google.golang.org/grpc"
 
+	"github.com/mpapenbr/go-racelogger/internal/msgimport"
+	"github.com/mpapenbr/go-racelogger/internal/processor"
 	"github.com/mpapenbr/go-racelogger/internal/racelogger"
+	"github.com/mpapenbr/go-racelogger/internal/spool"
 	"github.com/mpapenbr/go-racelogger/log"
 	"github.com/mpapenbr/go-racelogger/pkg/config"
+	grpcDataclient "github.com/mpapenbr/go-racelogger/pkg/grpc"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+	"github.com/mpapenbr/go-racelogger/pkg/publisher"
 )
 
 type contextData struct {
@@ -33,6 +40,21 @@ type Recorder struct {
 	rl                      *racelogger.Racelogger
 	eventNames              []string
 	eventDescriptions       []string
+	publishers              []publisher.Publisher
+	publisherClosers        []io.Closer
+	spool                   *spool.Writer
+	uploader                *spool.Uploader
+	uploadCancel            context.CancelFunc
+	uploadDone              chan struct{}
+	retryQueue              *grpcDataclient.RetryQueue
+	msgLogNamer             *logger.FileNamer
+	msgLogFileConfig        logger.FileConfig
+	incidentsNamer          *logger.FileNamer
+	battleListener          processor.BattleListener
+	lapChartNamer           *logger.FileNamer
+	lapChartListener        processor.LapChartListener
+	pitStopsNamer           *logger.FileNamer
+	timingListener          processor.TimingListener
 }
 type Option func(*Recorder)
 
@@ -59,6 +81,27 @@ func WithContext(ctx context.Context, cancel context.CancelFunc) Option {
 	}
 }
 
+// WithRetryQueue is used to queue messages if the backend is not reachable
+func WithRetryQueue(q *grpcDataclient.RetryQueue) Option {
+	return func(r *Recorder) { r.retryQueue = q }
+}
+
+// WithBattleListener receives the current battles while recording
+func WithBattleListener(l processor.BattleListener) Option {
+	return func(r *Recorder) { r.battleListener = l }
+}
+
+// WithLapChartListener receives the new lap completions while recording
+func WithLapChartListener(l processor.LapChartListener) Option {
+	return func(r *Recorder) { r.lapChartListener = l }
+}
+
+// WithTimingListener receives the overall and class based gaps and intervals
+// while recording
+func WithTimingListener(l processor.TimingListener) Option {
+	return func(r *Recorder) { r.timingListener = l }
+}
+
 func WithEventNames(arg []string) Option {
 	return func(r *Recorder) { r.eventNames = arg }
 }
@@ -107,10 +150,46 @@ func (r *Recorder) initFromCLI(cfg *config.CliArgs) {
 	if cfg.DoNotPersist {
 		r.recordingMode = providerv1.RecordingMode_RECORDING_MODE_DO_NOT_PERSIST
 	}
+	if cfg.MsgLogFile != "" {
+		// shared by all heats to keep the file names unique
+		r.msgLogNamer = logger.NewFileNamer(cfg.MsgLogFile)
+	}
+	r.msgLogFileConfig = msgLogFileConfig(cfg)
+	if cfg.IncidentsFile != "" {
+		r.incidentsNamer = logger.NewFileNamer(cfg.IncidentsFile)
+	}
+	if cfg.LapChartFile != "" {
+		r.lapChartNamer = logger.NewFileNamer(cfg.LapChartFile)
+	}
+	if cfg.PitStopsFile != "" {
+		r.pitStopsNamer = logger.NewFileNamer(cfg.PitStopsFile)
+	}
+}
+
+// msgLogFileConfig returns the compression and rotation of the msg log files.
+// Invalid values are logged and ignored.
+func msgLogFileConfig(cfg *config.CliArgs) logger.FileConfig {
+	ret := logger.FileConfig{
+		MaxSize:     cfg.MsgLogRotateSize * 1024 * 1024,
+		MaxSegments: cfg.MsgLogMaxSegments,
+	}
+	var err error
+	if ret.Compression, err = logger.ParseCompression(cfg.MsgLogCompression); err != nil {
+		log.Warn("Invalid msg log compression. Writing uncompressed",
+			log.ErrorField(err))
+	}
+	if cfg.MsgLogRotateInterval != "" {
+		if ret.MaxAge, err = time.ParseDuration(cfg.MsgLogRotateInterval); err != nil {
+			log.Warn("Invalid msg log rotate interval. Ignored", log.ErrorField(err))
+		}
+	}
+	return ret
 }
 
 //nolint:funlen,nestif,gocognit // by design
 func (r *Recorder) Start() {
+	r.openPublishers()
+	r.openSpool()
 	// loop until all race sessions are recorded
 	r.collectRaceSessions()
 	raceIndex := 0 // used to get name/description from cli args
@@ -186,7 +265,104 @@ func (r *Recorder) Stop() {
 }
 
 func (r *Recorder) Close() {
-	// cleanup
+	if r.uploadCancel != nil {
+		r.uploadCancel()
+		<-r.uploadDone
+		r.uploadCancel = nil
+	}
+	if r.spool != nil {
+		if err := r.spool.Close(); err != nil {
+			r.l.Warn("Error closing spool file", log.ErrorField(err))
+		}
+		r.spool = nil
+	}
+	if err := publisher.CloseAll(r.publisherClosers); err != nil {
+		r.l.Warn("Error closing publishers", log.ErrorField(err))
+	}
+	r.publisherClosers = nil
+}
+
+// openPublishers opens the additional publishers configured by the cli args.
+// Errors are logged, the recording to the backend is not affected.
+func (r *Recorder) openPublishers() {
+	if r.cli == nil || len(r.cli.Publish) == 0 {
+		return
+	}
+	pubs, closers, err := publisher.OpenSinks(r.cli.Publish, r.msgLogFileConfig,
+		logger.WithStateDelta(r.cli.StateKeyframeInterval))
+	if err != nil {
+		r.l.Error("Could not open publishers", log.ErrorField(err))
+		return
+	}
+	r.publishers = pubs
+	r.publisherClosers = closers
+}
+
+// openSpool creates the spool file and starts the uploader if spooling is
+// configured. The uploader sends the spooled data to the backend as soon as
+// it is reachable.
+func (r *Recorder) openSpool() {
+	if r.cli == nil {
+		return
+	}
+	dir := r.cli.SpoolDir
+	if dir == "" && r.cli.Offline {
+		dir = config.DefaultSpoolDir
+	}
+	if dir == "" {
+		return
+	}
+	w, err := spool.Create(dir, logger.WithStateDelta(r.cli.StateKeyframeInterval))
+	if err != nil {
+		r.l.Error("Could not create spool file", log.ErrorField(err))
+		return
+	}
+	r.spool = w
+	r.l.Info("Recording to spool file", log.String("file", w.Name()))
+
+	dpc := grpcDataclient.NewDataProviderClient(
+		grpcDataclient.WithConnection(r.conn),
+		grpcDataclient.WithToken(r.cli.Token),
+	)
+	r.uploader = spool.NewUploader(w.Name(),
+		msgimport.NewImporter(dpc, msgimport.WithRecordingMode(r.recordingMode)),
+		spool.WithFollow(true))
+	var uploadCtx context.Context
+	uploadCtx, r.uploadCancel = context.WithCancel(context.Background())
+	r.uploadDone = make(chan struct{})
+	go func() {
+		defer close(r.uploadDone)
+		if err := r.uploader.Run(uploadCtx); err != nil {
+			r.l.Error("Upload of spool file stopped. Use the upload command later",
+				log.String("file", w.Name()),
+				log.ErrorField(err))
+		}
+	}()
+}
+
+// SpoolFile returns the name of the spool file (empty if spooling is not active)
+func (r *Recorder) SpoolFile() string {
+	if r.spool == nil {
+		return ""
+	}
+	return r.spool.Name()
+}
+
+// WaitForUpload waits until the spooled data is uploaded to the backend.
+// Returns immediately if spooling is not active.
+func (r *Recorder) WaitForUpload(ctx context.Context) error {
+	if r.uploader == nil {
+		return nil
+	}
+	r.uploader.Finish()
+	select {
+	case <-r.uploadDone:
+		return nil
+	case <-ctx.Done():
+		r.l.Warn("Upload aborted. Use the upload command to continue",
+			log.String("file", r.spool.Name()))
+		return ctx.Err()
+	}
 }
 
 func (r *Recorder) collectRaceSessions() {
@@ -207,7 +383,7 @@ func (r *Recorder) collectRaceSessions() {
 
 func (r *Recorder) createRacelogger() *racelogger.Racelogger {
 	loggerCtx, cancel := context.WithCancel(r.overallCtx.ctx)
-	rl := racelogger.NewRaceLogger(
+	opts := []racelogger.ConfigFunc{
 		racelogger.WithGrpcConn(r.conn),
 		racelogger.WithContext(loggerCtx, cancel),
 		racelogger.WithWaitForServicesTimeout(r.waitForServicesTimeout),
@@ -215,15 +391,39 @@ func (r *Recorder) createRacelogger() *racelogger.Racelogger {
 		racelogger.WithSpeedmapPublishInterval(r.speedmapPublishInterval),
 		racelogger.WithSpeedmapSpeedThreshold(r.cli.SpeedmapSpeedThreshold),
 		racelogger.WithMaxSpeed(r.cli.MaxSpeed),
+		racelogger.WithOvertakeFilter(processor.OvertakeFilter{
+			Enabled:   r.cli.Overtakes,
+			TopN:      r.cli.OvertakeTopN,
+			SameClass: r.cli.OvertakeSameClass,
+		}),
+		racelogger.WithIncidentMessages(r.cli.IncidentMessages),
+		racelogger.WithBlueFlagWindow(r.cli.BlueFlagWindow),
+		racelogger.WithBattleThreshold(r.cli.BattleThreshold),
+		racelogger.WithBattleListener(r.battleListener),
+		racelogger.WithLapChartListener(r.lapChartListener),
+		racelogger.WithTimingListener(r.timingListener),
 		racelogger.WithRecordingMode(r.recordingMode),
 		racelogger.WithToken(r.cli.Token),
-		racelogger.WithGrpcLogFile(r.cli.MsgLogFile),
+		racelogger.WithGrpcLogFile(r.msgLogNamer),
+		racelogger.WithIncidentsFile(r.incidentsNamer),
+		racelogger.WithLapChartFile(r.lapChartNamer),
+		racelogger.WithPitStopsFile(r.pitStopsNamer),
+		racelogger.WithGrpcLogFileConfig(r.msgLogFileConfig),
+		racelogger.WithCaptureFile(r.cli.CaptureFile),
 		racelogger.WithEnsureLiveData(r.cli.EnsureLiveData),
 		racelogger.WithEnsureLiveDataInterval(r.ensureLiveDataInterval),
 		racelogger.WithWatchdogInterval(r.watchdogInterval),
 		racelogger.WithRaceSessionRecorded(r.raceSessionRecordedChan),
+		racelogger.WithPublishers(r.publishers...),
+		racelogger.WithRetryQueue(r.retryQueue),
+		racelogger.WithStreamPublish(r.cli.StreamPublish),
+		racelogger.WithStateKeyframeInterval(r.cli.StateKeyframeInterval),
 		racelogger.WithUUIDEventKey(),
-	)
+	}
+	if r.spool != nil {
+		opts = append(opts, racelogger.WithPrimaryPublisher(r.spool))
+	}
+	rl := racelogger.NewRaceLogger(opts...)
 	if rl == nil {
 		log.Error("Could not create racelogger")
 		return nil
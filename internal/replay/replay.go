@@ -0,0 +1,224 @@
+// Package replay runs the processor on telemetry data from a capture file
+// (see the capture command) instead of a running iRacing simulation.
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"github.com/google/uuid"
+
+	"github.com/mpapenbr/go-racelogger/internal/clock"
+	"github.com/mpapenbr/go-racelogger/internal/pacer"
+	"github.com/mpapenbr/go-racelogger/internal/processor"
+	"github.com/mpapenbr/go-racelogger/internal/telemetry"
+	"github.com/mpapenbr/go-racelogger/log"
+	"github.com/mpapenbr/go-racelogger/pkg/publisher"
+)
+
+var ErrNoData = errors.New("capture contains no data")
+
+type (
+	Replay struct {
+		reader           *telemetry.CaptureReader
+		output           publisher.Publisher
+		recordingMode    providerv1.RecordingMode
+		speed            float64
+		eventKey         string
+		eventName        string
+		eventDescription string
+		startTime        time.Time
+		clock            *clock.SessionClock
+		procOptions      []processor.OptionsFunc
+		log              *log.Logger
+	}
+	Option func(*Replay)
+)
+
+// WithSpeed sets the replay speed. 1 is real time, 0 means as fast as possible
+func WithSpeed(speed float64) Option {
+	return func(r *Replay) { r.speed = speed }
+}
+
+func WithRecordingMode(mode providerv1.RecordingMode) Option {
+	return func(r *Replay) { r.recordingMode = mode }
+}
+
+func WithEventKey(key string) Option {
+	return func(r *Replay) { r.eventKey = key }
+}
+
+func WithEventName(name string) Option {
+	return func(r *Replay) { r.eventName = name }
+}
+
+func WithEventDescription(description string) Option {
+	return func(r *Replay) { r.eventDescription = description }
+}
+
+// WithStartTime sets the time of the first tick.
+// All timestamps are derived from this time and the SessionTime of the capture.
+// Default is the time the replay starts.
+func WithStartTime(t time.Time) Option {
+	return func(r *Replay) { r.startTime = t }
+}
+
+// WithProcessorOptions passes additional options to the processor
+func WithProcessorOptions(opts ...processor.OptionsFunc) Option {
+	return func(r *Replay) { r.procOptions = append(r.procOptions, opts...) }
+}
+
+//nolint:whitespace // can't get different linters happy
+func NewReplay(
+	reader *telemetry.CaptureReader,
+	output publisher.Publisher,
+	opts ...Option,
+) *Replay {
+	ret := &Replay{
+		reader:        reader,
+		output:        output,
+		recordingMode: providerv1.RecordingMode_RECORDING_MODE_PERSIST,
+		speed:         1,
+		log:           log.Default().Named("replay"),
+	}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	if ret.startTime.IsZero() {
+		ret.startTime = time.Now()
+	}
+	ret.clock = clock.NewSessionClock(ret.startTime)
+	return ret
+}
+
+// Run processes the capture tick by tick until the race is done,
+// the capture is exhausted or the context is canceled.
+//
+//nolint:funlen // by design
+func (r *Replay) Run(ctx context.Context) error {
+	r.log = log.GetFromContext(ctx).Named("replay")
+	mem := telemetry.NewMemory()
+	if err := r.reader.Next(mem); err != nil {
+		if errors.Is(err, io.EOF) {
+			return ErrNoData
+		}
+		return err
+	}
+	r.updateClock(mem)
+	gpd, err := r.register(mem)
+	if err != nil {
+		return err
+	}
+
+	stateChannel := make(chan *racestatev1.PublishStateRequest, 2)
+	speedmapChannel := make(chan *racestatev1.PublishSpeedmapRequest, 1)
+	carDataChannel := make(chan *racestatev1.PublishDriverDataRequest, 1)
+	extraInfoChannel := make(chan *racestatev1.PublishEventExtraInfoRequest, 1)
+	recordingDoneChannel := make(chan struct{}, 1)
+
+	forwardDone := publisher.Forward(r.output, publisher.Channels{
+		State:      stateChannel,
+		Speedmap:   speedmapChannel,
+		DriverData: carDataChannel,
+		ExtraInfo:  extraInfoChannel,
+	})
+
+	opts := []processor.OptionsFunc{
+		processor.WithGlobalProcessingData(gpd),
+		processor.WithChunkSize(10),
+		processor.WithRecordingDoneChannel(recordingDoneChannel),
+		processor.WithClock(r.clock),
+		processor.WithContext(ctx),
+	}
+	proc := processor.NewProcessor(
+		mem,
+		stateChannel,
+		speedmapChannel,
+		carDataChannel,
+		extraInfoChannel,
+		append(opts, r.procOptions...)...,
+	)
+
+	p := pacer.New(r.speed)
+	var runErr error
+loop:
+	for {
+		sessionTime, _ := mem.GetDoubleValue("SessionTime")
+		p.Wait(ctx, sessionTime)
+		if ctx.Err() != nil {
+			r.log.Debug("replay canceled")
+			break
+		}
+		proc.Process()
+		select {
+		case _, more := <-recordingDoneChannel:
+			if !more {
+				r.log.Info("Recording done.")
+				break loop
+			}
+		default:
+		}
+		if err := r.reader.Next(mem); err != nil {
+			if !errors.Is(err, io.EOF) {
+				runErr = err
+			}
+			break
+		}
+		r.updateClock(mem)
+	}
+	r.log.Info("Replay finished", log.Int("ticks", r.reader.Ticks()))
+
+	close(stateChannel)
+	close(speedmapChannel)
+	close(carDataChannel)
+	close(extraInfoChannel)
+	<-forwardDone
+
+	if err := r.output.UnregisterProvider(gpd.EventDataInfo.Key); err != nil {
+		r.log.Warn("Could not unregister event", log.ErrorField(err))
+	}
+	return runErr
+}
+
+//nolint:whitespace // false positive
+func (r *Replay) register(
+	mem *telemetry.Memory,
+) (*processor.GlobalProcessingData, error) {
+	y := mem.GetLatestYaml()
+	event := processor.CreateEventInfo(y, r.clock.Now())
+	track := processor.CreateTrackInfo(y)
+	if r.eventName != "" {
+		event.Name = r.eventName
+	} else {
+		event.Name = fmt.Sprintf("%s %s (replay)",
+			track.Name,
+			event.EventTime.AsTime().Format("20060102-150405"))
+	}
+	event.Description = r.eventDescription
+	event.Key = r.eventKey
+	if event.Key == "" {
+		event.Key = uuid.New().String()
+	}
+	resp, err := r.output.RegisterProvider(event, track, r.recordingMode)
+	if err != nil {
+		return nil, err
+	}
+	r.log.Info("Replaying event", log.String("name", event.Name),
+		log.String("key", event.Key))
+	return &processor.GlobalProcessingData{
+		TrackInfo:     resp.Track,
+		EventDataInfo: event,
+	}, nil
+}
+
+// updateClock advances the replay clock to the SessionTime of the current tick
+func (r *Replay) updateClock(mem *telemetry.Memory) {
+	if sessionTime, err := mem.GetDoubleValue("SessionTime"); err == nil {
+		r.clock.Update(sessionTime)
+	}
+}
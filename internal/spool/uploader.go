@@ -0,0 +1,453 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/mpapenbr/go-racelogger/internal/msgimport"
+	"github.com/mpapenbr/go-racelogger/log"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+// for a manifest this is logger.PositionName, so the rotating msg log keeps
+// the segments which are not uploaded yet
+const offsetFileExtension = logger.PositionExtension
+
+var errSegmentNotFound = errors.New("segment of upload position not found")
+
+type (
+	// Uploader sends the messages of a spool file to the backend server.
+	// The position of the last uploaded message is stored next to the spool file
+	// (<spool file>.offset), so an interrupted upload continues where it stopped.
+	Uploader struct {
+		fn           string
+		offsetFile   string
+		importer     *msgimport.Importer
+		pollInterval time.Duration
+		maxBackoff   time.Duration
+		follow       bool
+		finishOnce   sync.Once
+		finish       chan struct{}
+		log          *log.Logger
+	}
+	UploaderOption func(*Uploader)
+)
+
+// WithFollow keeps the uploader waiting for new messages at the end of the file
+// until Finish is called. Used while the recording is still in progress.
+func WithFollow(b bool) UploaderOption {
+	return func(u *Uploader) { u.follow = b }
+}
+
+// WithPollInterval sets how often the spool file is checked for new messages
+func WithPollInterval(d time.Duration) UploaderOption {
+	return func(u *Uploader) { u.pollInterval = d }
+}
+
+// WithMaxBackoff sets the maximum wait time between two attempts to send a message
+func WithMaxBackoff(d time.Duration) UploaderOption {
+	return func(u *Uploader) { u.maxBackoff = d }
+}
+
+// WithOffsetFile stores the position of the last uploaded message in fn instead
+// of <spool file>.offset. An empty name disables storing the position.
+func WithOffsetFile(fn string) UploaderOption {
+	return func(u *Uploader) { u.offsetFile = fn }
+}
+
+//nolint:whitespace // can't get different linters happy
+func NewUploader(
+	fn string,
+	importer *msgimport.Importer,
+	opts ...UploaderOption,
+) *Uploader {
+	ret := &Uploader{
+		fn:           fn,
+		offsetFile:   fn + offsetFileExtension,
+		importer:     importer,
+		pollInterval: 500 * time.Millisecond,
+		maxBackoff:   30 * time.Second,
+		finish:       make(chan struct{}),
+		log:          log.Default().Named("uploader"),
+	}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	return ret
+}
+
+// Finish signals that no more messages will be written to the spool file.
+// Run returns once all messages are uploaded.
+func (u *Uploader) Finish() {
+	u.finishOnce.Do(func() { close(u.finish) })
+}
+
+// Run uploads the messages of the spool file until the end of the file is reached
+// (in follow mode: until Finish is called and all messages are uploaded)
+// or the context is canceled.
+//
+//nolint:cyclop // by design
+func (u *Uploader) Run(ctx context.Context) error {
+	if logger.IsManifest(u.fn) {
+		return u.runSegments(ctx)
+	}
+	f, err := os.Open(u.fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if logger.IsCompressed(f) {
+		return u.runStream(ctx)
+	}
+	offset := u.loadOffset()
+	if offset > 0 {
+		u.log.Info("Continuing upload", log.Int64("offset", offset))
+	}
+	count := 0
+	var format byte
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if format == 0 {
+			format, offset, err = u.start(f, offset)
+		}
+		var msg protoreflect.Message
+		var n int64
+		if format != 0 {
+			msg, n, err = readAt(f, offset, format)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// no (complete) message available yet
+			if !u.following() {
+				if errors.Is(err, io.ErrUnexpectedEOF) {
+					u.log.Warn("Spool file ends with an incomplete message")
+				}
+				u.log.Info("Upload done", log.Int("messages", count))
+				return nil
+			}
+			u.wait(ctx)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if msg != nil {
+			if err := u.send(ctx, msg); err != nil {
+				return err
+			}
+			count++
+		}
+		offset += n
+		if err := u.saveOffset(offset); err != nil {
+			u.log.Warn("Could not store upload offset", log.ErrorField(err))
+		}
+	}
+}
+
+// runStream uploads a compressed msg log. It can't be read at an offset.
+// The offset refers to the uncompressed data, the messages before it are
+// passed to the importer without sending them.
+// Follow mode is not supported.
+func (u *Uploader) runStream(ctx context.Context) error {
+	r, err := os.Open(u.fn)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	offset := u.loadOffset()
+	if offset > 0 {
+		u.log.Info("Continuing upload", log.Int64("offset", offset))
+	}
+	count, err := u.upload(ctx, r, offset, u.saveOffset)
+	if err != nil {
+		return err
+	}
+	u.log.Info("Upload done", log.Int("messages", count))
+	return nil
+}
+
+// runSegments uploads the segments of a rotated msg log one after another.
+// The position is stored as segment and offset within it (see logger.Position),
+// so it remains valid when older segments are deleted. The segments before the
+// position are passed to the importer without sending them.
+// Follow mode is not supported.
+//
+//nolint:cyclop // by design
+func (u *Uploader) runSegments(ctx context.Context) error {
+	m, err := logger.ReadManifest(u.fn)
+	if err != nil {
+		return err
+	}
+	pos := u.loadPosition()
+	start := 0
+	if pos.Segment != "" {
+		start = slices.IndexFunc(m.Segments, func(seg *logger.Segment) bool {
+			return seg.File == pos.Segment
+		})
+		if start < 0 {
+			return fmt.Errorf("%w: %s", errSegmentNotFound, pos.Segment)
+		}
+		u.log.Info("Continuing upload",
+			log.String("segment", pos.Segment), log.Int64("offset", pos.Offset))
+	}
+	count := 0
+	for i, seg := range m.Segments {
+		offset := int64(0)
+		switch {
+		case i < start:
+			offset = math.MaxInt64 // uploaded before
+		case i == start:
+			offset = pos.Offset
+		}
+		f, err := os.Open(filepath.Join(filepath.Dir(u.fn), seg.File))
+		if i < start && errors.Is(err, os.ErrNotExist) {
+			continue // uploaded segment deleted meanwhile
+		}
+		if err != nil {
+			return err
+		}
+		n, err := u.upload(ctx, f, offset, func(offset int64) error {
+			return u.savePosition(logger.Position{Segment: seg.File, Offset: offset})
+		})
+		f.Close()
+		if err != nil {
+			return err
+		}
+		count += n
+	}
+	u.log.Info("Upload done", log.Int("messages", count))
+	return nil
+}
+
+// upload sends the messages of the msg log r. The messages up to offset were
+// sent before, they are passed to the importer without sending them.
+// save is called with the offset of the next message after each sent message.
+// Returns the number of messages sent.
+//
+//nolint:whitespace // can't get different linters happy
+func (u *Uploader) upload(
+	ctx context.Context,
+	r io.Reader,
+	offset int64,
+	save func(offset int64) error,
+) (int, error) {
+	m := logger.NewMsgLogger(logger.WithReader(r))
+	count := 0
+	for {
+		if ctx.Err() != nil {
+			return count, ctx.Err()
+		}
+		msg, err := m.ReadNext()
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				u.log.Warn("Msg log ends with an incomplete message")
+			}
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		if m.Offset() <= offset {
+			if msg != nil {
+				if err := u.importer.Skip(msg); err != nil {
+					return count, err
+				}
+			}
+			continue
+		}
+		if msg != nil {
+			if err := u.send(ctx, msg); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if err := save(m.Offset()); err != nil {
+			u.log.Warn("Could not store upload offset", log.ErrorField(err))
+		}
+	}
+}
+
+func (u *Uploader) following() bool {
+	if !u.follow {
+		return false
+	}
+	select {
+	case <-u.finish:
+		return false
+	default:
+		return true
+	}
+}
+
+// wait waits for the poll interval or until Finish is called
+func (u *Uploader) wait(ctx context.Context) {
+	t := time.NewTimer(u.pollInterval)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-u.finish:
+	case <-t.C:
+	}
+}
+
+// send sends the message to the backend.
+// Temporary errors are retried with exponential backoff.
+// Messages rejected by the server are skipped.
+func (u *Uploader) send(ctx context.Context, msg protoreflect.Message) error {
+	backoff := min(time.Second, u.maxBackoff)
+	attempt := 0
+	for {
+		err := u.importer.Send(msg)
+		if err == nil {
+			if attempt > 0 {
+				u.log.Info("Upload resumed", log.Int("attempts", attempt+1))
+			}
+			return nil
+		}
+		//nolint:exhaustive // only some codes need special handling
+		switch status.Code(err) {
+		case codes.Unauthenticated, codes.PermissionDenied:
+			return err
+		case codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+			codes.FailedPrecondition:
+			u.log.Warn("Message rejected by server. Skipping",
+				log.String("msg", string(msg.Descriptor().Name())),
+				log.ErrorField(err))
+			return nil
+		}
+		if attempt%10 == 0 {
+			u.log.Warn("Could not upload message. Will retry",
+				log.Int("attempt", attempt+1),
+				log.Duration("backoff", backoff),
+				log.ErrorField(err))
+		}
+		attempt++
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		backoff = min(2*backoff, u.maxBackoff)
+	}
+}
+
+func (u *Uploader) loadOffset() int64 {
+	if u.offsetFile == "" {
+		return 0
+	}
+	data, err := os.ReadFile(u.offsetFile)
+	if err != nil {
+		return 0
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		u.log.Warn("Invalid upload offset. Starting from beginning", log.ErrorField(err))
+		return 0
+	}
+	return offset
+}
+
+func (u *Uploader) loadPosition() logger.Position {
+	if u.offsetFile == "" {
+		return logger.Position{}
+	}
+	pos, err := logger.ReadPosition(u.offsetFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return logger.Position{}
+	}
+	if err != nil {
+		u.log.Warn("Invalid upload position. Starting from beginning", log.ErrorField(err))
+		return logger.Position{}
+	}
+	return *pos
+}
+
+func (u *Uploader) savePosition(pos logger.Position) error {
+	if u.offsetFile == "" {
+		return nil
+	}
+	return logger.WritePosition(u.offsetFile, pos)
+}
+
+// saveOffset stores the offset atomically (write temp file, then rename)
+func (u *Uploader) saveOffset(offset int64) error {
+	if u.offsetFile == "" {
+		return nil
+	}
+	fn := u.offsetFile
+	tmp := fn + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(offset, 10)), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// start detects the format of the spool file and returns the offset of the first
+// message to upload. When resuming, the messages before offset are passed to the
+// importer without sending them (see msgimport.Importer.Skip).
+func (u *Uploader) start(f *os.File, offset int64) (byte, int64, error) {
+	format, pos, err := detectFormat(f)
+	if err != nil {
+		return 0, offset, err
+	}
+	for pos < offset {
+		msg, n, err := readAt(f, pos, format)
+		if err != nil {
+			return 0, offset, err
+		}
+		if msg != nil {
+			if err := u.importer.Skip(msg); err != nil {
+				return 0, offset, err
+			}
+		}
+		pos += n
+	}
+	return format, pos, nil
+}
+
+// detectFormat reads the file header (if any) and returns the format of the
+// spool file along with the offset of the first record.
+func detectFormat(f *os.File) (byte, int64, error) {
+	h, size, err := logger.ReadFileHeader(io.NewSectionReader(f, 0, math.MaxInt64))
+	switch {
+	case err == nil:
+		return h.Version, size, nil
+	case errors.Is(err, logger.ErrNoFileHeader):
+		return logger.FormatV1, 0, nil
+	default:
+		return 0, 0, err
+	}
+}
+
+// readAt reads the message at offset.
+// Returns the message (nil for unknown message types) and the bytes consumed.
+//
+//nolint:whitespace // can't get different linters happy
+func readAt(
+	f *os.File,
+	offset int64,
+	format byte,
+) (protoreflect.Message, int64, error) {
+	m := logger.NewMsgLogger(
+		logger.WithReader(io.NewSectionReader(f, offset, math.MaxInt64-offset)),
+		logger.WithFormat(format))
+	msg, err := m.ReadNext()
+	return msg, m.Offset(), err
+}
@@ -0,0 +1,221 @@
+package spool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mpapenbr/go-racelogger/internal/msgimport"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+	"github.com/mpapenbr/go-racelogger/pkg/publisher"
+)
+
+// recordingTarget records the received messages.
+// The first len(errs) calls return the given errors.
+type recordingTarget struct {
+	publisher.Publisher
+	mu    sync.Mutex
+	calls []string
+	errs  []error
+}
+
+func (r *recordingTarget) record(call string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.errs) > 0 {
+		err := r.errs[0]
+		r.errs = r.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	r.calls = append(r.calls, call)
+	return nil
+}
+
+func (r *recordingTarget) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.calls...)
+}
+
+//nolint:whitespace // can't get different linters happy
+func (r *recordingTarget) RegisterProvider(
+	event *eventv1.Event,
+	track *trackv1.Track,
+	recordingMode providerv1.RecordingMode,
+) (*providerv1.RegisterEventResponse, error) {
+	if err := r.record("register:" + event.Key); err != nil {
+		return nil, err
+	}
+	return &providerv1.RegisterEventResponse{Track: track}, nil
+}
+
+func (r *recordingTarget) UnregisterProvider(eventKey string) error {
+	return r.record("unregister:" + eventKey)
+}
+
+func (r *recordingTarget) PublishState(req *racestatev1.PublishStateRequest) error {
+	return r.record("state")
+}
+
+func (r *recordingTarget) DeleteEvent(eventKey string) error {
+	return r.record("delete:" + eventKey)
+}
+
+func writeSpool(t *testing.T, w *Writer, states int) {
+	t.Helper()
+	_, err := w.RegisterProvider(&eventv1.Event{Key: "ev"}, &trackv1.Track{},
+		providerv1.RecordingMode_RECORDING_MODE_PERSIST)
+	require.NoError(t, err)
+	for range states {
+		require.NoError(t, w.PublishState(&racestatev1.PublishStateRequest{}))
+	}
+	require.NoError(t, w.UnregisterProvider("ev"))
+}
+
+//nolint:whitespace // can't get different linters happy
+func newTestUploader(
+	w *Writer,
+	target *recordingTarget,
+	opts ...UploaderOption,
+) *Uploader {
+	opts = append([]UploaderOption{
+		WithPollInterval(5 * time.Millisecond),
+		WithMaxBackoff(time.Millisecond),
+	}, opts...)
+	return NewUploader(w.Name(), msgimport.NewImporter(target), opts...)
+}
+
+func TestUploader_Resume(t *testing.T) {
+	w, err := Create(t.TempDir())
+	require.NoError(t, err)
+	defer w.Close()
+	writeSpool(t, w, 2)
+
+	// the third message is rejected due to missing credentials
+	first := &recordingTarget{
+		errs: []error{nil, nil, status.Error(codes.Unauthenticated, "no token")},
+	}
+	err = newTestUploader(w, first).Run(context.Background())
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, []string{"register:ev", "state"}, first.received())
+
+	second := &recordingTarget{}
+	require.NoError(t, newTestUploader(w, second).Run(context.Background()))
+	assert.Equal(t, []string{"state", "unregister:ev"}, second.received())
+}
+
+func TestUploader_ResumeStateDelta(t *testing.T) {
+	w, err := Create(t.TempDir(), logger.WithStateDelta(3))
+	require.NoError(t, err)
+	defer w.Close()
+	writeSpool(t, w, 4)
+
+	first := &recordingTarget{
+		errs: []error{nil, nil, nil, status.Error(codes.Unauthenticated, "no token")},
+	}
+	err = newTestUploader(w, first).Run(context.Background())
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// the first message after resuming is a delta.
+	// It can be decoded because the previous messages are passed to the importer.
+	second := &recordingTarget{}
+	require.NoError(t, newTestUploader(w, second).Run(context.Background()))
+	assert.Equal(t, []string{"state", "state", "unregister:ev"}, second.received())
+}
+
+func TestUploader_Retry(t *testing.T) {
+	w, err := Create(t.TempDir())
+	require.NoError(t, err)
+	defer w.Close()
+	writeSpool(t, w, 1)
+
+	target := &recordingTarget{errs: []error{
+		status.Error(codes.Unavailable, "offline"),
+		status.Error(codes.Unavailable, "offline"),
+		nil,
+		status.Error(codes.InvalidArgument, "rejected"), // state is skipped
+	}}
+	require.NoError(t, newTestUploader(w, target).Run(context.Background()))
+	assert.Equal(t, []string{"register:ev", "unregister:ev"}, target.received())
+}
+
+func TestUploader_Follow(t *testing.T) {
+	w, err := Create(t.TempDir())
+	require.NoError(t, err)
+	defer w.Close()
+
+	target := &recordingTarget{}
+	u := newTestUploader(w, target, WithFollow(true))
+	done := make(chan error)
+	go func() { done <- u.Run(context.Background()) }()
+
+	writeSpool(t, w, 3)
+	u.Finish()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("uploader did not finish")
+	}
+	assert.Equal(t,
+		[]string{"register:ev", "state", "state", "state", "unregister:ev"},
+		target.received())
+}
+
+func TestUploader_RotatedGzip(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "grpc.bin")
+	cfg := logger.FileConfig{Compression: logger.CompressionGzip, MaxSize: 1}
+	f, err := logger.CreateFile(fn, cfg)
+	require.NoError(t, err)
+	pub := publisher.NewMsgLog(f, logger.WithStateDelta(3))
+	_, err = pub.RegisterProvider(&eventv1.Event{Key: "ev"}, &trackv1.Track{},
+		providerv1.RecordingMode_RECORDING_MODE_PERSIST)
+	require.NoError(t, err)
+	for range 4 {
+		require.NoError(t, pub.PublishState(&racestatev1.PublishStateRequest{}))
+	}
+	require.NoError(t, pub.UnregisterProvider("ev"))
+	require.NoError(t, f.Close())
+
+	manifest := logger.ManifestName(fn)
+	first := &recordingTarget{
+		errs: []error{nil, nil, nil, status.Error(codes.Unauthenticated, "no token")},
+	}
+	u := NewUploader(manifest, msgimport.NewImporter(first),
+		WithMaxBackoff(time.Millisecond))
+	err = u.Run(context.Background())
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, []string{"register:ev", "state", "state"}, first.received())
+
+	// the position refers to the segment, deleting uploaded segments keeps it valid
+	pos, err := logger.ReadPosition(logger.PositionName(manifest))
+	require.NoError(t, err)
+	m, err := logger.ReadManifest(manifest)
+	require.NoError(t, err)
+	idx := slices.IndexFunc(m.Segments, func(seg *logger.Segment) bool {
+		return seg.File == pos.Segment
+	})
+	require.Greater(t, idx, 1)
+	require.NoError(t, os.Remove(filepath.Join(filepath.Dir(fn), m.Segments[1].File)))
+
+	second := &recordingTarget{}
+	u = NewUploader(manifest, msgimport.NewImporter(second),
+		WithMaxBackoff(time.Millisecond))
+	require.NoError(t, u.Run(context.Background()))
+	assert.Equal(t, []string{"state", "state", "unregister:ev"}, second.received())
+}
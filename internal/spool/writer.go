@@ -0,0 +1,96 @@
+// Package spool provides a durable local store for the messages of a recording
+// and an uploader which forwards them to the backend server once it is reachable.
+//
+// The spool file uses the msg log format, so it may also be imported later by the
+// import command.
+package spool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
+
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+	"github.com/mpapenbr/go-racelogger/pkg/publisher"
+)
+
+const FileExtension = ".rlspool"
+
+// Writer is a publisher which writes all messages to a spool file.
+// Each message is synced to disk before the call returns.
+type Writer struct {
+	f *os.File
+	p publisher.Publisher
+}
+
+var _ publisher.Publisher = (*Writer)(nil)
+
+// Create creates a new spool file in dir. The directory is created if needed.
+// The opts are passed to the msg logger writing the file.
+func Create(dir string, opts ...logger.Option) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	fn := filepath.Join(dir,
+		fmt.Sprintf("racelogger-%s%s", time.Now().Format("20060102-150405"), FileExtension))
+	f, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{f: f, p: publisher.NewMsgLog(f, opts...)}, nil
+}
+
+// Name returns the name of the spool file
+func (w *Writer) Name() string {
+	return w.f.Name()
+}
+
+func (w *Writer) Close() error {
+	return w.f.Close()
+}
+
+//nolint:whitespace // can't get different linters happy
+func (w *Writer) RegisterProvider(
+	event *eventv1.Event,
+	track *trackv1.Track,
+	recordingMode providerv1.RecordingMode,
+) (*providerv1.RegisterEventResponse, error) {
+	resp, err := w.p.RegisterProvider(event, track, recordingMode)
+	return resp, w.sync(err)
+}
+
+func (w *Writer) UnregisterProvider(eventKey string) error {
+	return w.sync(w.p.UnregisterProvider(eventKey))
+}
+
+func (w *Writer) PublishState(req *racestatev1.PublishStateRequest) error {
+	return w.sync(w.p.PublishState(req))
+}
+
+func (w *Writer) PublishDriverData(req *racestatev1.PublishDriverDataRequest) error {
+	return w.sync(w.p.PublishDriverData(req))
+}
+
+func (w *Writer) PublishSpeedmap(req *racestatev1.PublishSpeedmapRequest) error {
+	return w.sync(w.p.PublishSpeedmap(req))
+}
+
+//nolint:whitespace // can't get different linters happy
+func (w *Writer) PublishEventExtraInfo(
+	req *racestatev1.PublishEventExtraInfoRequest,
+) error {
+	return w.sync(w.p.PublishEventExtraInfo(req))
+}
+
+func (w *Writer) sync(err error) error {
+	if err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
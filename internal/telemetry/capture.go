@@ -0,0 +1,461 @@
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// The capture file stores the raw processor inputs for every tick.
+// Layout:
+//
+//	magic "RLTC" + version byte
+//	records: recordType byte, payload length (uvarint), payload
+//
+// Record types:
+//   - vars: describes the layout of the following tick records
+//     (num vars, then per var: name, kind, count)
+//   - yaml: the raw yaml string (written whenever it changes)
+//   - tick: the values of all vars (little endian, in order of the vars record)
+//
+// A vars record may appear multiple times (e.g. when appending another
+// heat to an existing file). It replaces the previous layout.
+
+const (
+	captureMagic   = "RLTC"
+	captureVersion = byte(1)
+	// limit for the payload of a record, protects against corrupt length fields
+	maxCaptureRecordLen = 64 << 20
+)
+
+const (
+	recVars byte = iota + 1
+	recYaml
+	recTick
+)
+
+type varKind byte
+
+const (
+	kindInt32 varKind = iota + 1
+	kindFloat32
+	kindFloat64
+	kindBool
+)
+
+var (
+	ErrInvalidCaptureFile = errors.New("invalid capture file")
+	ErrUnsupportedVarType = errors.New("unsupported var type")
+)
+
+// CaptureVars contains the telemetry variables the processor reads.
+// These are written to the capture file on each tick.
+var CaptureVars = []string{
+	"SessionNum",
+	"SessionTime",
+	"SessionTimeRemain",
+	"SessionLapsRemainEx",
+	"SessionTimeOfDay",
+	"SessionState",
+	"SessionFlags",
+	"AirTemp",
+	"AirDensity",
+	"AirPressure",
+	"TrackTempCrew",
+	"WindDir",
+	"WindVel",
+	"TrackWetness",
+	"Precipitation",
+	"CarIdxLapDistPct",
+	"CarIdxTrackSurface",
+	"CarIdxPosition",
+	"CarIdxClassPosition",
+	"CarIdxLap",
+	"CarIdxLapCompleted",
+	"CarIdxOnPitRoad",
+	"CarIdxTireCompound",
+}
+
+// ValueSource is used by the CaptureWriter to read the current values
+type ValueSource interface {
+	GetValue(name string) (any, error)
+	GetYamlString() string
+}
+
+type varDef struct {
+	name  string
+	kind  varKind
+	count int // 0 means scalar value
+}
+
+type (
+	CaptureWriter struct {
+		w        *bufio.Writer
+		vars     []string
+		layout   []varDef
+		lastYaml string
+		ticks    int
+		buf      bytes.Buffer
+		closer   io.Closer
+	}
+	CaptureOption func(*CaptureWriter)
+)
+
+func WithCaptureVars(vars []string) CaptureOption {
+	return func(cw *CaptureWriter) {
+		cw.vars = vars
+	}
+}
+
+// NewCaptureWriter creates a writer for capture data.
+// The file header is written if the writer is positioned at the beginning
+// (pass writeHeader=false when appending to an existing capture file)
+func NewCaptureWriter(w io.Writer, writeHeader bool, opts ...CaptureOption) (*CaptureWriter, error) {
+	ret := &CaptureWriter{w: bufio.NewWriter(w), vars: CaptureVars}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	if writeHeader {
+		if _, err := ret.w.WriteString(captureMagic); err != nil {
+			return nil, err
+		}
+		if err := ret.w.WriteByte(captureVersion); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// OpenCaptureFile opens (or creates) a capture file for appending.
+// The file header is written only for new (empty) files.
+func OpenCaptureFile(name string, opts ...CaptureOption) (*CaptureWriter, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ret, err := NewCaptureWriter(f, fi.Size() == 0, opts...)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ret.closer = f
+	return ret, nil
+}
+
+// WriteTick writes the current values of src to the capture.
+// The yaml is written only if it changed since the last call.
+func (cw *CaptureWriter) WriteTick(src ValueSource) error {
+	values := make([]any, 0, len(cw.vars))
+	layout := make([]varDef, 0, len(cw.vars))
+	for _, name := range cw.vars {
+		v, err := src.GetValue(name)
+		if err != nil {
+			continue // var not provided by this source
+		}
+		def, err := describeValue(name, v)
+		if err != nil {
+			return err
+		}
+		values = append(values, v)
+		layout = append(layout, def)
+	}
+	if !sameLayout(layout, cw.layout) {
+		if err := cw.writeLayout(layout); err != nil {
+			return err
+		}
+	}
+	if y := src.GetYamlString(); y != cw.lastYaml {
+		if err := cw.writeRecord(recYaml, []byte(y)); err != nil {
+			return err
+		}
+		cw.lastYaml = y
+	}
+	cw.buf.Reset()
+	for _, v := range values {
+		if err := binary.Write(&cw.buf, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	if err := cw.writeRecord(recTick, cw.buf.Bytes()); err != nil {
+		return err
+	}
+	cw.ticks++
+	return nil
+}
+
+// Flush writes buffered data to the underlying writer
+func (cw *CaptureWriter) Flush() error {
+	return cw.w.Flush()
+}
+
+// Close flushes the buffered data and closes the underlying file
+// (if the writer was created by OpenCaptureFile)
+func (cw *CaptureWriter) Close() error {
+	if err := cw.Flush(); err != nil {
+		return err
+	}
+	if cw.closer != nil {
+		return cw.closer.Close()
+	}
+	return nil
+}
+
+func (cw *CaptureWriter) Ticks() int {
+	return cw.ticks
+}
+
+func (cw *CaptureWriter) writeLayout(layout []varDef) error {
+	var b bytes.Buffer
+	b.Write(binary.AppendUvarint(nil, uint64(len(layout))))
+	for _, d := range layout {
+		b.Write(binary.AppendUvarint(nil, uint64(len(d.name))))
+		b.WriteString(d.name)
+		b.WriteByte(byte(d.kind))
+		b.Write(binary.AppendUvarint(nil, uint64(d.count)))
+	}
+	if err := cw.writeRecord(recVars, b.Bytes()); err != nil {
+		return err
+	}
+	cw.layout = layout
+	return nil
+}
+
+func (cw *CaptureWriter) writeRecord(t byte, payload []byte) error {
+	if err := cw.w.WriteByte(t); err != nil {
+		return err
+	}
+	if _, err := cw.w.Write(binary.AppendUvarint(nil, uint64(len(payload)))); err != nil {
+		return err
+	}
+	_, err := cw.w.Write(payload)
+	return err
+}
+
+//nolint:gocritic // by design
+func describeValue(name string, v any) (varDef, error) {
+	switch c := v.(type) {
+	case int32:
+		return varDef{name: name, kind: kindInt32}, nil
+	case float32:
+		return varDef{name: name, kind: kindFloat32}, nil
+	case float64:
+		return varDef{name: name, kind: kindFloat64}, nil
+	case bool:
+		return varDef{name: name, kind: kindBool}, nil
+	case []int32:
+		return varDef{name: name, kind: kindInt32, count: len(c)}, nil
+	case []float32:
+		return varDef{name: name, kind: kindFloat32, count: len(c)}, nil
+	case []float64:
+		return varDef{name: name, kind: kindFloat64, count: len(c)}, nil
+	case []bool:
+		return varDef{name: name, kind: kindBool, count: len(c)}, nil
+	}
+	return varDef{}, fmt.Errorf("%w: %s (%T)", ErrUnsupportedVarType, name, v)
+}
+
+func sameLayout(a, b []varDef) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type CaptureReader struct {
+	r      *bufio.Reader
+	layout []varDef
+	ticks  int
+}
+
+// NewCaptureReader creates a reader for capture data. The file header is validated.
+func NewCaptureReader(r io.Reader) (*CaptureReader, error) {
+	ret := &CaptureReader{r: bufio.NewReader(r)}
+	header := make([]byte, len(captureMagic)+1)
+	if _, err := io.ReadFull(ret.r, header); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCaptureFile, err)
+	}
+	if string(header[:len(captureMagic)]) != captureMagic {
+		return nil, fmt.Errorf("%w: unknown magic", ErrInvalidCaptureFile)
+	}
+	if header[len(captureMagic)] != captureVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d",
+			ErrInvalidCaptureFile, header[len(captureMagic)])
+	}
+	return ret, nil
+}
+
+// Next reads the next tick and stores the values (and yaml, if changed) in dst.
+// Returns io.EOF if no more ticks are available.
+func (cr *CaptureReader) Next(dst *Memory) error {
+	for {
+		t, payload, err := cr.readRecord()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case recVars:
+			if cr.layout, err = decodeLayout(payload); err != nil {
+				return err
+			}
+		case recYaml:
+			if err := dst.SetYamlString(string(payload)); err != nil {
+				return err
+			}
+		case recTick:
+			values, err := decodeTick(cr.layout, payload)
+			if err != nil {
+				return err
+			}
+			dst.SetValues(values)
+			cr.ticks++
+			return nil
+		default:
+			return fmt.Errorf("%w: unknown record type %d", ErrInvalidCaptureFile, t)
+		}
+	}
+}
+
+func (cr *CaptureReader) Ticks() int {
+	return cr.ticks
+}
+
+func (cr *CaptureReader) readRecord() (t byte, payload []byte, err error) {
+	t, err = cr.r.ReadByte()
+	if err != nil {
+		return 0, nil, err // io.EOF on regular end of file
+	}
+	l, err := binary.ReadUvarint(cr.r)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %w", ErrInvalidCaptureFile, io.ErrUnexpectedEOF)
+	}
+	if l > maxCaptureRecordLen {
+		return 0, nil, fmt.Errorf("%w: record too large (%d bytes)", ErrInvalidCaptureFile, l)
+	}
+	payload = make([]byte, l)
+	if _, err := io.ReadFull(cr.r, payload); err != nil {
+		return 0, nil, fmt.Errorf("%w: %w", ErrInvalidCaptureFile, io.ErrUnexpectedEOF)
+	}
+	return t, payload, nil
+}
+
+func decodeLayout(payload []byte) ([]varDef, error) {
+	r := bytes.NewReader(payload)
+	num, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, ErrInvalidCaptureFile
+	}
+	// each var needs at least 3 bytes (name length, kind, count)
+	if num > uint64(r.Len())/3 {
+		return nil, ErrInvalidCaptureFile
+	}
+	ret := make([]varDef, 0, num)
+	for range num {
+		l, err := binary.ReadUvarint(r)
+		if err != nil || l > uint64(r.Len()) {
+			return nil, ErrInvalidCaptureFile
+		}
+		name := make([]byte, l)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return nil, ErrInvalidCaptureFile
+		}
+		kind, err := r.ReadByte()
+		if err != nil {
+			return nil, ErrInvalidCaptureFile
+		}
+		count, err := binary.ReadUvarint(r)
+		// the values of a var must fit into a tick record
+		if err != nil || count > maxCaptureRecordLen {
+			return nil, ErrInvalidCaptureFile
+		}
+		ret = append(ret, varDef{name: string(name), kind: varKind(kind), count: int(count)})
+	}
+	return ret, nil
+}
+
+//nolint:cyclop // by design
+func decodeTick(layout []varDef, payload []byte) (map[string]any, error) {
+	ret := make(map[string]any, len(layout))
+	pos := 0
+	next := func(size int) ([]byte, error) {
+		if pos+size > len(payload) {
+			return nil, fmt.Errorf("%w: tick too short", ErrInvalidCaptureFile)
+		}
+		b := payload[pos : pos+size]
+		pos += size
+		return b, nil
+	}
+	for _, d := range layout {
+		n := max(d.count, 1)
+		var size int
+		switch d.kind {
+		case kindInt32, kindFloat32:
+			size = 4
+		case kindFloat64:
+			size = 8
+		case kindBool:
+			size = 1
+		default:
+			return nil, fmt.Errorf("%w: unknown kind %d", ErrInvalidCaptureFile, d.kind)
+		}
+		b, err := next(n * size)
+		if err != nil {
+			return nil, err
+		}
+		ret[d.name] = decodeValues(d, b)
+	}
+	return ret, nil
+}
+
+//nolint:exhaustive // by design
+func decodeValues(d varDef, b []byte) any {
+	n := max(d.count, 1)
+	switch d.kind {
+	case kindInt32:
+		v := make([]int32, n)
+		for i := range v {
+			v[i] = int32(binary.LittleEndian.Uint32(b[i*4:]))
+		}
+		return scalarOrSlice(d, v)
+	case kindFloat32:
+		v := make([]float32, n)
+		for i := range v {
+			v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+		}
+		return scalarOrSlice(d, v)
+	case kindFloat64:
+		v := make([]float64, n)
+		for i := range v {
+			v[i] = math.Float64frombits(binary.LittleEndian.Uint64(b[i*8:]))
+		}
+		return scalarOrSlice(d, v)
+	case kindBool:
+		v := make([]bool, n)
+		for i := range v {
+			v[i] = b[i] != 0
+		}
+		return scalarOrSlice(d, v)
+	}
+	return nil
+}
+
+func scalarOrSlice[T any](d varDef, v []T) any {
+	if d.count == 0 {
+		return v[0]
+	}
+	return v
+}
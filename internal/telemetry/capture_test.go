@@ -0,0 +1,110 @@
+package telemetry
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mpapenbr/goirsdk/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapture_RoundTrip(t *testing.T) {
+	src := NewMemory()
+	assert.NoError(t, src.SetYaml(&yaml.IrsdkYaml{
+		WeekendInfo: yaml.WeekendInfo{TrackID: 1},
+	}))
+	src.SetValues(map[string]any{
+		"SessionNum":       int32(2),
+		"SessionTime":      float64(10.5),
+		"AirTemp":          float32(21.5),
+		"CarIdxLap":        []int32{1, 2},
+		"CarIdxLapDistPct": []float32{0.1, 0.2},
+		"CarIdxOnPitRoad":  []bool{false, true},
+	})
+
+	var buf bytes.Buffer
+	cw, err := NewCaptureWriter(&buf, true)
+	assert.NoError(t, err)
+	assert.NoError(t, cw.WriteTick(src))
+	src.SetValue("SessionTime", float64(10.6))
+	assert.NoError(t, cw.WriteTick(src))
+	assert.NoError(t, cw.Flush())
+	assert.Equal(t, 2, cw.Ticks())
+
+	cr, err := NewCaptureReader(&buf)
+	assert.NoError(t, err)
+	dst := NewMemory()
+
+	assert.NoError(t, cr.Next(dst))
+	assert.Equal(t, 1, dst.GetLatestYaml().WeekendInfo.TrackID)
+	d, _ := dst.GetDoubleValue("SessionTime")
+	assert.Equal(t, 10.5, d)
+	i, _ := dst.GetIntValue("SessionNum")
+	assert.Equal(t, int32(2), i)
+	f, _ := dst.GetFloatValue("AirTemp")
+	assert.Equal(t, float32(21.5), f)
+	laps, _ := dst.GetIntValues("CarIdxLap")
+	assert.Equal(t, []int32{1, 2}, laps)
+	pct, _ := dst.GetFloatValues("CarIdxLapDistPct")
+	assert.Equal(t, []float32{0.1, 0.2}, pct)
+	pit, _ := dst.GetBoolValues("CarIdxOnPitRoad")
+	assert.Equal(t, []bool{false, true}, pit)
+
+	assert.NoError(t, cr.Next(dst))
+	d, _ = dst.GetDoubleValue("SessionTime")
+	assert.Equal(t, 10.6, d)
+
+	assert.True(t, errors.Is(cr.Next(dst), io.EOF))
+	assert.Equal(t, 2, cr.Ticks())
+}
+
+func TestCapture_Append(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.rlcap")
+	src := NewMemory()
+	src.SetValue("SessionNum", int32(1))
+
+	for range 2 {
+		cw, err := OpenCaptureFile(name)
+		assert.NoError(t, err)
+		assert.NoError(t, cw.WriteTick(src))
+		assert.NoError(t, cw.Close())
+		src.SetValue("SessionNum", int32(2))
+	}
+
+	data, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	cr, err := NewCaptureReader(bytes.NewReader(data))
+	assert.NoError(t, err)
+	dst := NewMemory()
+	for _, want := range []int32{1, 2} {
+		assert.NoError(t, cr.Next(dst))
+		i, _ := dst.GetIntValue("SessionNum")
+		assert.Equal(t, want, i)
+	}
+	assert.True(t, errors.Is(cr.Next(dst), io.EOF))
+}
+
+func TestCapture_InvalidHeader(t *testing.T) {
+	_, err := NewCaptureReader(bytes.NewReader([]byte("XXXX\x01")))
+	assert.ErrorIs(t, err, ErrInvalidCaptureFile)
+}
+
+func TestCapture_CorruptLength(t *testing.T) {
+	header := captureMagic + string(captureVersion)
+	for name, data := range map[string]string{
+		// payload length of 1<<62 bytes
+		"record": header + "\x03\x80\x80\x80\x80\x80\x80\x80\x80\x40",
+		// 1<<62 vars in a vars record of 9 bytes
+		"vars": header + "\x01\x09\x80\x80\x80\x80\x80\x80\x80\x80\x40",
+		// one var with a name of 127 bytes in a vars record of 4 bytes
+		"name": header + "\x01\x04\x01\x7f\x01\x00",
+	} {
+		cr, err := NewCaptureReader(bytes.NewReader([]byte(data)))
+		assert.NoError(t, err, name)
+		assert.ErrorIs(t, cr.Next(NewMemory()), ErrInvalidCaptureFile, name)
+	}
+}
@@ -0,0 +1,157 @@
+package telemetry
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/mpapenbr/goirsdk/yaml"
+	goyaml "gopkg.in/yaml.v3"
+)
+
+// Memory is an in-memory telemetry source.
+// Values are provided by the caller via SetValue/SetYaml.
+// It mimics the value access of irsdk.Irsdk and can be used
+// whenever no running iRacing instance is available (tests, replays)
+type Memory struct {
+	mu         sync.RWMutex
+	values     map[string]any
+	yaml       yaml.IrsdkYaml
+	yamlString string
+}
+
+func NewMemory() *Memory {
+	return &Memory{values: make(map[string]any)}
+}
+
+// SetValue stores a telemetry value.
+// Supported types are the ones provided by iRacing:
+// int32, float32, float64, bool and slices of them.
+func (m *Memory) SetValue(name string, v any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[name] = v
+}
+
+// SetValues stores multiple telemetry values at once
+func (m *Memory) SetValues(values map[string]any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, v := range values {
+		m.values[k] = v
+	}
+}
+
+// SetYaml stores the yaml data. The yaml string is computed from y.
+func (m *Memory) SetYaml(y *yaml.IrsdkYaml) error {
+	out, err := goyaml.Marshal(y)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.yaml = *y
+	m.yamlString = string(out)
+	return nil
+}
+
+// SetYamlString stores the raw yaml string (as delivered by iRacing).
+func (m *Memory) SetYamlString(s string) error {
+	var y yaml.IrsdkYaml
+	if err := goyaml.Unmarshal([]byte(s), &y); err != nil {
+		if err := goyaml.Unmarshal([]byte(m.RepairedYaml(s)), &y); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.yaml = y
+	m.yamlString = s
+	return nil
+}
+
+func (m *Memory) GetLatestYaml() *yaml.IrsdkYaml {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return &m.yaml
+}
+
+func (m *Memory) GetYamlString() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.yamlString
+}
+
+// replaces the yaml team and user name with a quoted string
+// (same logic as in irsdk)
+func (m *Memory) RepairedYaml(s string) string {
+	work := s
+	for _, key := range []string{"TeamName", "UserName"} {
+		re := regexp.MustCompile(fmt.Sprintf("%s: (.*)", key))
+		work = re.ReplaceAllString(work, fmt.Sprintf("%s: \"$1\"", key))
+	}
+	return work
+}
+
+func (m *Memory) GetValueKeys() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ret := make([]string, 0, len(m.values))
+	for k := range m.values {
+		ret = append(ret, k)
+	}
+	return ret
+}
+
+func (m *Memory) GetValue(name string) (any, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if v, ok := m.values[name]; ok {
+		return v, nil
+	}
+	return nil, ErrUnknownVar
+}
+
+func (m *Memory) GetIntValue(name string) (int32, error) {
+	return getTyped[int32](m, name)
+}
+
+func (m *Memory) GetIntValues(name string) ([]int32, error) {
+	return getTyped[[]int32](m, name)
+}
+
+func (m *Memory) GetFloatValue(name string) (float32, error) {
+	return getTyped[float32](m, name)
+}
+
+func (m *Memory) GetFloatValues(name string) ([]float32, error) {
+	return getTyped[[]float32](m, name)
+}
+
+func (m *Memory) GetDoubleValue(name string) (float64, error) {
+	return getTyped[float64](m, name)
+}
+
+func (m *Memory) GetDoubleValues(name string) ([]float64, error) {
+	return getTyped[[]float64](m, name)
+}
+
+func (m *Memory) GetBoolValue(name string) (bool, error) {
+	return getTyped[bool](m, name)
+}
+
+func (m *Memory) GetBoolValues(name string) ([]bool, error) {
+	return getTyped[[]bool](m, name)
+}
+
+func getTyped[T any](m *Memory, name string) (T, error) {
+	var zero T
+	v, err := m.GetValue(name)
+	if err != nil {
+		return zero, err
+	}
+	if ret, ok := v.(T); ok {
+		return ret, nil
+	}
+	return zero, ErrNoMatchingDataType
+}
@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mpapenbr/goirsdk/yaml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory_GetValues(t *testing.T) {
+	m := NewMemory()
+	m.SetValues(map[string]any{
+		"SessionNum":       int32(1),
+		"SessionTime":      float64(12.5),
+		"AirTemp":          float32(21.0),
+		"CarIdxLap":        []int32{1, 2},
+		"CarIdxLapDistPct": []float32{0.1, 0.2},
+		"CarIdxOnPitRoad":  []bool{false, true},
+	})
+
+	i, err := m.GetIntValue("SessionNum")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), i)
+
+	d, err := m.GetDoubleValue("SessionTime")
+	assert.NoError(t, err)
+	assert.Equal(t, 12.5, d)
+
+	f, err := m.GetFloatValue("AirTemp")
+	assert.NoError(t, err)
+	assert.Equal(t, float32(21.0), f)
+
+	laps, err := m.GetIntValues("CarIdxLap")
+	assert.NoError(t, err)
+	assert.Equal(t, []int32{1, 2}, laps)
+
+	pct, err := m.GetFloatValues("CarIdxLapDistPct")
+	assert.NoError(t, err)
+	assert.Equal(t, []float32{0.1, 0.2}, pct)
+
+	pit, err := m.GetValue("CarIdxOnPitRoad")
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false, true}, pit)
+
+	_, err = m.GetIntValue("SessionTime")
+	assert.True(t, errors.Is(err, ErrNoMatchingDataType))
+
+	_, err = m.GetIntValue("Unknown")
+	assert.True(t, errors.Is(err, ErrUnknownVar))
+}
+
+func TestMemory_Yaml(t *testing.T) {
+	m := NewMemory()
+	y := yaml.IrsdkYaml{
+		DriverInfo: yaml.DriverInfo{
+			Drivers: []yaml.Drivers{{CarIdx: 1, UserName: "A"}},
+		},
+	}
+	assert.NoError(t, m.SetYaml(&y))
+	assert.Equal(t, "A", m.GetLatestYaml().DriverInfo.Drivers[0].UserName)
+
+	// names are not quoted by iRacing
+	raw := `
+DriverInfo:
+  Drivers:
+  - CarIdx: 2
+    UserName: B: the driver
+`
+	assert.NoError(t, m.SetYamlString(raw))
+	assert.Equal(t, raw, m.GetYamlString())
+	assert.Equal(t, "B: the driver", m.GetLatestYaml().DriverInfo.Drivers[0].UserName)
+}
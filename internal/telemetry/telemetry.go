@@ -0,0 +1,72 @@
+// Package telemetry contains the iRacing telemetry vocabulary used by the
+// processor which does not depend on a running simulation.
+// The values mirror the ones defined in github.com/mpapenbr/goirsdk/irsdk.
+// That package is only available on Windows, so we keep our own copy here.
+package telemetry
+
+import "errors"
+
+type (
+	SessionState  int32
+	Flags         int64
+	TrackLocation int32
+)
+
+var (
+	ErrUnknownVar         = errors.New("unknown telemetry variable")
+	ErrNoMatchingDataType = errors.New("no matching data type")
+)
+
+const (
+	FlagCheckered Flags = 1 << iota
+	FlagWhite
+	FlagGreen
+	FlagYello
+	FlagRed
+	FlagBlue
+	FlagDebris
+	FlagCrossed
+	FlagYellowWaving
+	FlagOneLapToGreen
+	FlagGreenHeld
+	FlagTenToGo
+	FlagFiveToGo
+	FlagRandomWaving
+	FlagCaution
+	FlagCautionWaving
+
+	// start lights
+	FlagStartHidden Flags = 0x10000000
+	FlagStartReady  Flags = 0x20000000
+	FlagStartSet    Flags = 0x40000000
+	FlagStartGo     Flags = 0x80000000
+)
+
+const (
+	StateInvalid SessionState = iota
+	StateGetInCar
+	StateWarmup
+	StateParadeLaps
+	StateRacing
+	StateCheckered
+	StateCoolDown
+)
+
+const (
+	TrackWetnessUnknown = iota
+	TrackWetnessDry
+	TrackWetnessMostlyDry
+	TrackWetnessVeryLightlyWet
+	TrackWetnessLightlyWet
+	TrackWetnessModeratelyWet
+	TrackWetnessVeryWet
+	TrackWetnessExtremeWet
+)
+
+const (
+	TrackLocationNotInWorld TrackLocation = iota - 1
+	TrackLocationOffTrack
+	TrackLocationInPitStall
+	TrackLocationAproachingPits
+	TrackLocationOnTrack
+)
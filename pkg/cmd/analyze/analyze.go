@@ -0,0 +1,84 @@
+package analyze
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mpapenbr/go-racelogger/internal/analyze"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+var (
+	format   = "json"
+	output   = ""
+	eventKey = ""
+)
+
+var ErrUnknownFormat = errors.New("unknown format")
+
+var writers = map[string]func(io.Writer, *analyze.Result) error{
+	"json":       analyze.WriteJSON,
+	"laps-csv":   analyze.WriteLapsCSV,
+	"stints-csv": analyze.WriteStintsCSV,
+}
+
+func NewAnalyzeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "analyze <file>",
+		Short: "compute lap histories and stints from a msg log",
+		Long: `Rebuilds the lap history of each car from the state messages of a msg log.
+Laps contain the lap time, position, stint and whether it was an in or out lap.
+Stints are split at pit stops.
+Formats: json (laps and stints), laps-csv, stints-csv`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			write, ok := writers[format]
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+			}
+			cmd.SilenceUsage = true
+			return doAnalyze(cmd.OutOrStdout(), args[0], write)
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "json",
+		"output format (json, laps-csv, stints-csv)")
+	cmd.Flags().StringVar(&output, "output", "",
+		"write the result to this file (default: stdout)")
+	cmd.Flags().StringVar(&eventKey, "event", "",
+		"analyze this event (default: the first event of the file)")
+	return cmd
+}
+
+//nolint:whitespace // can't get different linters happy
+func doAnalyze(
+	stdout io.Writer,
+	fn string,
+	write func(io.Writer, *analyze.Result) error,
+) error {
+	file, err := logger.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	res, err := analyze.Analyze(bufio.NewReader(file), analyze.WithEventKey(eventKey))
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		return write(stdout, res)
+	}
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := write(out, res); err != nil {
+		out.Close() //nolint:errcheck // the write error is reported
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,96 @@
+package capture
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/mpapenbr/goirsdk/irsdk"
+	"github.com/spf13/cobra"
+
+	"github.com/mpapenbr/go-racelogger/internal/telemetry"
+	"github.com/mpapenbr/go-racelogger/log"
+	"github.com/mpapenbr/go-racelogger/pkg/config"
+	"github.com/mpapenbr/go-racelogger/pkg/util"
+)
+
+func NewCaptureCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "capture capture-file",
+		Short: "capture raw iRacing telemetry data to a file",
+		Long: `Captures the telemetry data used by the processor on every tick.
+No data is sent to the backend. The file may be used for offline replays.
+If the file already exists, data is appended.
+Use the --capture-file option of the record command to capture while recording.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return captureTelemetry(cmd.Context(), config.DefaultCliArgs(), args[0])
+		},
+	}
+
+	cmd.Flags().StringVar(&config.DefaultCliArgs().WaitForServices,
+		"wait",
+		"60s",
+		"Wait for running iRacing Sim")
+	cmd.Flags().StringVar(&config.DefaultCliArgs().WaitForData,
+		"wait-for-data",
+		"1s",
+		"Timeout to wait for irsdk to signal valid data")
+	return cmd
+}
+
+//nolint:funlen // by design
+func captureTelemetry(cmdCtx context.Context, cfg *config.CliArgs, fileName string) error {
+	logger := log.GetFromContext(cmdCtx).Named("capture")
+	if ok := util.WaitForSimulation(cfg); !ok {
+		logger.Error("Simulation not running")
+		return nil
+	}
+	waitForData, err := time.ParseDuration(cfg.WaitForData)
+	if err != nil {
+		logger.Warn("Invalid duration value. Setting default 1s", log.ErrorField(err))
+		waitForData = time.Second
+	}
+
+	cw, err := telemetry.OpenCaptureFile(fileName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := cw.Close(); err != nil {
+			logger.Warn("Could not close capture file", log.ErrorField(err))
+		}
+	}()
+
+	api := irsdk.NewIrsdk()
+	defer api.Close()
+	api.WaitForValidData()
+
+	ctx, stop := signal.NotifyContext(cmdCtx, os.Interrupt)
+	defer stop()
+
+	logger.Info("Capturing telemetry data", log.String("file", fileName))
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Capture terminated", log.Int("ticks", cw.Ticks()))
+			return nil
+		default:
+			if !api.GetDataWithDataReadyTimeout(waitForData) {
+				simAvail, err := irsdk.IsSimRunning(ctx, http.DefaultClient)
+				if err == nil && !simAvail {
+					logger.Info("Sim is not running. Capture terminated",
+						log.Int("ticks", cw.Ticks()))
+					return nil
+				}
+				logger.Debug("no new data available")
+				continue
+			}
+			if err := cw.WriteTick(api); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package logimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"os/signal"
+	"slices"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/mpapenbr/go-racelogger/internal/inspect"
+	"github.com/mpapenbr/go-racelogger/internal/msgimport"
+	"github.com/mpapenbr/go-racelogger/internal/spool"
+	owngrpc "github.com/mpapenbr/go-racelogger/pkg/grpc"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/standin"
+)
+
+var ErrDryRunFailed = errors.New("dry run failed")
+
+// doDryRun validates the records of the file and imports it into a local
+// stand-in backend. No checkpoint is used.
+func doDryRun(cmdCtx context.Context, fn string) error {
+	f, err := logger.Open(fn)
+	if err != nil {
+		return err
+	}
+	v := inspect.Validate(f)
+	f.Close()
+	v.Print(os.Stdout)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	srv := standin.New()
+	gs := grpc.NewServer()
+	srv.Register(gs)
+	go func() { _ = gs.Serve(lis) }()
+	defer gs.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, stop := signal.NotifyContext(cmdCtx, os.Interrupt)
+	defer stop()
+	dpc := owngrpc.NewDataProviderClient(owngrpc.WithConnection(conn))
+	// as fast as possible
+	u := spool.NewUploader(fn, msgimport.NewImporter(dpc, importerOptions(ctx, 0)...),
+		spool.WithOffsetFile(""))
+	runErr := u.Run(ctx)
+
+	counts := map[string]int{}
+	for _, msg := range srv.Received() {
+		counts[string(msg.ProtoReflect().Descriptor().Name())]++
+	}
+	fmt.Printf("Imported  : %d\n", len(srv.Received()))
+	for _, name := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Printf("  %-30s %d\n", name, counts[name])
+	}
+	fmt.Printf("Rejected  : %d\n", srv.Rejected())
+	if runErr != nil {
+		fmt.Printf("Error     : %v\n", runErr)
+	}
+	if v.Verdict != inspect.VerdictOK || srv.Rejected() > 0 || runErr != nil {
+		return ErrDryRunFailed
+	}
+	fmt.Println("Dry run OK")
+	return nil
+}
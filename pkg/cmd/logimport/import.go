@@ -1,40 +1,55 @@
 package logimport
 
 import (
-	"bufio"
+	"context"
 	"errors"
-	"io"
 	"os"
+	"os/signal"
+	"time"
 
-	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
 	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
-	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
 	"github.com/spf13/cobra"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/reflect/protoreflect"
 
+	"github.com/mpapenbr/go-racelogger/internal/msgimport"
+	"github.com/mpapenbr/go-racelogger/internal/spool"
 	"github.com/mpapenbr/go-racelogger/log"
 	"github.com/mpapenbr/go-racelogger/pkg/config"
 	owngrpc "github.com/mpapenbr/go-racelogger/pkg/grpc"
-	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
 	"github.com/mpapenbr/go-racelogger/pkg/util"
 )
 
+// the progress of an import is stored in <file>.checkpoint
+const checkpointExtension = ".checkpoint"
+
 var (
 	eventKey    = ""
 	replaceData = false
+	pace        = msgimport.PaceMax
+	from        time.Duration
+	to          time.Duration
+	dryRun      = false
+	restart     = false
 )
 
 func NewImportCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "import <file>",
 		Short: "import race from previous logged grpc messages file",
-		Args:  cobra.ExactArgs(1),
+		Long: `Imports the messages of a msg log file.
+The progress is stored in <file>.checkpoint. An interrupted import continues
+at the last message acknowledged by the server.`,
+		Args: cobra.ExactArgs(1),
 
-		Run: func(cmd *cobra.Command, args []string) {
-			doImport(args[0])
+		RunE: func(cmd *cobra.Command, args []string) error {
+			speed, err := msgimport.ParsePace(pace)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			if dryRun {
+				return doDryRun(cmd.Context(), args[0])
+			}
+			return doImport(cmd.Context(), args[0], speed)
 		},
 	}
 	cmd.Flags().StringVarP(&config.DefaultCliArgs().Token,
@@ -54,121 +69,79 @@ func NewImportCmd() *cobra.Command {
 		"replace-data",
 		false,
 		"replace existing data on server with imported data")
+	cmd.Flags().StringVar(&pace,
+		"pace",
+		msgimport.PaceMax,
+		"send the messages paced by their timestamps (realtime, Nx (e.g. 2x), max)")
+	cmd.Flags().DurationVar(&from,
+		"from",
+		0,
+		"import only data from this session time on (e.g. 1h30m)")
+	cmd.Flags().DurationVar(&to,
+		"to",
+		0,
+		"import only data up to this session time (default: no limit)")
+	cmd.Flags().BoolVar(&dryRun,
+		"dry-run",
+		false,
+		"validate the file and import it into a local stand-in backend")
+	cmd.Flags().BoolVar(&restart,
+		"restart",
+		false,
+		"ignore the checkpoint of a previous import and start from the beginning")
 
 	return cmd
 }
 
-type importProc struct {
-	conn          *grpc.ClientConn
-	f             *os.File
-	m             *logger.MsgLogger
-	dpc           *owngrpc.DataProviderClient
-	recordingMode providerv1.RecordingMode
-	replaceData   bool
-	eventKey      string
-}
-
-func newImportProc(conn *grpc.ClientConn, f *os.File) *importProc {
-	ret := &importProc{
-		conn:          conn,
-		f:             f,
-		recordingMode: providerv1.RecordingMode_RECORDING_MODE_PERSIST,
+func importerOptions(ctx context.Context, speed float64) []msgimport.Option {
+	opts := []msgimport.Option{
+		msgimport.WithReplaceData(replaceData),
+		msgimport.WithEventKey(eventKey),
+		msgimport.WithContext(ctx),
+		msgimport.WithPace(speed),
+		msgimport.WithSessionTimeWindow(from, to),
 	}
-	ret.m = logger.NewMsgLogger(logger.WithReader(bufio.NewReader(f)))
-	ret.dpc = owngrpc.NewDataProviderClient(
-		owngrpc.WithConnection(conn),
-		owngrpc.WithToken(config.DefaultCliArgs().Token),
-	)
-	return ret
+	if config.DefaultCliArgs().DoNotPersist {
+		opts = append(opts, msgimport.WithRecordingMode(
+			providerv1.RecordingMode_RECORDING_MODE_DO_NOT_PERSIST))
+	}
+	return opts
 }
 
-func doImport(fn string) {
+func doImport(cmdCtx context.Context, fn string, speed float64) error {
+	if _, err := os.Stat(fn); err != nil {
+		return err
+	}
 	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
 	if err != nil {
 		log.Error("error connecting to grpc server", log.ErrorField(err))
-		return
+		return err
 	}
 	defer conn.Close()
-	f, err := os.Open(fn)
-	if err != nil {
-		log.Error("error opening file: %v", log.ErrorField(err))
-		return
-	}
-	defer f.Close()
-	proc := newImportProc(conn, f)
-	if config.DefaultCliArgs().DoNotPersist {
-		proc.recordingMode = providerv1.RecordingMode_RECORDING_MODE_DO_NOT_PERSIST
-	}
-	proc.replaceData = replaceData
-	proc.eventKey = eventKey
-
-	proc.process()
-}
 
-func (p *importProc) process() {
-	i := 0
-	for {
-		msg, err := p.m.ReadNext()
-		if errors.Is(err, io.EOF) {
-			return // done
-		}
-		if err != nil {
-			log.Error("error reading message", log.ErrorField(err))
-			break
-		}
-		log.Debug("message",
-			log.Int("i", i),
-			log.String("name", string(msg.Descriptor().Name())))
-		if err := p.sendMesage(msg); err != nil {
-			log.Error("error sending message", log.ErrorField(err))
-			return
+	ctx, stop := signal.NotifyContext(cmdCtx, os.Interrupt)
+	defer stop()
+	checkpoint := fn + checkpointExtension
+	if restart {
+		if err := os.Remove(checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
 		}
-		i++
 	}
-}
-
-//nolint:cyclop // by design
-func (p *importProc) sendMesage(msg protoreflect.Message) error {
-	switch msg.Interface().(type) {
-	case *providerv1.RegisterEventRequest:
-		req, _ := msg.Interface().(*providerv1.RegisterEventRequest)
-		if p.eventKey != "" {
-			req.Event.Key = p.eventKey
-		}
-		if p.replaceData {
-			if err := p.dpc.DeleteEvent(req.Event.Key); err != nil {
-				if status.Code(err) != codes.NotFound {
-					return err
-				}
-			}
-		}
-		_, err := p.dpc.RegisterProvider(req.Event, req.Track, p.recordingMode)
+	dpc := owngrpc.NewDataProviderClient(
+		owngrpc.WithConnection(conn),
+		owngrpc.WithToken(config.DefaultCliArgs().Token),
+	)
+	u := spool.NewUploader(fn,
+		msgimport.NewImporter(dpc, importerOptions(ctx, speed)...),
+		spool.WithOffsetFile(checkpoint))
+	if err := u.Run(ctx); err != nil {
+		log.Error("import stopped. Run the command again to continue",
+			log.ErrorField(err))
 		return err
-	case *providerv1.UnregisterEventRequest:
-		req, _ := msg.Interface().(*providerv1.UnregisterEventRequest)
-		eventKey := req.EventSelector.GetKey()
-		if p.eventKey != "" {
-			eventKey = p.eventKey
-		}
-		return p.dpc.UnregisterProvider(eventKey)
-	case *racestatev1.PublishStateRequest:
-		req, _ := msg.Interface().(*racestatev1.PublishStateRequest)
-		p.updateEventSelector(req.Event)
-		return p.dpc.PublishState(req)
-	case *racestatev1.PublishDriverDataRequest:
-		req, _ := msg.Interface().(*racestatev1.PublishDriverDataRequest)
-		p.updateEventSelector(req.Event)
-		return p.dpc.PublishDriverData(req)
-	case *racestatev1.PublishSpeedmapRequest:
-		req, _ := msg.Interface().(*racestatev1.PublishSpeedmapRequest)
-		p.updateEventSelector(req.Event)
-		return p.dpc.PublishSpeedmap(req)
 	}
-	return nil
-}
-
-func (p *importProc) updateEventSelector(sel *commonv1.EventSelector) {
-	if p.eventKey != "" {
-		sel.Arg = &commonv1.EventSelector_Key{Key: p.eventKey}
+	// the import is complete, the next import starts from the beginning
+	if err := os.Remove(checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warn("could not remove checkpoint", log.ErrorField(err))
 	}
+	return nil
 }
@@ -0,0 +1,175 @@
+package loginspect
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/go-racelogger/internal/inspect"
+	"github.com/mpapenbr/go-racelogger/log"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+var (
+	gapThreshold = 5 * time.Second
+	skip         = 0
+	limit        = 0
+	filter       = inspect.Filter{}
+)
+
+var ErrValidationFailed = errors.New("validation failed")
+
+func NewInspectCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "inspect a file of logged grpc messages",
+		Long: `Shows the content of a msg log file (--msg-log-file, msglog publisher,
+spool file). Compressed files and manifests of rotated msg logs are supported.`,
+	}
+	cmd.AddCommand(newSummaryCmd(), newDumpCmd(), newFilterCmd(), newValidateCmd())
+	return cmd
+}
+
+func newSummaryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "summary <file>",
+		Short: "show message counts, event, track, time span and gaps",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withFile(args[0], func(r io.Reader) error {
+				inspect.Summarize(r, gapThreshold).Print(cmd.OutOrStdout())
+				return nil
+			})
+		},
+	}
+	cmd.Flags().DurationVar(&gapThreshold,
+		"gap-threshold",
+		5*time.Second,
+		"report intervals between state messages longer than this as gaps")
+	return cmd
+}
+
+func newDumpCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dump <file>",
+		Short: "dump messages as JSON lines",
+		Long: `Dumps the messages as JSON lines. Use --skip and --limit to select
+the messages by their position in the file.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withFile(args[0], func(r io.Reader) error {
+				return dump(r, cmd.OutOrStdout(), func(rec *inspect.Record) proto.Message {
+					if rec.Index < skip {
+						return nil
+					}
+					return rec.Msg
+				})
+			})
+		},
+	}
+	cmd.Flags().IntVar(&skip, "skip", 0, "skip the first n messages")
+	cmd.Flags().IntVar(&limit, "limit", 0, "dump at most n messages (0: no limit)")
+	return cmd
+}
+
+func newFilterCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "filter <file>",
+		Short: "dump messages selected by type, car number or session time",
+		Long: `Dumps the selected messages as JSON lines.
+Types: register, unregister, state, driverdata, speedmap, extrainfo
+With --car only car related messages are shown and reduced to the data of this car.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := filter.ValidateTypes(); err != nil {
+				return err
+			}
+			return withFile(args[0], func(r io.Reader) error {
+				return dump(r, cmd.OutOrStdout(), func(rec *inspect.Record) proto.Message {
+					return filter.Apply(rec)
+				})
+			})
+		},
+	}
+	cmd.Flags().StringSliceVar(&filter.Types, "type", []string{},
+		"show only messages of this type (may be used multiple times)")
+	cmd.Flags().StringVar(&filter.CarNum, "car", "", "show only data of this car number")
+	cmd.Flags().DurationVar(&filter.From, "from", 0,
+		"show only messages from this session time on (e.g. 1h30m)")
+	cmd.Flags().DurationVar(&filter.To, "to", 0,
+		"show only messages up to this session time (default: no limit)")
+	cmd.Flags().IntVar(&limit, "limit", 0, "dump at most n messages (0: no limit)")
+	return cmd
+}
+
+func newValidateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate <file>",
+		Short: "check the file for truncated or corrupted records",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withFile(args[0], func(r io.Reader) error {
+				v := inspect.Validate(r)
+				v.Print(cmd.OutOrStdout())
+				if v.Verdict != inspect.VerdictOK {
+					cmd.SilenceUsage = true
+					return fmt.Errorf("%w: %s", ErrValidationFailed, v.Verdict)
+				}
+				return nil
+			})
+		},
+	}
+	return cmd
+}
+
+func withFile(fn string, f func(r io.Reader) error) error {
+	file, err := logger.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return f(bufio.NewReader(file))
+}
+
+// dump writes the messages returned by sel as JSON lines.
+// Records for which sel returns nil are not written.
+//
+//nolint:whitespace // can't get different linters happy
+func dump(
+	r io.Reader,
+	w io.Writer,
+	sel func(rec *inspect.Record) proto.Message,
+) error {
+	reader := inspect.NewReader(r)
+	out := bufio.NewWriter(w)
+	count := 0
+	for limit <= 0 || count < limit {
+		rec, err := reader.Next()
+		if errors.Is(err, logger.ErrInvalidMessage) {
+			log.Warn("skipping invalid message", log.ErrorField(err))
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			//nolint:errcheck // the read error is reported
+			out.Flush()
+			return err
+		}
+		msg := sel(rec)
+		if msg == nil {
+			continue
+		}
+		if err := inspect.WriteJSON(out, rec, msg); err != nil {
+			return err
+		}
+		count++
+	}
+	return out.Flush()
+}
@@ -0,0 +1,188 @@
+package logtool
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mpapenbr/go-racelogger/internal/msgtool"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+var (
+	keyframeInterval = 0
+	outputDir        = ""
+	fromKey          = ""
+	toKey            = ""
+)
+
+var (
+	ErrOutputIsInput = errors.New("output file is also an input file")
+	ErrMissingKey    = errors.New("missing new event key (--to)")
+)
+
+func NewLogtoolCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logtool",
+		Short: "split, merge and re-key msg log files",
+		Long: `Rewrites msg log files by the event key (EventSelector) of the messages.
+Messages without an event key belong to the event registered last.
+The output is always written in the current msg log format.`,
+	}
+	cmd.PersistentFlags().IntVar(&keyframeInterval,
+		"state-keyframe-interval",
+		0,
+		"write state messages as deltas with a full state every n messages "+
+			"(0: always full state)")
+	cmd.AddCommand(newSplitCmd(), newMergeCmd(), newRekeyCmd())
+	return cmd
+}
+
+func newSplitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "split <file>",
+		Short: "write the messages of each event to a separate file",
+		Long: `Writes the messages of each event key to <file>-<key>.<ext>
+(in the directory of the file unless --output-dir is given).`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return doSplit(cmd.OutOrStdout(), args[0])
+		},
+	}
+	cmd.Flags().StringVar(&outputDir, "output-dir", "",
+		"write the files to this directory")
+	return cmd
+}
+
+func newMergeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "merge <output> <file>...",
+		Short: "merge files into a single file",
+		Long: `Writes the messages of all files to <output> ordered by their capture time.
+Files in v1 format have no capture times. Their messages are kept in the
+order of the files.`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return doMerge(cmd.OutOrStdout(), args[0], args[1:])
+		},
+	}
+	return cmd
+}
+
+func newRekeyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rekey <file> <output>",
+		Short: "change the event key of messages",
+		Long: `Copies the messages to <output> and replaces the event key --from
+by --to. Without --from the messages of all events get the new key.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if toKey == "" {
+				return ErrMissingKey
+			}
+			cmd.SilenceUsage = true
+			return doRekey(cmd.OutOrStdout(), args[0], args[1])
+		},
+	}
+	cmd.Flags().StringVar(&fromKey, "from", "", "event key to replace")
+	cmd.Flags().StringVar(&toKey, "to", "", "new event key")
+	return cmd
+}
+
+func outputOpts() []logger.Option {
+	return []logger.Option{logger.WithStateDelta(keyframeInterval)}
+}
+
+func doSplit(out io.Writer, fn string) error {
+	in, err := logger.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	dir := outputDir
+	if dir == "" {
+		dir = filepath.Dir(fn)
+	}
+	name := strings.TrimSuffix(fn, logger.ManifestExtension)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(filepath.Base(name), ext)
+	files := map[string]string{}
+	counts, err := msgtool.Split(in, func(key string) (io.WriteCloser, error) {
+		name := key
+		if name == "" {
+			name = "nokey"
+		}
+		files[key] = filepath.Join(dir, fmt.Sprintf("%s-%s%s", base, name, ext))
+		return os.Create(files[key])
+	}, outputOpts()...)
+	for _, key := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Fprintf(out, "%-40s %8d  %s\n", key, counts[key], files[key])
+	}
+	return err
+}
+
+func doMerge(out io.Writer, outFn string, inFns []string) error {
+	inputs := make([]io.Reader, 0, len(inFns))
+	for _, fn := range inFns {
+		if sameFile(fn, outFn) {
+			return ErrOutputIsInput
+		}
+		f, err := logger.Open(fn)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		inputs = append(inputs, f)
+	}
+	w, err := os.Create(outFn)
+	if err != nil {
+		return err
+	}
+	counts, err := msgtool.Merge(w, inputs, outputOpts()...)
+	err = errors.Join(err, w.Close())
+	for _, key := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Fprintf(out, "%-40s %8d\n", key, counts[key])
+	}
+	return err
+}
+
+func doRekey(out io.Writer, fn, outFn string) error {
+	if sameFile(fn, outFn) {
+		return ErrOutputIsInput
+	}
+	in, err := logger.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	w, err := os.Create(outFn)
+	if err != nil {
+		return err
+	}
+	changed, err := msgtool.Rekey(in, w, fromKey, toKey, outputOpts()...)
+	err = errors.Join(err, w.Close())
+	fmt.Fprintf(out, "Changed messages: %d\n", changed)
+	return err
+}
+
+func sameFile(a, b string) bool {
+	sa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	sb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(sa, sb)
+}
@@ -2,6 +2,7 @@ package record
 
 import (
 	"context"
+	"errors"
 	"os"
 	"os/signal"
 
@@ -65,6 +66,48 @@ func NewRecordCmd() *cobra.Command {
 		"max-speed",
 		500,
 		"do not process computed speed above this value in km/h")
+	cmd.Flags().BoolVar(&config.DefaultCliArgs().Overtakes,
+		"overtakes",
+		false,
+		"report overtakes as race control messages")
+	cmd.Flags().IntVar(&config.DefaultCliArgs().OvertakeTopN,
+		"overtake-top-n",
+		0,
+		"report only overtakes for this position or better (0: all)")
+	cmd.Flags().BoolVar(&config.DefaultCliArgs().OvertakeSameClass,
+		"overtake-same-class",
+		false,
+		"report only overtakes between cars of the same class "+
+			"(--overtake-top-n refers to the class position)")
+	cmd.Flags().Float64Var(&config.DefaultCliArgs().BlueFlagWindow,
+		"blue-flag-window",
+		0,
+		"show the blue flag if a lapping car is within this time (seconds) "+
+			"behind, for example 2 (0: no lapping detection)")
+	cmd.Flags().Float64Var(&config.DefaultCliArgs().BattleThreshold,
+		"battle-threshold",
+		0,
+		"cars within this interval (seconds) are in a battle, for example 1 "+
+			"(0: no battle detection)")
+	cmd.Flags().StringVar(&config.DefaultCliArgs().LapChartFile,
+		"lap-chart-file",
+		"",
+		"write the lap chart of each race session to this file (CSV if the name "+
+			"ends with .csv, otherwise JSON). Placeholders: {key}, {session}, {date}")
+	cmd.Flags().StringVar(&config.DefaultCliArgs().PitStopsFile,
+		"pit-stops-file",
+		"",
+		"write the pit stops of each race session to this file (CSV if the name "+
+			"ends with .csv, otherwise JSON). Placeholders: {key}, {session}, {date}")
+	cmd.Flags().BoolVar(&config.DefaultCliArgs().IncidentMessages,
+		"incident-messages",
+		false,
+		"report incidents (off track, spin, stopped) as race control messages")
+	cmd.Flags().StringVar(&config.DefaultCliArgs().IncidentsFile,
+		"incidents-file",
+		"",
+		"write incidents of each race session to this file (JSON). "+
+			"Placeholders: {key}, {session}, {date}")
 	cmd.Flags().BoolVar(&config.DefaultCliArgs().DoNotPersist,
 		"do-not-persist",
 		false,
@@ -72,7 +115,62 @@ func NewRecordCmd() *cobra.Command {
 	cmd.Flags().StringVar(&config.DefaultCliArgs().MsgLogFile,
 		"msg-log-file",
 		"",
-		"write grpc messages to this file")
+		"write grpc messages to this file. A file is created per event. "+
+			"Placeholders: {key}, {session}, {date}")
+	cmd.Flags().StringVar(&config.DefaultCliArgs().MsgLogCompression,
+		"msg-log-compression",
+		"none",
+		"compress msg log files (none, gzip)")
+	cmd.Flags().Int64Var(&config.DefaultCliArgs().MsgLogRotateSize,
+		"msg-log-rotate-size",
+		0,
+		"split msg log files into segments of this size in MB (0: no limit)")
+	cmd.Flags().StringVar(&config.DefaultCliArgs().MsgLogRotateInterval,
+		"msg-log-rotate-interval",
+		"0s",
+		"split msg log files into segments covering this duration (0s: no limit)")
+	cmd.Flags().IntVar(&config.DefaultCliArgs().MsgLogMaxSegments,
+		"msg-log-max-segments",
+		0,
+		"keep at most this number of msg log segments (0: keep all). "+
+			"The first segment and segments not yet uploaded are always kept")
+	cmd.Flags().StringSliceVar(&config.DefaultCliArgs().Publish,
+		"publish",
+		[]string{},
+		"additionally publish the data to <type>:<file> (types: msglog, json)")
+	cmd.Flags().StringVar(&config.DefaultCliArgs().SpoolDir,
+		"spool-dir",
+		"",
+		"record to a spool file in this directory and upload the data from there")
+	cmd.Flags().BoolVar(&config.DefaultCliArgs().Offline,
+		"offline",
+		false,
+		"start recording even if the backend is not reachable (implies spool-dir)")
+	cmd.Flags().IntVar(&config.DefaultCliArgs().StateKeyframeInterval,
+		"state-keyframe-interval",
+		0,
+		"write state messages to msg logs as deltas with a full state every n messages "+
+			"(0: always full state)")
+	cmd.Flags().BoolVar(&config.DefaultCliArgs().StreamPublish,
+		"stream",
+		false,
+		"publish state, speedmap and driver data via a single stream (needs backend support)")
+	cmd.Flags().StringVar(&config.DefaultCliArgs().RetryQueueDir,
+		"retry-queue-dir",
+		"",
+		"queue messages which could not be sent in this directory for later redelivery")
+	cmd.Flags().Int64Var(&config.DefaultCliArgs().RetryQueueMaxSize,
+		"retry-queue-max-size",
+		64,
+		"max size of the retry queue in MB (oldest messages are dropped)")
+	cmd.Flags().StringVar(&config.DefaultCliArgs().RetryQueueMaxAge,
+		"retry-queue-max-age",
+		"2h",
+		"drop queued messages older than this duration")
+	cmd.Flags().StringVar(&config.DefaultCliArgs().CaptureFile,
+		"capture-file",
+		"",
+		"write raw telemetry data to this file (see capture command)")
 	cmd.Flags().BoolVar(&config.DefaultCliArgs().EnsureLiveData,
 		"ensure-live-data",
 		true,
@@ -105,14 +203,27 @@ func recordEvent(cmdCtx context.Context, cfg *config.CliArgs) error {
 	}
 	defer conn.Close()
 
-	if ok := validateBackendVersion(conn); !ok {
+	if err := validateBackendVersion(conn); err != nil {
+		if !cfg.Offline || errors.Is(err, ErrIncompatibleBackend) {
+			return nil
+		}
+		log.Warn("Backend not reachable. Recording offline")
+	}
+
+	retryQueue, err := util.OpenRetryQueue(cmdCtx, conn, cfg)
+	if err != nil {
+		log.Error("Could not open retry queue", log.ErrorField(err))
 		return nil
 	}
+	if retryQueue != nil {
+		defer retryQueue.Close()
+	}
 
 	myCtx, cancel := context.WithCancel(cmdCtx)
 	rec := recorder.NewRecorder(
 		recorder.WithContext(myCtx, cancel),
 		recorder.WithConnection(conn),
+		recorder.WithRetryQueue(retryQueue),
 		recorder.WithCliArgs(cfg),
 		recorder.WithEventNames(cfg.EventName),
 		recorder.WithEventDescriptions(cfg.EventDescription),
@@ -153,11 +264,35 @@ func recordEvent(cmdCtx context.Context, cfg *config.CliArgs) error {
 	// r.UnregisterProvider()
 	// log.Debug("Got signal ", log.Any("signal", v))
 	// wampHandler.shutdown()
+	waitForUpload(rec, sigChan)
 	log.Info("Recorder terminated")
 	return nil
 }
 
-func validateBackendVersion(conn *grpc.ClientConn) bool {
+// waitForUpload waits until the spooled data is uploaded. Another interrupt aborts
+// the upload, it may be continued later with the upload command.
+func waitForUpload(rec *recorder.Recorder, sigChan chan os.Signal) {
+	if rec.SpoolFile() == "" {
+		return
+	}
+	uploadCtx, uploadCancel := context.WithCancel(context.Background())
+	defer uploadCancel()
+	go func() {
+		select {
+		case <-sigChan:
+			uploadCancel()
+		case <-uploadCtx.Done():
+		}
+	}()
+	log.Info("Waiting for upload of spooled data (press Ctrl-C to abort)")
+	if err := rec.WaitForUpload(uploadCtx); err != nil {
+		log.Warn("Upload not completed", log.ErrorField(err))
+	}
+}
+
+var ErrIncompatibleBackend = errors.New("racelogger and backend are not compatible")
+
+func validateBackendVersion(conn *grpc.ClientConn) error {
 	c := providerv1grpc.NewProviderServiceClient(conn)
 	var res *providerv1.VersionCheckResponse
 	var err error
@@ -165,7 +300,7 @@ func validateBackendVersion(conn *grpc.ClientConn) bool {
 		RaceloggerVersion: version.Version,
 	}); err != nil {
 		log.Error("error checking compatibility", log.ErrorField(err))
-		return false
+		return err
 	}
 	if !res.RaceloggerCompatible {
 		log.Error("Client and server are not compatible",
@@ -173,6 +308,7 @@ func validateBackendVersion(conn *grpc.ClientConn) bool {
 			log.String("server-version", res.ServerVersion),
 			log.String("minimum-racelogger-version", res.SupportedRaceloggerVersion),
 			log.Bool("compatible", res.RaceloggerCompatible))
+		return ErrIncompatibleBackend
 	}
-	return res.RaceloggerCompatible
+	return nil
 }
@@ -0,0 +1,164 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	"github.com/spf13/cobra"
+
+	"github.com/mpapenbr/go-racelogger/internal/replay"
+	"github.com/mpapenbr/go-racelogger/internal/telemetry"
+	"github.com/mpapenbr/go-racelogger/log"
+	"github.com/mpapenbr/go-racelogger/pkg/config"
+	owngrpc "github.com/mpapenbr/go-racelogger/pkg/grpc"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+	"github.com/mpapenbr/go-racelogger/pkg/publisher"
+	"github.com/mpapenbr/go-racelogger/pkg/util"
+)
+
+const (
+	outputJSON   = "json"
+	outputMsgLog = "msglog"
+	outputGrpc   = "grpc"
+)
+
+var ErrMissingMsgLogFile = errors.New("output msglog requires --msg-log-file")
+
+var (
+	speed            = 1.0
+	output           = outputJSON
+	eventKey         = ""
+	eventName        = ""
+	eventDescription = ""
+)
+
+func NewReplayCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "replay <capture-file>",
+		Short: "run the racelogger processing on a capture file",
+		Long: `Runs the racelogger processing on data recorded by the capture command.
+The results can be sent to the backend server (grpc),
+written to a msg log file (msglog) or to stdout as JSON lines (json).`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return doReplay(cmd.Context(), config.DefaultCliArgs(), args[0])
+		},
+	}
+	cmd.Flags().Float64Var(&speed,
+		"speed",
+		1.0,
+		"replay speed (1: real time, 2: double speed, ..., 0: as fast as possible)")
+	cmd.Flags().StringVar(&output,
+		"output",
+		outputJSON,
+		"where to send the results (json, msglog, grpc)")
+	cmd.Flags().StringVar(&config.DefaultCliArgs().MsgLogFile,
+		"msg-log-file",
+		"",
+		"write grpc messages to this file")
+	cmd.Flags().StringSliceVar(&config.DefaultCliArgs().Publish,
+		"publish",
+		[]string{},
+		"additionally publish the data to <type>:<file> (types: msglog, json)")
+	cmd.Flags().StringVar(&eventKey,
+		"event-key",
+		"",
+		"use this event key (default: generated)")
+	cmd.Flags().StringVarP(&eventName,
+		"name",
+		"n",
+		"",
+		"Event name")
+	cmd.Flags().StringVarP(&eventDescription,
+		"description",
+		"d",
+		"",
+		"Event description")
+	cmd.Flags().StringVarP(&config.DefaultCliArgs().Token,
+		"token",
+		"t",
+		"",
+		"Dataprovider token")
+	cmd.Flags().BoolVar(&config.DefaultCliArgs().DoNotPersist,
+		"do-not-persist",
+		false,
+		"do not persist the recorded data (used for debugging)")
+	return cmd
+}
+
+//nolint:funlen,cyclop // by design
+func doReplay(cmdCtx context.Context, cfg *config.CliArgs, fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader, err := telemetry.NewCaptureReader(f)
+	if err != nil {
+		return err
+	}
+
+	var msgLog *os.File
+	if cfg.MsgLogFile != "" {
+		if msgLog, err = os.Create(cfg.MsgLogFile); err != nil {
+			return err
+		}
+		defer msgLog.Close()
+	}
+
+	recordingMode := providerv1.RecordingMode_RECORDING_MODE_PERSIST
+	if cfg.DoNotPersist {
+		recordingMode = providerv1.RecordingMode_RECORDING_MODE_DO_NOT_PERSIST
+	}
+	var out publisher.Publisher
+	switch output {
+	case outputJSON:
+		out = publisher.NewJSON(os.Stdout)
+	case outputMsgLog:
+		if msgLog == nil {
+			return ErrMissingMsgLogFile
+		}
+		out = publisher.NewMsgLog(msgLog)
+	case outputGrpc:
+		conn, err := util.ConnectGrpc(cfg)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		opts := []owngrpc.Option{
+			owngrpc.WithConnection(conn),
+			owngrpc.WithToken(cfg.Token),
+		}
+		if msgLog != nil {
+			opts = append(opts, owngrpc.WithMsgLogFile(msgLog))
+		}
+		out = owngrpc.NewDataProviderClient(opts...)
+	default:
+		return fmt.Errorf("unknown output %q", output)
+	}
+	sinks, closers, err := publisher.OpenSinks(cfg.Publish, logger.FileConfig{})
+	if err != nil {
+		return err
+	}
+	//nolint:errcheck // by design
+	defer publisher.CloseAll(closers)
+
+	ctx, stop := signal.NotifyContext(cmdCtx, os.Interrupt)
+	defer stop()
+	r := replay.NewReplay(reader, publisher.Combine(out, sinks...),
+		replay.WithRecordingMode(recordingMode),
+		replay.WithSpeed(speed),
+		replay.WithEventKey(eventKey),
+		replay.WithEventName(eventName),
+		replay.WithEventDescription(eventDescription),
+	)
+	if err := r.Run(ctx); err != nil {
+		log.Error("replay failed", log.ErrorField(err))
+		return err
+	}
+	return nil
+}
@@ -34,6 +34,23 @@ func NewServerCmd() *cobra.Command {
 		"backend-check-interval",
 		time.Second*2,
 		"Interval to check backend compatibility")
+	cmd.Flags().Float64Var(&config.DefaultCliArgs().BattleThreshold,
+		"battle-threshold",
+		0,
+		"cars within this interval (seconds) are in a battle, for example 1 "+
+			"(0: no battle detection)")
+	cmd.Flags().StringVar(&config.DefaultCliArgs().RetryQueueDir,
+		"retry-queue-dir",
+		"",
+		"queue messages which could not be sent in this directory for later redelivery")
+	cmd.Flags().Int64Var(&config.DefaultCliArgs().RetryQueueMaxSize,
+		"retry-queue-max-size",
+		64,
+		"max size of the retry queue in MB (oldest messages are dropped)")
+	cmd.Flags().StringVar(&config.DefaultCliArgs().RetryQueueMaxAge,
+		"retry-queue-max-age",
+		"2h",
+		"drop queued messages older than this duration")
 	return cmd
 }
 
@@ -49,6 +66,14 @@ func runServer(cmdCtx context.Context) {
 
 	myCtx, cancel := context.WithCancel(cmdCtx)
 	defer cancel()
+	retryQueue, err := util.OpenRetryQueue(myCtx, conn, config.DefaultCliArgs())
+	if err != nil {
+		log.Error("Could not open retry queue", log.ErrorField(err))
+		return
+	}
+	if retryQueue != nil {
+		defer retryQueue.Close()
+	}
 	var srv server.Server
 
 	srv, err = server.NewServer(
@@ -56,6 +81,7 @@ func runServer(cmdCtx context.Context) {
 		server.WithGrpcConn(conn),
 		server.WithAddr(config.DefaultCliArgs().ServerServiceAddr),
 		server.WithBackendCheckInterval(config.DefaultCliArgs().BackendCheckInterval),
+		server.WithRetryQueue(retryQueue),
 		server.WithLogger(log.GetFromContext(myCtx).Named("server")))
 	if err != nil {
 		log.Error("Could not create server", log.ErrorField(err))
@@ -0,0 +1,67 @@
+package upload
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	"github.com/spf13/cobra"
+
+	"github.com/mpapenbr/go-racelogger/internal/msgimport"
+	"github.com/mpapenbr/go-racelogger/internal/spool"
+	"github.com/mpapenbr/go-racelogger/log"
+	"github.com/mpapenbr/go-racelogger/pkg/config"
+	owngrpc "github.com/mpapenbr/go-racelogger/pkg/grpc"
+	"github.com/mpapenbr/go-racelogger/pkg/util"
+)
+
+func NewUploadCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "upload <spool-file>",
+		Short: "upload the data of an offline recording to the backend server",
+		Long: `Uploads the data of a spool file created by the record command.
+The upload continues where a previous upload stopped.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return doUpload(cmd.Context(), args[0])
+		},
+	}
+	cmd.Flags().StringVarP(&config.DefaultCliArgs().Token,
+		"token",
+		"t",
+		"",
+		"Dataprovider token")
+	cmd.Flags().BoolVar(&config.DefaultCliArgs().DoNotPersist,
+		"do-not-persist",
+		false,
+		"do not persist the uploaded data (used for debugging)")
+	return cmd
+}
+
+func doUpload(cmdCtx context.Context, fn string) error {
+	if _, err := os.Stat(fn); err != nil {
+		return err
+	}
+	conn, err := util.ConnectGrpc(config.DefaultCliArgs())
+	if err != nil {
+		log.Error("error connecting to grpc server", log.ErrorField(err))
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := signal.NotifyContext(cmdCtx, os.Interrupt)
+	defer cancel()
+
+	recordingMode := providerv1.RecordingMode_RECORDING_MODE_PERSIST
+	if config.DefaultCliArgs().DoNotPersist {
+		recordingMode = providerv1.RecordingMode_RECORDING_MODE_DO_NOT_PERSIST
+	}
+	dpc := owngrpc.NewDataProviderClient(
+		owngrpc.WithConnection(conn),
+		owngrpc.WithToken(config.DefaultCliArgs().Token),
+	)
+	u := spool.NewUploader(fn,
+		msgimport.NewImporter(dpc, msgimport.WithRecordingMode(recordingMode)))
+	return u.Run(ctx)
+}
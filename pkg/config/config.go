@@ -20,8 +20,30 @@ type CliArgs struct {
 	SpeedmapPublishInterval string        // duration to publish speedmap data
 	SpeedmapSpeedThreshold  float64       // do not record speed below this threshold pct (0-1.0)
 	MaxSpeed                float64       // do not process  speeds above this value (km/h)
+	Overtakes               bool          // report overtakes as race control messages
+	OvertakeTopN            int           // report only overtakes for this position or better (0: all)
+	OvertakeSameClass       bool          // report only overtakes between cars of the same class
+	BlueFlagWindow          float64       // seconds a lapping car may be behind to show the blue flag (0: off)
+	BattleThreshold         float64       // max interval (seconds) between cars in a battle (0: off)
+	IncidentMessages        bool          // report incidents as race control messages
+	IncidentsFile           string        // write incidents of each race session to this file (JSON)
+	LapChartFile            string        // write the lap chart of each race session to this file (JSON or CSV)
+	PitStopsFile            string        // write the pit stops of each race session to this file (JSON or CSV)
 	DoNotPersist            bool          // do not persist the recorded data (used for debugging)
 	MsgLogFile              string        // write grpc messages to this file
+	MsgLogCompression       string        // compression of the msg log files (none, gzip)
+	MsgLogRotateSize        int64         // start a new msg log segment at this size (MB)
+	MsgLogRotateInterval    string        // start a new msg log segment after this duration
+	MsgLogMaxSegments       int           // keep at most this number of msg log segments
+	CaptureFile             string        // write raw telemetry (processor input) to this file
+	Publish                 []string      // additional publishers (<type>:<file>)
+	SpoolDir                string        // record to a spool file in this directory and upload from there
+	Offline                 bool          // start recording even if the backend is not reachable (implies spool)
+	RetryQueueDir           string        // queue messages which could not be sent in this directory
+	RetryQueueMaxSize       int64         // max size of the retry queue (MB)
+	RetryQueueMaxAge        string        // drop queued messages older than this (duration)
+	StreamPublish           bool          // publish state, speedmap and driver data via stream
+	StateKeyframeInterval   int           // write state messages to msg logs as deltas with a keyframe every n messages
 	EnsureLiveData          bool          // if true, replay will be set to live data on connection
 	EnsureLiveDataInterval  string        // interval to set replay mode to live mode
 	WatchdogInterval        string        // interval for watchdog checks (duration)
@@ -31,6 +53,9 @@ type CliArgs struct {
 	BackendCheckInterval    time.Duration // interval to check backend compatibility
 }
 
+// DefaultSpoolDir is used for offline recording if no spool dir is configured
+const DefaultSpoolDir = "spool"
+
 var cliArgs = NewCliArgs()
 
 func DefaultCliArgs() *CliArgs {
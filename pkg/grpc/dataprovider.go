@@ -14,8 +14,8 @@ import (
 	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
 
-	"github.com/mpapenbr/go-racelogger/log"
 	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
 )
 
@@ -27,6 +27,7 @@ type DataProviderClient struct {
 	token          string
 
 	msgLogger *logger.MsgLogger
+	queue     *RetryQueue
 }
 
 type Option func(*DataProviderClient)
@@ -56,9 +57,19 @@ func WithToken(token string) Option {
 	}
 }
 
-func WithMsgLogFile(writer io.Writer) Option {
+// WithMsgLogFile logs all sent messages to writer.
+// Additional logger options (e.g. logger.WithStateDelta) may be passed.
+func WithMsgLogFile(writer io.Writer, opts ...logger.Option) Option {
 	return func(dpc *DataProviderClient) {
-		dpc.msgLogger = logger.NewMsgLogger(logger.WithWriter(writer))
+		opts = append(opts, logger.WithWriter(writer))
+		dpc.msgLogger = logger.NewMsgLogger(opts...)
+	}
+}
+
+// WithRetryQueue queues messages which could not be sent for later redelivery
+func WithRetryQueue(q *RetryQueue) Option {
+	return func(dpc *DataProviderClient) {
+		dpc.queue = q
 	}
 }
 
@@ -91,9 +102,11 @@ func (dpc *DataProviderClient) UnregisterProvider(eventKey string) error {
 	}
 	//nolint:errcheck // by design
 	dpc.msgLogger.Log(req.ProtoReflect())
-	_, err := dpc.providerClient.UnregisterEvent(
-		dpc.prepareContext(context.Background()), &req)
-	return err
+	return dpc.publish(&req, func() error {
+		_, err := dpc.providerClient.UnregisterEvent(
+			dpc.prepareContext(context.Background()), &req)
+		return err
+	})
 }
 
 func (dpc *DataProviderClient) DeleteEvent(eventKey string) error {
@@ -107,70 +120,16 @@ func (dpc *DataProviderClient) DeleteEvent(eventKey string) error {
 	return err
 }
 
-//nolint:whitespace,nestif,gocognit // by design
-func (dpc *DataProviderClient) PublishStateFromChannel(
-	eventKey string,
-	rcv chan *racestatev1.PublishStateRequest,
-) {
-	go func() {
-		errorCounter := 0
-		for {
-			s, more := <-rcv
-			if s != nil {
-				err := dpc.PublishState(s)
-				if err != nil {
-					if errorCounter%30 == 0 {
-						log.Error("Error publishing state data",
-							log.Int("errorCounter", errorCounter+1),
-							log.ErrorField(err))
-					}
-					errorCounter++
-				} else {
-					if errorCounter > 0 {
-						log.Info("Published state data successful again",
-							log.Int("errorCounter", errorCounter))
-					}
-					errorCounter = 0
-				}
-			}
-			if !more {
-				log.Debug("closed channel signaled")
-				return
-			}
-		}
-	}()
-}
-
 func (dpc *DataProviderClient) PublishState(
 	req *racestatev1.PublishStateRequest,
 ) error {
 	//nolint:errcheck // by design
 	dpc.msgLogger.Log(req.ProtoReflect())
-	_, err := dpc.stateClient.PublishState(
-		dpc.prepareContext(context.Background()), req)
-	return err
-}
-
-//nolint:whitespace // by design
-func (dpc *DataProviderClient) PublishCarDataFromChannel(
-	eventKey string,
-	rcv chan *racestatev1.PublishDriverDataRequest,
-) {
-	go func() {
-		for {
-			s, more := <-rcv
-			if s != nil {
-				err := dpc.PublishDriverData(s)
-				if err != nil {
-					log.Error("Error publishing driver data", log.ErrorField(err))
-				}
-			}
-			if !more {
-				log.Debug("closed channel signaled")
-				return
-			}
-		}
-	}()
+	return dpc.publish(req, func() error {
+		_, err := dpc.stateClient.PublishState(
+			dpc.prepareContext(context.Background()), req)
+		return err
+	})
 }
 
 func (dpc *DataProviderClient) PublishDriverData(
@@ -178,31 +137,11 @@ func (dpc *DataProviderClient) PublishDriverData(
 ) error {
 	//nolint:errcheck // by design
 	dpc.msgLogger.Log(req.ProtoReflect())
-	_, err := dpc.stateClient.PublishDriverData(
-		dpc.prepareContext(context.Background()), req)
-	return err
-}
-
-//nolint:whitespace // by design
-func (dpc *DataProviderClient) PublishSpeedmapDataFromChannel(
-	eventKey string,
-	rcv chan *racestatev1.PublishSpeedmapRequest,
-) {
-	go func() {
-		for {
-			s, more := <-rcv
-			if s != nil {
-				err := dpc.PublishSpeedmap(s)
-				if err != nil {
-					log.Error("Error publishing speedmap data", log.ErrorField(err))
-				}
-			}
-			if !more {
-				log.Debug("closed channel signaled")
-				return
-			}
-		}
-	}()
+	return dpc.publish(req, func() error {
+		_, err := dpc.stateClient.PublishDriverData(
+			dpc.prepareContext(context.Background()), req)
+		return err
+	})
 }
 
 func (dpc *DataProviderClient) PublishSpeedmap(
@@ -210,31 +149,11 @@ func (dpc *DataProviderClient) PublishSpeedmap(
 ) error {
 	//nolint:errcheck // by design
 	dpc.msgLogger.Log(req.ProtoReflect())
-	_, err := dpc.stateClient.PublishSpeedmap(
-		dpc.prepareContext(context.Background()), req)
-	return err
-}
-
-//nolint:whitespace // by design
-func (dpc *DataProviderClient) SendExtraInfoFromChannel(
-	eventKey string,
-	rcv chan *racestatev1.PublishEventExtraInfoRequest,
-) {
-	go func() {
-		for {
-			extra, more := <-rcv
-			if extra != nil {
-				err := dpc.PublishEventExtraInfo(extra)
-				if err != nil {
-					log.Error("Error publishing extra info", log.ErrorField(err))
-				}
-			}
-			if !more {
-				log.Debug("closed channel signaled")
-				return
-			}
-		}
-	}()
+	return dpc.publish(req, func() error {
+		_, err := dpc.stateClient.PublishSpeedmap(
+			dpc.prepareContext(context.Background()), req)
+		return err
+	})
 }
 
 func (dpc *DataProviderClient) PublishEventExtraInfo(
@@ -242,7 +161,38 @@ func (dpc *DataProviderClient) PublishEventExtraInfo(
 ) error {
 	//nolint:errcheck // by design
 	dpc.msgLogger.Log(req.ProtoReflect())
-	_, err := dpc.stateClient.PublishEventExtraInfo(
-		dpc.prepareContext(context.Background()), req)
-	return err
+	return dpc.publish(req, func() error {
+		_, err := dpc.stateClient.PublishEventExtraInfo(
+			dpc.prepareContext(context.Background()), req)
+		return err
+	})
+}
+
+// Send sends a message read from a msg log (or retry queue) to the backend
+func (dpc *DataProviderClient) Send(msg proto.Message) error {
+	switch req := msg.(type) {
+	case *providerv1.RegisterEventRequest:
+		_, err := dpc.RegisterProvider(req.Event, req.Track, req.RecordingMode)
+		return err
+	case *providerv1.UnregisterEventRequest:
+		return dpc.UnregisterProvider(req.EventSelector.GetKey())
+	case *racestatev1.PublishStateRequest:
+		return dpc.PublishState(req)
+	case *racestatev1.PublishDriverDataRequest:
+		return dpc.PublishDriverData(req)
+	case *racestatev1.PublishSpeedmapRequest:
+		return dpc.PublishSpeedmap(req)
+	case *racestatev1.PublishEventExtraInfoRequest:
+		return dpc.PublishEventExtraInfo(req)
+	default:
+		return nil
+	}
+}
+
+// publish calls send directly or via the retry queue (if configured)
+func (dpc *DataProviderClient) publish(msg proto.Message, send func() error) error {
+	if dpc.queue == nil {
+		return send()
+	}
+	return dpc.queue.Publish(msg, send)
 }
@@ -0,0 +1,41 @@
+package grpc_test
+
+import (
+	"testing"
+
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	owngrpc "github.com/mpapenbr/go-racelogger/pkg/grpc"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/standin"
+)
+
+func TestDataProviderClient_Standin(t *testing.T) {
+	srv := standin.New()
+	dpc := owngrpc.NewDataProviderClient(
+		owngrpc.WithConnection(startStandin(t, srv)))
+
+	// data for unregistered events is rejected
+	err := dpc.PublishState(state("ev"))
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	err = dpc.DeleteEvent("ev")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = dpc.RegisterProvider(&eventv1.Event{Key: "ev"}, &trackv1.Track{},
+		providerv1.RecordingMode_RECORDING_MODE_PERSIST)
+	require.NoError(t, err)
+	require.NoError(t, dpc.PublishState(state("ev")))
+	require.NoError(t, dpc.PublishEventExtraInfo(
+		&racestatev1.PublishEventExtraInfoRequest{Event: state("ev").Event}))
+	require.NoError(t, dpc.UnregisterProvider("ev"))
+
+	assert.Len(t, srv.Received(), 4)
+	assert.Equal(t, 1, srv.Rejected())
+	assert.Equal(t, []string{"ev"}, receivedKeys(srv))
+}
@@ -0,0 +1,159 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	Compression string
+	// FileConfig configures the files created by CreateFile
+	FileConfig struct {
+		Compression Compression
+		// start a new segment when the current one reaches this size (bytes)
+		MaxSize int64
+		// start a new segment when the current one covers this duration
+		MaxAge time.Duration
+		// delete the oldest segments (except the first one) if there are more
+		MaxSegments int
+		// flush the compressed data after this duration (default 1s)
+		FlushInterval time.Duration
+	}
+)
+
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+
+	defaultFlushInterval = time.Second
+)
+
+var (
+	ErrUnsupportedCompression = errors.New("unsupported compression")
+	gzipMagic                 = []byte{0x1f, 0x8b}
+)
+
+// ParseCompression parses the compression names used by the cli args
+func ParseCompression(s string) (Compression, error) {
+	switch strings.ToLower(s) {
+	case "", "none":
+		return CompressionNone, nil
+	case string(CompressionGzip):
+		return CompressionGzip, nil
+	default:
+		return CompressionNone, fmt.Errorf("%w: %q", ErrUnsupportedCompression, s)
+	}
+}
+
+// Rotating returns true if the msg log is split into segments
+func (c FileConfig) Rotating() bool {
+	return c.MaxSize > 0 || c.MaxAge > 0
+}
+
+// CreateFile creates a msg log file.
+// If rotation is configured the data is written to segments listed in the
+// manifest <name>.manifest.json (see RotatingFile).
+func CreateFile(name string, cfg FileConfig) (io.WriteCloser, error) {
+	if cfg.Rotating() {
+		return NewRotatingFile(name, cfg)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return compress(f, cfg), nil
+}
+
+// compress returns a writer compressing the data written to w.
+// Closing the writer also closes w.
+func compress(w io.WriteCloser, cfg FileConfig) io.WriteCloser {
+	if cfg.Compression == CompressionGzip {
+		interval := cfg.FlushInterval
+		if interval <= 0 {
+			interval = defaultFlushInterval
+		}
+		return &gzipWriter{gz: gzip.NewWriter(w), w: w, interval: interval}
+	}
+	return w
+}
+
+// gzipWriter flushes the compressed data by a timer started with the first
+// write after a flush. Flushing each record would spoil the compression.
+// A crash loses at most the records of the last interval.
+type gzipWriter struct {
+	mu       sync.Mutex
+	gz       *gzip.Writer
+	w        io.WriteCloser
+	interval time.Duration
+	timer    *time.Timer
+	err      error // error of the last flush
+}
+
+func (g *gzipWriter) Write(b []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err != nil {
+		return 0, g.err
+	}
+	n, err := g.gz.Write(b)
+	if err == nil && g.timer == nil {
+		g.timer = time.AfterFunc(g.interval, g.flush)
+	}
+	return n, err
+}
+
+func (g *gzipWriter) flush() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.timer = nil
+	g.err = g.gz.Flush() // nop if already closed
+}
+
+func (g *gzipWriter) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+	return errors.Join(g.gz.Close(), g.w.Close())
+}
+
+// decompress returns a reader providing the uncompressed data of r.
+// The compression is detected by the first bytes of r.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil || !bytes.Equal(magic, gzipMagic) {
+		// too short data is reported by the record reader
+		return br, nil //nolint:nilerr // see above
+	}
+	return gzip.NewReader(br)
+}
+
+// IsCompressed returns true if the data of r is compressed
+func IsCompressed(r io.ReaderAt) bool {
+	magic := make([]byte, len(gzipMagic))
+	if _, err := r.ReadAt(magic, 0); err != nil {
+		return false
+	}
+	return bytes.Equal(magic, gzipMagic)
+}
+
+// Open opens a msg log for reading.
+// For a manifest the segments are returned as a single stream.
+// Compressed data is detected by the reader (see MsgLogger.ReadNext).
+func Open(name string) (io.ReadCloser, error) {
+	if IsManifest(name) {
+		return openSegments(name)
+	}
+	return os.Open(name)
+}
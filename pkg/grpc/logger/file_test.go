@@ -0,0 +1,187 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mpapenbr/go-racelogger/pkg/statedelta"
+)
+
+var fileStart = time.Date(2024, 3, 17, 14, 0, 0, 0, time.UTC)
+
+// writeFile logs n state messages (one per minute) to a file created by CreateFile
+//
+//nolint:whitespace // can't get different linters happy
+func writeFile(
+	t *testing.T,
+	name string,
+	cfg FileConfig,
+	n int,
+	opts ...Option,
+) []string {
+	t.Helper()
+	f, err := CreateFile(name, cfg)
+	require.NoError(t, err)
+	m := NewMsgLogger(append(opts, WithWriter(f))...)
+	texts := make([]string, n)
+	for i := range n {
+		texts[i] = fmt.Sprintf("msg-%03d", i)
+		msg := stateMsg(texts[i])
+		for car := range 40 {
+			msg.Cars = append(msg.Cars, &racestatev1.Car{CarIdx: int32(car), Pos: int32(i)})
+		}
+		ts := fileStart.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, m.LogAt(msg.ProtoReflect(), ts))
+	}
+	require.NoError(t, f.Close())
+	return texts
+}
+
+func readFile(t *testing.T, name string) []string {
+	t.Helper()
+	r, err := Open(name)
+	require.NoError(t, err)
+	defer r.Close()
+	return messageTexts(readAll(t, NewMsgLogger(WithReader(r))))
+}
+
+func TestCreateFile_Gzip(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "grpc.bin")
+	texts := writeFile(t, name, FileConfig{Compression: CompressionGzip}, 100)
+	plain := filepath.Join(dir, "plain.bin")
+	writeFile(t, plain, FileConfig{}, 100)
+
+	f, err := os.Open(name)
+	require.NoError(t, err)
+	defer f.Close()
+	assert.True(t, IsCompressed(f))
+	st, err := f.Stat()
+	require.NoError(t, err)
+	stPlain, err := os.Stat(plain)
+	require.NoError(t, err)
+	assert.Less(t, st.Size(), stPlain.Size())
+
+	assert.Equal(t, texts, readFile(t, name))
+}
+
+func TestCreateFile_GzipNotClosed(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "grpc.bin")
+	cfg := FileConfig{Compression: CompressionGzip, FlushInterval: 10 * time.Millisecond}
+	f, err := CreateFile(name, cfg)
+	require.NoError(t, err)
+	m := NewMsgLogger(WithWriter(f))
+	require.NoError(t, m.Log(stateMsg("msg-a").ProtoReflect()))
+	require.NoError(t, m.Log(stateMsg("msg-b").ProtoReflect()))
+
+	// a crash loses nothing but the end of the compressed stream once the
+	// data is flushed
+	readable := func() []string {
+		r, err := Open(name)
+		require.NoError(t, err)
+		defer r.Close()
+		reader := NewMsgLogger(WithReader(r))
+		var ret []string
+		for {
+			msg, err := reader.ReadNext()
+			if err != nil {
+				return ret
+			}
+			ret = append(ret,
+				msg.Interface().(*racestatev1.PublishStateRequest).Messages[0].Msg)
+		}
+	}
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{"msg-a", "msg-b"}, readable())
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, f.Close())
+}
+
+func TestRotatingFile(t *testing.T) {
+	for _, c := range []Compression{CompressionNone, CompressionGzip} {
+		t.Run("compression "+string(c), func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "grpc.bin")
+			cfg := FileConfig{Compression: c, MaxAge: 10 * time.Minute}
+			texts := writeFile(t, name, cfg, 35, WithStateDelta(4), WithEventKey("ev"))
+
+			manifest, err := ReadManifest(ManifestName(name))
+			require.NoError(t, err)
+			require.Len(t, manifest.Segments, 4)
+			assert.Equal(t, "grpc.0001.bin", manifest.Segments[0].File)
+			for _, seg := range manifest.Segments {
+				assert.True(t, seg.Complete)
+			}
+			assert.Equal(t, 5, manifest.Segments[3].Records)
+
+			// each segment can be read on its own
+			seg := readFile(t, filepath.Join(filepath.Dir(name), "grpc.0002.bin"))
+			assert.Equal(t, texts[10:20], seg)
+			f, err := os.Open(filepath.Join(filepath.Dir(name), "grpc.0002.bin"))
+			require.NoError(t, err)
+			defer f.Close()
+			r, err := decompress(f)
+			require.NoError(t, err)
+			m := NewMsgLogger(WithReader(r))
+			msg, err := m.ReadNext()
+			require.NoError(t, err)
+			assert.Equal(t, "ev", m.FileHeader().EventKey)
+			state := msg.Interface().(*racestatev1.PublishStateRequest)
+			assert.False(t, statedelta.IsDelta(state))
+
+			// the manifest returns all segments
+			assert.Equal(t, texts, readFile(t, ManifestName(name)))
+		})
+	}
+}
+
+func TestRotatingFile_MaxSegments(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "grpc.bin")
+	cfg := FileConfig{MaxAge: 10 * time.Minute, MaxSegments: 2}
+	// the upload is in the third segment
+	require.NoError(t, WritePosition(PositionName(ManifestName(name)),
+		Position{Segment: "grpc.0003.bin", Offset: 100}))
+	texts := writeFile(t, name, cfg, 35)
+
+	manifest, err := ReadManifest(ManifestName(name))
+	require.NoError(t, err)
+	require.Len(t, manifest.Segments, 3)
+	assert.Equal(t, "grpc.0001.bin", manifest.Segments[0].File)
+	assert.Equal(t, "grpc.0003.bin", manifest.Segments[1].File)
+	assert.Equal(t, "grpc.0004.bin", manifest.Segments[2].File)
+	_, err = os.Stat(filepath.Join(dir, "grpc.0002.bin"))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, append(texts[:10:10], texts[20:]...), readFile(t, ManifestName(name)))
+}
+
+func TestRotatingFile_MaxSegmentsNotUploaded(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "grpc.bin")
+	cfg := FileConfig{MaxAge: 10 * time.Minute, MaxSegments: 2}
+	texts := writeFile(t, name, cfg, 35)
+
+	manifest, err := ReadManifest(ManifestName(name))
+	require.NoError(t, err)
+	assert.Len(t, manifest.Segments, 4)
+	assert.Equal(t, texts, readFile(t, ManifestName(name)))
+}
+
+func TestParseCompression(t *testing.T) {
+	for in, want := range map[string]Compression{
+		"": CompressionNone, "none": CompressionNone, "GZIP": CompressionGzip,
+	} {
+		got, err := ParseCompression(in)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParseCompression("zip")
+	assert.ErrorIs(t, err, ErrUnsupportedCompression)
+}
@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+)
+
+// FileNamer creates the names of msg log files from a template.
+// Placeholders:
+//   - {key}: event key
+//   - {session}: name of the recorded session
+//   - {date}: event time (20060102-150405)
+//
+// A name which was already returned is made unique by appending the event key,
+// so an event with several heats doesn't overwrite the earlier ones.
+type FileNamer struct {
+	template string
+	mu       sync.Mutex
+	used     map[string]bool
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func NewFileNamer(template string) *FileNamer {
+	return &FileNamer{template: template, used: map[string]bool{}}
+}
+
+// Name returns the file name for event
+func (n *FileNamer) Name(event *eventv1.Event, session string) string {
+	date := ""
+	if event.GetEventTime() != nil {
+		date = event.GetEventTime().AsTime().Format("20060102-150405")
+	}
+	name := strings.NewReplacer(
+		"{key}", safeFileChars(event.GetKey()),
+		"{session}", safeFileChars(session),
+		"{date}", date,
+	).Replace(n.template)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.used[name] {
+		ext := filepath.Ext(name)
+		name = strings.TrimSuffix(name, ext) + "-" + safeFileChars(event.GetKey()) + ext
+	}
+	n.used[name] = true
+	return name
+}
+
+func safeFileChars(s string) string {
+	return strings.Trim(unsafeFileChars.ReplaceAllString(s, "_"), "_")
+}
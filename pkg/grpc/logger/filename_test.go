@@ -0,0 +1,33 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestFileNamer_Name(t *testing.T) {
+	eventTime := timestamppb.New(time.Date(2024, 3, 17, 14, 0, 0, 0, time.UTC))
+	heat1 := &eventv1.Event{Key: "k1", EventTime: eventTime}
+	heat2 := &eventv1.Event{Key: "k2", EventTime: eventTime}
+
+	t.Run("placeholders", func(t *testing.T) {
+		n := NewFileNamer("logs/{date}-{session}-{key}.msglog")
+		assert.Equal(t, "logs/20240317-140000-HEAT_1-k1.msglog", n.Name(heat1, "HEAT 1"))
+		assert.Equal(t, "logs/20240317-140000-HEAT_2-k2.msglog", n.Name(heat2, "HEAT 2"))
+	})
+
+	t.Run("used names get the event key", func(t *testing.T) {
+		n := NewFileNamer("grpc.log")
+		assert.Equal(t, "grpc.log", n.Name(heat1, "HEAT 1"))
+		assert.Equal(t, "grpc-k2.log", n.Name(heat2, "HEAT 2"))
+	})
+
+	t.Run("unsafe chars", func(t *testing.T) {
+		n := NewFileNamer("{session}.log")
+		assert.Equal(t, "a_.._b.log", n.Name(heat1, "/a/../b "))
+	})
+}
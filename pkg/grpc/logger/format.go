@@ -0,0 +1,323 @@
+package logger
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Msg log formats
+//
+// v1 has no file header. Each record consists of
+//   - msg type (byte)
+//   - msg length (uint16, little endian)
+//   - proto encoded message
+//
+// v2 starts with a file header
+//   - magic "RLML"
+//   - version (byte)
+//   - creation time (int64 unix nanos, little endian)
+//   - event key (uvarint length + bytes)
+//   - crc32c of the preceding header bytes (uint32, little endian)
+//
+// followed by records
+//   - marker 0xA5 0x5A
+//   - msg type (byte)
+//   - msg length (uvarint)
+//   - capture time (int64 unix nanos, little endian)
+//   - proto encoded message
+//   - crc32c of msg type to message (uint32, little endian)
+//
+// The marker and the checksum allow a reader to skip corrupted records.
+// A file header may also appear between records (concatenated files).
+const (
+	FormatV1 byte = 1
+	FormatV2 byte = 2
+
+	maxRecordLen  = 64 << 20
+	maxKeyLen     = 1024
+	readChunkSize = 64 << 10
+)
+
+var (
+	fileMagic    = []byte("RLML")
+	recordMarker = []byte{0xa5, 0x5a}
+	crcTable     = crc32.MakeTable(crc32.Castagnoli)
+)
+
+var (
+	ErrNoFileHeader       = errors.New("no msg log file header")
+	ErrUnsupportedVersion = errors.New("unsupported msg log version")
+	ErrCorruptRecord      = errors.New("corrupt msg log record")
+	ErrUnknownMsgType     = errors.New("unknown msg type")
+)
+
+// FileHeader describes a v2 msg log file
+type FileHeader struct {
+	Version  byte
+	Created  time.Time
+	EventKey string
+}
+
+func appendFileHeader(b []byte, h *FileHeader) []byte {
+	start := len(b)
+	b = append(b, fileMagic...)
+	b = append(b, h.Version)
+	//nolint:gosec // unix nano is positive
+	b = binary.LittleEndian.AppendUint64(b, uint64(h.Created.UnixNano()))
+	b = binary.AppendUvarint(b, uint64(len(h.EventKey)))
+	b = append(b, h.EventKey...)
+	return binary.LittleEndian.AppendUint32(b, crc32.Checksum(b[start:], crcTable))
+}
+
+// AppendRecord appends msg as v2 record (without file header) to b.
+func AppendRecord(b []byte, msg proto.Message, ts time.Time) ([]byte, error) {
+	t := MsgType(msg)
+	if t == MsgUnknown {
+		return b, fmt.Errorf("%w: %T", ErrUnknownMsgType, msg)
+	}
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return b, err
+	}
+	return appendRecord(b, t, body, ts), nil
+}
+
+func appendRecord(b []byte, t byte, body []byte, ts time.Time) []byte {
+	b = append(b, recordMarker...)
+	start := len(b)
+	b = append(b, t)
+	b = binary.AppendUvarint(b, uint64(len(body)))
+	//nolint:gosec // unix nano is positive
+	b = binary.LittleEndian.AppendUint64(b, uint64(ts.UnixNano()))
+	b = append(b, body...)
+	return binary.LittleEndian.AppendUint32(b, crc32.Checksum(b[start:], crcTable))
+}
+
+// recordReader reads exactly the requested bytes from r.
+// Bytes may be pushed back in order to resync after a corrupted record.
+// The bytes read since the last mark are captured for checksum computation.
+type recordReader struct {
+	r       io.Reader
+	pending []byte
+	offset  int64 // bytes consumed
+	capture []byte
+}
+
+// read returns the next n bytes.
+// The length may come from corrupted data, so the buffer is not allocated
+// upfront but grows with the data actually read.
+func (rr *recordReader) read(n int) ([]byte, error) {
+	b := make([]byte, 0, min(n, readChunkSize))
+	c := min(n, len(rr.pending))
+	b = append(b, rr.pending[:c]...)
+	rr.pending = rr.pending[c:]
+	var err error
+	for len(b) < n && err == nil {
+		start := len(b)
+		b = slices.Grow(b, min(n-start, readChunkSize))
+		var m int
+		m, err = io.ReadFull(rr.r, b[start:start+min(n-start, readChunkSize)])
+		b = b[:start+m]
+	}
+	rr.offset += int64(len(b))
+	rr.capture = append(rr.capture, b...)
+	if err != nil {
+		if len(b) > 0 && errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+func (rr *recordReader) readByte() (byte, error) {
+	b, err := rr.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// ReadByte implements io.ByteReader (used by binary.ReadUvarint)
+func (rr *recordReader) ReadByte() (byte, error) {
+	return rr.readByte()
+}
+
+func (rr *recordReader) readUvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(rr)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return 0, ErrCorruptRecord // overflow
+	}
+	return v, err
+}
+
+// mark starts a new capture
+func (rr *recordReader) mark() {
+	rr.capture = rr.capture[:0]
+}
+
+func (rr *recordReader) unread(b []byte) {
+	rr.pending = append(append([]byte{}, b...), rr.pending...)
+	rr.offset -= int64(len(b))
+}
+
+// resync pushes back the captured bytes (except the first one) and skips to the
+// next possible start of a record or file header.
+func (rr *recordReader) resync() error {
+	rr.unread(rr.capture[1:])
+	rr.mark()
+	for {
+		c, err := rr.readByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if c == recordMarker[0] || c == fileMagic[0] {
+			rr.unread([]byte{c})
+			rr.mark()
+			return nil
+		}
+	}
+}
+
+// readFileHeader reads a v2 file header.
+// Returns ErrNoFileHeader if the data doesn't start with the magic bytes.
+func (rr *recordReader) readFileHeader() (*FileHeader, error) {
+	rr.mark()
+	magic, err := rr.read(len(fileMagic))
+	if err != nil {
+		return nil, err
+	}
+	if string(magic) != string(fileMagic) {
+		return nil, ErrNoFileHeader
+	}
+	version, err := rr.readByte()
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	if version != FormatV2 {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	created, err := rr.read(8)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	keyLen, err := rr.readUvarint()
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	if keyLen > maxKeyLen {
+		return nil, fmt.Errorf("%w: file header", ErrCorruptRecord)
+	}
+	key, err := rr.read(int(keyLen))
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	sum := crc32.Checksum(rr.capture, crcTable)
+	crc, err := rr.read(4)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	if binary.LittleEndian.Uint32(crc) != sum {
+		return nil, fmt.Errorf("%w: file header", ErrCorruptRecord)
+	}
+	return &FileHeader{
+		Version: version,
+		//nolint:gosec // written by appendFileHeader
+		Created:  time.Unix(0, int64(binary.LittleEndian.Uint64(created))),
+		EventKey: string(key),
+	}, nil
+}
+
+// readRecordV2 reads a v2 record.
+// Returns ErrCorruptRecord if the data at the current position is not a valid record.
+//
+//nolint:cyclop // by design
+func (rr *recordReader) readRecordV2() (t byte, body []byte, ts time.Time, err error) {
+	rr.mark()
+	marker, err := rr.read(len(recordMarker))
+	if err != nil {
+		return 0, nil, ts, err
+	}
+	if marker[0] != recordMarker[0] || marker[1] != recordMarker[1] {
+		return 0, nil, ts, ErrCorruptRecord
+	}
+	start := len(rr.capture)
+	if t, err = rr.readByte(); err != nil {
+		return 0, nil, ts, unexpected(err)
+	}
+	msgLen, err := rr.readUvarint()
+	if err != nil {
+		return 0, nil, ts, unexpected(err)
+	}
+	if msgLen > maxRecordLen {
+		return 0, nil, ts, ErrCorruptRecord
+	}
+	tsBytes, err := rr.read(8)
+	if err != nil {
+		return 0, nil, ts, unexpected(err)
+	}
+	if body, err = rr.read(int(msgLen)); err != nil {
+		return 0, nil, ts, unexpected(err)
+	}
+	sum := crc32.Checksum(rr.capture[start:], crcTable)
+	crc, err := rr.read(4)
+	if err != nil {
+		return 0, nil, ts, unexpected(err)
+	}
+	if binary.LittleEndian.Uint32(crc) != sum {
+		return 0, nil, ts, ErrCorruptRecord
+	}
+	//nolint:gosec // written by appendRecord
+	return t, body, time.Unix(0, int64(binary.LittleEndian.Uint64(tsBytes))), nil
+}
+
+// readRecordV1 reads a v1 record
+func (rr *recordReader) readRecordV1() (t byte, body []byte, err error) {
+	rr.mark()
+	h, err := rr.read(3)
+	if err != nil {
+		return 0, nil, err
+	}
+	body, err = rr.read(int(binary.LittleEndian.Uint16(h[1:])))
+	if err != nil {
+		return 0, nil, unexpected(err)
+	}
+	return h[0], body, nil
+}
+
+// unexpected converts io.EOF to io.ErrUnexpectedEOF (data ends within a record)
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReadFileHeader reads the file header at the start of r and returns it along
+// with its size. ErrNoFileHeader is returned for v1 files.
+func ReadFileHeader(r io.Reader) (*FileHeader, int64, error) {
+	rr := &recordReader{r: r}
+	c, err := rr.readByte()
+	if err != nil {
+		return nil, 0, err
+	}
+	if c != fileMagic[0] {
+		return nil, 0, ErrNoFileHeader
+	}
+	rr.unread([]byte{c})
+	h, err := rr.readFileHeader()
+	if err != nil {
+		return nil, 0, err
+	}
+	return h, rr.offset, nil
+}
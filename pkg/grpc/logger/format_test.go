@@ -0,0 +1,186 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func stateMsg(text string) *racestatev1.PublishStateRequest {
+	return &racestatev1.PublishStateRequest{
+		Event:    eventSel,
+		Messages: []*racestatev1.Message{{Msg: text}},
+	}
+}
+
+func readAll(t *testing.T, m *MsgLogger) []proto.Message {
+	t.Helper()
+	ret := []proto.Message{}
+	for {
+		msg, err := m.ReadNext()
+		if errors.Is(err, io.EOF) {
+			return ret
+		}
+		require.NoError(t, err)
+		ret = append(ret, msg.Interface())
+	}
+}
+
+func messageTexts(msgs []proto.Message) []string {
+	ret := []string{}
+	for _, msg := range msgs {
+		if req, ok := msg.(*racestatev1.PublishStateRequest); ok {
+			ret = append(ret, req.Messages[0].Msg)
+		}
+	}
+	return ret
+}
+
+func TestFormatV2(t *testing.T) {
+	buf := bytes.Buffer{}
+	w := NewMsgLogger(WithWriter(&buf))
+	ts := time.Date(2024, 3, 17, 14, 5, 12, 0, time.UTC)
+	register := &providerv1.RegisterEventRequest{Event: &eventv1.Event{Key: "myEvent"}}
+	require.NoError(t, w.LogAt(register.ProtoReflect(), ts))
+	require.NoError(t, w.LogAt(stateMsg("a").ProtoReflect(), ts.Add(time.Second)))
+
+	r := NewMsgLogger(WithReader(bytes.NewReader(buf.Bytes())))
+	msg, err := r.ReadNext()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(register, msg.Interface()))
+	assert.Equal(t, FormatV2, r.Format())
+	require.NotNil(t, r.FileHeader())
+	assert.Equal(t, "myEvent", r.FileHeader().EventKey)
+	assert.True(t, ts.Equal(r.FileHeader().Created))
+	assert.True(t, ts.Equal(r.Timestamp()))
+
+	msg, err = r.ReadNext()
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(stateMsg("a"), msg.Interface()))
+	assert.True(t, ts.Add(time.Second).Equal(r.Timestamp()))
+	_, err = r.ReadNext()
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, int64(buf.Len()), r.Offset())
+}
+
+func TestFormatV1(t *testing.T) {
+	buf := bytes.Buffer{}
+	for _, text := range []string{"a", "b"} {
+		b, err := proto.Marshal(stateMsg(text))
+		require.NoError(t, err)
+		require.NoError(t, binary.Write(&buf, binary.LittleEndian, struct {
+			MsgType byte
+			MsgLen  uint16
+		}{MsgState, uint16(len(b))}))
+		buf.Write(b)
+	}
+
+	r := NewMsgLogger(WithReader(&buf))
+	assert.Equal(t, []string{"a", "b"}, messageTexts(readAll(t, r)))
+	assert.Equal(t, FormatV1, r.Format())
+	assert.Nil(t, r.FileHeader())
+}
+
+func TestFormatV2_LargeMessage(t *testing.T) {
+	large := strings.Repeat("x", 100*1024)
+	buf := bytes.Buffer{}
+	w := NewMsgLogger(WithWriter(&buf))
+	require.NoError(t, w.Log(stateMsg(large).ProtoReflect()))
+	require.NoError(t, w.Log(stateMsg("b").ProtoReflect()))
+
+	r := NewMsgLogger(WithReader(&buf))
+	assert.Equal(t, []string{large, "b"}, messageTexts(readAll(t, r)))
+}
+
+func TestFormatV2_SkipCorrupted(t *testing.T) {
+	buf := bytes.Buffer{}
+	w := NewMsgLogger(WithWriter(&buf))
+	for _, text := range []string{"msg-a", "msg-b", "msg-c", "msg-d", "msg-e"} {
+		require.NoError(t, w.Log(stateMsg(text).ProtoReflect()))
+	}
+	data := buf.Bytes()
+	// corrupt the body of "msg-b" and the length of "msg-d"
+	idx := bytes.Index(data, []byte("msg-b"))
+	data[idx] = 'X'
+	idx = bytes.LastIndex(data[:bytes.Index(data, []byte("msg-d"))], recordMarker)
+	data[idx+3] += 2
+
+	r := NewMsgLogger(WithReader(bytes.NewReader(data)))
+	assert.Equal(t, []string{"msg-a", "msg-c", "msg-e"}, messageTexts(readAll(t, r)))
+	assert.Equal(t, 2, r.Skipped())
+}
+
+func TestFormatV2_LengthBeyondEOF(t *testing.T) {
+	buf := bytes.Buffer{}
+	w := NewMsgLogger(WithWriter(&buf))
+	for _, text := range []string{"msg-a", "msg-b", "msg-c", "msg-d", "msg-e"} {
+		require.NoError(t, w.Log(stateMsg(text).ProtoReflect()))
+	}
+	data := buf.Bytes()
+	// replace the length of "msg-c" by a large one pointing beyond the end
+	idx := bytes.LastIndex(data[:bytes.Index(data, []byte("msg-c"))], recordMarker)
+	corrupted := append([]byte{}, data[:idx+3]...)
+	corrupted = binary.AppendUvarint(corrupted, 32<<20)
+	corrupted = append(corrupted, data[idx+4:]...)
+
+	r := NewMsgLogger(WithReader(bytes.NewReader(corrupted)))
+	assert.Equal(t, []string{"msg-a", "msg-b", "msg-d", "msg-e"},
+		messageTexts(readAll(t, r)))
+	assert.Equal(t, 1, r.Skipped())
+}
+
+func TestFormatV2_Concatenated(t *testing.T) {
+	buf := bytes.Buffer{}
+	for _, key := range []string{"first", "second"} {
+		w := NewMsgLogger(WithWriter(&buf), WithEventKey(key))
+		require.NoError(t, w.Log(stateMsg(key).ProtoReflect()))
+	}
+
+	r := NewMsgLogger(WithReader(&buf))
+	assert.Equal(t, []string{"first", "second"}, messageTexts(readAll(t, r)))
+	assert.Equal(t, "second", r.FileHeader().EventKey)
+	assert.Equal(t, 0, r.Skipped())
+}
+
+func TestFormatV2_IncompleteRecord(t *testing.T) {
+	buf := bytes.Buffer{}
+	w := NewMsgLogger(WithWriter(&buf))
+	require.NoError(t, w.Log(stateMsg("a").ProtoReflect()))
+	require.NoError(t, w.Log(stateMsg("b").ProtoReflect()))
+	data := buf.Bytes()[:buf.Len()-3]
+
+	r := NewMsgLogger(WithReader(bytes.NewReader(data)))
+	_, err := r.ReadNext()
+	require.NoError(t, err)
+	_, err = r.ReadNext()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestReadFileHeader(t *testing.T) {
+	buf := bytes.Buffer{}
+	w := NewMsgLogger(WithWriter(&buf), WithEventKey("myEvent"))
+	require.NoError(t, w.Log(stateMsg("a").ProtoReflect()))
+
+	h, size, err := ReadFileHeader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, "myEvent", h.EventKey)
+
+	// records after the header can be read without header detection
+	r := NewMsgLogger(WithReader(bytes.NewReader(buf.Bytes()[size:])),
+		WithFormat(FormatV2))
+	assert.Equal(t, []string{"a"}, messageTexts(readAll(t, r)))
+
+	_, _, err = ReadFileHeader(bytes.NewReader([]byte{MsgState, 0, 0}))
+	assert.ErrorIs(t, err, ErrNoFileHeader)
+}
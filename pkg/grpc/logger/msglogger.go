@@ -1,11 +1,11 @@
 package logger
 
 import (
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
 	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
@@ -13,6 +13,7 @@ import (
 	"google.golang.org/protobuf/reflect/protoreflect"
 
 	"github.com/mpapenbr/go-racelogger/log"
+	"github.com/mpapenbr/go-racelogger/pkg/statedelta"
 )
 
 type (
@@ -22,12 +23,19 @@ type (
 		m     sync.Mutex
 		count int
 		debug bool
+		delta *statedelta.Encoder
+		// writer
+		eventKey      string
+		noFileHeader  bool
+		headerWritten bool
+		// reader
+		rr        *recordReader
+		format    byte
+		header    *FileHeader
+		timestamp time.Time
+		skipped   int
 	}
 	Option func(*MsgLogger)
-	header struct {
-		MsgType byte
-		MsgLen  uint16
-	}
 )
 
 const (
@@ -40,7 +48,12 @@ const (
 	MsgEventExtraInfo
 )
 
-var ErrNoReader = errors.New("no reader")
+var (
+	ErrNoReader = errors.New("no reader")
+	// the record was read but the message could not be decoded.
+	// The reader may continue with the next record.
+	ErrInvalidMessage = errors.New("invalid message")
+)
 
 func NewMsgLogger(opts ...Option) *MsgLogger {
 	ret := &MsgLogger{}
@@ -69,8 +82,70 @@ func WithDebug(b bool) Option {
 	}
 }
 
-//nolint:govet // false positive
+// WithStateDelta writes state messages as deltas with a keyframe every
+// keyframeInterval messages. Values <= 1 write every state message completely.
+// Readers have to use a statedelta.Decoder to get the complete messages.
+func WithStateDelta(keyframeInterval int) Option {
+	return func(ml *MsgLogger) {
+		if keyframeInterval > 1 {
+			ml.delta = statedelta.NewEncoder(keyframeInterval)
+		} else {
+			ml.delta = nil
+		}
+	}
+}
+
+// WithEventKey sets the event key of the file header.
+// By default the key of the first register message is used.
+func WithEventKey(key string) Option {
+	return func(ml *MsgLogger) {
+		ml.eventKey = key
+	}
+}
+
+// WithoutFileHeader writes just the records (used when appending to an
+// existing msg log or for storing single records)
+func WithoutFileHeader() Option {
+	return func(ml *MsgLogger) {
+		ml.noFileHeader = true
+	}
+}
+
+// WithFormat reads records of the given format without expecting a file header.
+// Used when reading from an offset within a msg log.
+func WithFormat(format byte) Option {
+	return func(ml *MsgLogger) {
+		ml.format = format
+	}
+}
+
+// MsgType returns the msg type used in the msg log for msg
+func MsgType(msg proto.Message) byte {
+	switch msg.(type) {
+	case *providerv1.RegisterEventRequest:
+		return MsgRegister
+	case *providerv1.UnregisterEventRequest:
+		return MsgUnregister
+	case *racestatev1.PublishStateRequest:
+		return MsgState
+	case *racestatev1.PublishDriverDataRequest:
+		return MsgDriverData
+	case *racestatev1.PublishSpeedmapRequest:
+		return MsgSpeedmap
+	case *racestatev1.PublishEventExtraInfoRequest:
+		return MsgEventExtraInfo
+	default:
+		return MsgUnknown
+	}
+}
+
+// Log writes msg with the current time as capture time
 func (m *MsgLogger) Log(msg protoreflect.Message) error {
+	return m.LogAt(msg, time.Now())
+}
+
+// LogAt writes msg with ts as capture time. Unknown messages are ignored.
+func (m *MsgLogger) LogAt(msg protoreflect.Message, ts time.Time) error {
 	if m.w == nil {
 		return nil
 	}
@@ -80,54 +155,208 @@ func (m *MsgLogger) Log(msg protoreflect.Message) error {
 		return nil
 	}
 
-	if b, err := proto.Marshal(msg.Interface()); err == nil {
-		m.m.Lock()
-		defer m.m.Unlock()
-		h := header{MsgType: t, MsgLen: uint16(len(b))}
-
-		if err := m.write(m.w, h, b); err != nil {
+	m.m.Lock()
+	defer m.m.Unlock()
+	if s, ok := m.w.(Segmenter); ok {
+		newSegment, err := s.Rotate(ts)
+		if err != nil {
 			return err
 		}
-		m.count++
-	} else {
+		if newSegment && m.headerWritten {
+			// the segment has to be readable on its own
+			m.headerWritten = false
+			if m.delta != nil {
+				m.delta.Reset()
+			}
+		}
+	}
+	if state, ok := msg.Interface().(*racestatev1.PublishStateRequest); ok &&
+		m.delta != nil {
+		msg = m.delta.Encode(state).ProtoReflect()
+	}
+	b, err := proto.Marshal(msg.Interface())
+	if err != nil {
 		return err
 	}
-
+	var buf []byte
+	if !m.noFileHeader && !m.headerWritten {
+		// later segments get the key of the first one
+		m.eventKey = m.headerEventKey(msg.Interface())
+		buf = appendFileHeader(buf, &FileHeader{
+			Version:  FormatV2,
+			Created:  ts,
+			EventKey: m.eventKey,
+		})
+	}
+	// header and record are written at once (see spool.Writer)
+	buf = appendRecord(buf, t, b, ts)
+	if _, err := m.w.Write(buf); err != nil {
+		return err
+	}
+	m.headerWritten = true
+	m.count++
 	return nil
 }
 
-func (m *MsgLogger) write(w io.Writer, h header, b []byte) error {
-	if err := binary.Write(w, binary.LittleEndian, h); err != nil {
-		return err
+func (m *MsgLogger) headerEventKey(msg proto.Message) string {
+	if m.eventKey != "" {
+		return m.eventKey
 	}
-	if _, err := w.Write(b); err != nil {
-		return err
+	if req, ok := msg.(*providerv1.RegisterEventRequest); ok {
+		return req.GetEvent().GetKey()
 	}
-	return nil
+	return ""
+}
+
+// FileHeader returns the file header of the data read so far.
+// Returns nil for v1 files.
+func (m *MsgLogger) FileHeader() *FileHeader {
+	return m.header
 }
 
-//nolint:cyclop // false positive
+// Format returns the format of the data read so far (0 if not yet known)
+func (m *MsgLogger) Format() byte {
+	return m.format
+}
+
+// Timestamp returns the capture time of the last message read.
+// The zero time is returned for v1 files.
+func (m *MsgLogger) Timestamp() time.Time {
+	return m.timestamp
+}
+
+// Offset returns the number of bytes consumed by the reader
+func (m *MsgLogger) Offset() int64 {
+	if m.rr == nil {
+		return 0
+	}
+	return m.rr.offset
+}
+
+// Skipped returns the number of corrupted records skipped by the reader
+func (m *MsgLogger) Skipped() int {
+	return m.skipped
+}
+
+// ReadNext reads the next message. The format of the data is detected by
+// the file header (v1 files don't have one). Compressed data is detected
+// unless the format is given by WithFormat.
+// Returns nil (and no error) for unknown message types and io.EOF at the end
+// of the data. If the data ends within a record io.ErrUnexpectedEOF is returned.
+// Corrupted records of v2 files are skipped.
 func (m *MsgLogger) ReadNext() (protoreflect.Message, error) {
 	if m.r == nil {
 		return nil, ErrNoReader
 	}
+	if m.rr == nil {
+		r := m.r
+		if m.format == 0 {
+			var err error
+			if r, err = decompress(m.r); err != nil {
+				return nil, err
+			}
+		}
+		m.rr = &recordReader{r: r}
+	}
+	if m.format == 0 {
+		if err := m.detectFormat(); err != nil {
+			return nil, err
+		}
+	}
 
-	h := header{}
-	if err := binary.Read(m.r, binary.LittleEndian, &h); err != nil {
-		if errors.Is(err, io.EOF) {
-			return nil, io.EOF
+	var t byte
+	var b []byte
+	var err error
+	if m.format == FormatV1 {
+		t, b, err = m.rr.readRecordV1()
+	} else {
+		t, b, err = m.readNextV2()
+	}
+	if err != nil {
+		if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Error("could not read record", log.ErrorField(err))
 		}
-		log.Error("could not read header", log.ErrorField(err))
 		return nil, err
 	}
+	return m.unmarshal(t, b)
+}
 
-	b := make([]byte, h.MsgLen)
-	if _, err := io.ReadFull(m.r, b); err != nil {
-		return nil, err
+func (m *MsgLogger) detectFormat() error {
+	c, err := m.rr.readByte()
+	if err != nil {
+		return err
+	}
+	m.rr.unread([]byte{c})
+	if c != fileMagic[0] {
+		m.format = FormatV1
+		return nil
+	}
+	h, err := m.rr.readFileHeader()
+	if err != nil {
+		return err
+	}
+	m.header = h
+	m.format = h.Version
+	return nil
+}
+
+// readNextV2 reads the next valid record, skipping corrupted data.
+// If the data ends within a record the captured bytes are searched for a valid
+// record, too. The length of a corrupted record may point beyond the end of the
+// data, so this can't be distinguished from an incomplete last record upfront.
+//
+//nolint:cyclop // by design
+func (m *MsgLogger) readNextV2() (byte, []byte, error) {
+	skipOffset := int64(-1) // start of the skipped data
+	corrupted := false
+	skip := func() {
+		if skipOffset >= 0 {
+			m.skipped++
+			log.Warn("skipped corrupted record", log.Int64("offset", skipOffset))
+		}
+		skipOffset = -1
+		corrupted = false
 	}
+	for {
+		t, b, ts, err := m.rr.readRecordV2()
+		if err == nil {
+			skip()
+			m.timestamp = ts
+			return t, b, nil
+		}
+		if len(m.rr.capture) == 0 ||
+			!errors.Is(err, ErrCorruptRecord) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, err
+		}
+		if errors.Is(err, ErrCorruptRecord) {
+			corrupted = true
+		}
+		offset := m.rr.offset - int64(len(m.rr.capture))
+		if m.rr.capture[0] == fileMagic[0] {
+			// may be the header of a concatenated file
+			m.rr.unread(m.rr.capture)
+			if h, herr := m.rr.readFileHeader(); herr == nil {
+				skip()
+				m.header = h
+				continue
+			}
+		}
+		if skipOffset < 0 {
+			skipOffset = offset
+		}
+		if err := m.rr.resync(); err != nil {
+			// no valid record up to the end of the data
+			if corrupted {
+				skip()
+			}
+			return 0, nil, err
+		}
+	}
+}
 
+func (m *MsgLogger) unmarshal(t byte, b []byte) (protoreflect.Message, error) {
 	var msg proto.Message
-	switch h.MsgType {
+	switch t {
 	case MsgRegister:
 		msg = &providerv1.RegisterEventRequest{}
 	case MsgUnregister:
@@ -145,28 +374,16 @@ func (m *MsgLogger) ReadNext() (protoreflect.Message, error) {
 	}
 
 	if err := proto.Unmarshal(b, msg); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrInvalidMessage, err)
 	}
 
 	return msg.ProtoReflect(), nil
 }
 
-func (m *MsgLogger) getMsgType(msg interface{}) byte {
-	switch c := msg.(type) {
-	case *providerv1.RegisterEventRequest:
-		return MsgRegister
-	case *providerv1.UnregisterEventRequest:
-		return MsgUnregister
-	case *racestatev1.PublishStateRequest:
-		return MsgState
-	case *racestatev1.PublishDriverDataRequest:
-		return MsgDriverData
-	case *racestatev1.PublishSpeedmapRequest:
-		return MsgSpeedmap
-	case *racestatev1.PublishEventExtraInfoRequest:
-		return MsgEventExtraInfo
-	default:
-		fmt.Printf("%T\n", c)
+func (m *MsgLogger) getMsgType(msg proto.Message) byte {
+	t := MsgType(msg)
+	if t == MsgUnknown {
+		fmt.Printf("%T\n", msg)
 	}
-	return MsgUnknown
+	return t
 }
@@ -12,8 +12,11 @@ import (
 	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
 	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/mpapenbr/go-racelogger/pkg/statedelta"
 )
 
 var eventSel = &commonv1.EventSelector{
@@ -82,3 +85,33 @@ func TestMsgLogger_Log(t *testing.T) {
 		})
 	}
 }
+
+func TestMsgLogger_StateDelta(t *testing.T) {
+	states := []*racestatev1.PublishStateRequest{}
+	for i := range 5 {
+		states = append(states, &racestatev1.PublishStateRequest{
+			Event: eventSel,
+			Cars: []*racestatev1.Car{
+				{CarIdx: 1, Pos: 1, Dist: float32(100 * i)},
+				{CarIdx: 2, Pos: 2, Dist: float32(90 * i)},
+			},
+		})
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, 100))
+	writeLogger := NewMsgLogger(WithWriter(buf), WithStateDelta(3))
+	for _, s := range states {
+		assert.NoError(t, writeLogger.Log(s.ProtoReflect()))
+	}
+
+	readLogger := NewMsgLogger(WithReader(bytes.NewBuffer(buf.Bytes())))
+	dec := statedelta.NewDecoder()
+	for i, s := range states {
+		msg, err := readLogger.ReadNext()
+		assert.NoError(t, err)
+		req := msg.Interface().(*racestatev1.PublishStateRequest)
+		assert.Equal(t, i%3 != 0, statedelta.IsDelta(req))
+		got, err := dec.Decode(req)
+		assert.NoError(t, err)
+		assert.True(t, proto.Equal(s, got))
+	}
+}
@@ -0,0 +1,326 @@
+package logger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mpapenbr/go-racelogger/log"
+)
+
+const (
+	// ManifestExtension is appended to the name of a rotated msg log for its manifest
+	ManifestExtension = ".manifest.json"
+	// PositionExtension is appended to the name of a manifest for the upload position
+	PositionExtension = ".offset"
+)
+
+type (
+	// Segmenter is implemented by writers which split a msg log into several
+	// files. MsgLogger calls Rotate before each record. If a new file was started
+	// the logger writes a file header and a complete state message first, so
+	// each segment can be read on its own.
+	Segmenter interface {
+		Rotate(ts time.Time) (bool, error)
+	}
+
+	// Manifest lists the segments of a rotated msg log
+	Manifest struct {
+		Segments []*Segment `json:"segments"`
+	}
+	Segment struct {
+		// file name relative to the manifest
+		File    string    `json:"file"`
+		Created time.Time `json:"created"`
+		// capture time of the last record
+		Last    time.Time `json:"last"`
+		Records int       `json:"records"`
+		Size    int64     `json:"size"`
+		// the segment was closed regularly
+		Complete bool `json:"complete"`
+	}
+
+	// Position is the position of the next record to upload in a rotated msg
+	// log. The offset refers to the uncompressed data of the segment, so it
+	// stays valid if older segments are deleted.
+	Position struct {
+		Segment string `json:"segment"` // file name of the segment
+		Offset  int64  `json:"offset"`
+	}
+
+	// RotatingFile writes a msg log to segments <name>.0001<ext>, <name>.0002<ext>...
+	// A new segment is started when the current one exceeds the size or age
+	// limit. The segments are listed in the manifest <name>.manifest.json, which
+	// is updated whenever a segment is started or closed.
+	RotatingFile struct {
+		name     string
+		cfg      FileConfig
+		mu       sync.Mutex
+		manifest Manifest
+		num      int
+		cur      io.WriteCloser
+		counter  *countingWriter
+		segment  *Segment
+		ts       time.Time
+	}
+)
+
+var _ Segmenter = (*RotatingFile)(nil)
+
+// IsManifest returns true if name is the manifest of a rotated msg log
+func IsManifest(name string) bool {
+	return strings.HasSuffix(name, ManifestExtension)
+}
+
+// ManifestName returns the name of the manifest for the msg log name
+func ManifestName(name string) string {
+	return name + ManifestExtension
+}
+
+// ReadManifest reads the manifest of a rotated msg log
+func ReadManifest(name string) (*Manifest, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Manifest{}
+	if err := json.Unmarshal(data, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// PositionName returns the name of the upload position for the manifest name
+func PositionName(manifest string) string {
+	return manifest + PositionExtension
+}
+
+// ReadPosition reads an upload position written by WritePosition
+func ReadPosition(name string) (*Position, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Position{}
+	if err := json.Unmarshal(data, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// WritePosition writes the upload position atomically (write temp file, then rename)
+func WritePosition(name string, pos Position) error {
+	data, err := json.Marshal(pos)
+	if err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func NewRotatingFile(name string, cfg FileConfig) (*RotatingFile, error) {
+	ret := &RotatingFile{name: name, cfg: cfg}
+	// create the manifest right away to detect problems early
+	if err := ret.writeManifest(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Rotate starts a new segment if there is none yet or the limits of the
+// current one are reached. Returns true if a new segment was started.
+func (f *RotatingFile) Rotate(ts time.Time) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ts = ts
+	if f.cur != nil && !f.full(ts) {
+		return false, nil
+	}
+	return true, f.next(ts)
+}
+
+func (f *RotatingFile) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cur == nil {
+		if f.ts.IsZero() {
+			f.ts = time.Now()
+		}
+		if err := f.next(f.ts); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.cur.Write(b)
+	if err != nil {
+		return n, err
+	}
+	f.segment.Records++
+	f.segment.Last = f.ts
+	return n, nil
+}
+
+// Close closes the current segment and marks it complete in the manifest
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closeSegment()
+}
+
+func (f *RotatingFile) full(ts time.Time) bool {
+	return (f.cfg.MaxSize > 0 && f.counter.n.Load() >= f.cfg.MaxSize) ||
+		(f.cfg.MaxAge > 0 && ts.Sub(f.segment.Created) >= f.cfg.MaxAge)
+}
+
+func (f *RotatingFile) next(ts time.Time) error {
+	if err := f.closeSegment(); err != nil {
+		return err
+	}
+	f.num++
+	ext := filepath.Ext(f.name)
+	fn := fmt.Sprintf("%s.%04d%s", strings.TrimSuffix(f.name, ext), f.num, ext)
+	file, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	f.counter = &countingWriter{w: file}
+	f.cur = compress(f.counter, f.cfg)
+	f.segment = &Segment{File: filepath.Base(fn), Created: ts}
+	f.manifest.Segments = append(f.manifest.Segments, f.segment)
+	f.removeOldSegments()
+	return f.writeManifest()
+}
+
+func (f *RotatingFile) closeSegment() error {
+	if f.cur == nil {
+		return nil
+	}
+	err := f.cur.Close()
+	f.segment.Size = f.counter.n.Load()
+	f.segment.Complete = err == nil
+	f.cur = nil
+	return errors.Join(err, f.writeManifest())
+}
+
+// removeOldSegments deletes the oldest segments if there are more than
+// MaxSegments. The first segment contains the registration of the event and
+// is kept. Only segments which are uploaded completely are deleted (see
+// uploadedSegments), the others are kept until a later rotation.
+func (f *RotatingFile) removeOldSegments() {
+	maxSegments := f.cfg.MaxSegments
+	if maxSegments <= 0 {
+		return
+	}
+	maxSegments = max(maxSegments, 2)
+	if len(f.manifest.Segments) <= maxSegments {
+		return
+	}
+	uploaded := f.uploadedSegments()
+	for len(f.manifest.Segments) > maxSegments {
+		old := f.manifest.Segments[1]
+		if !uploaded[old.File] {
+			log.Debug("Keeping msg log segment until it is uploaded",
+				log.String("file", old.File))
+			return
+		}
+		fn := filepath.Join(filepath.Dir(f.name), old.File)
+		if err := os.Remove(fn); err != nil {
+			log.Warn("Could not remove msg log segment",
+				log.String("file", fn), log.ErrorField(err))
+		}
+		f.manifest.Segments = append(f.manifest.Segments[:1], f.manifest.Segments[2:]...)
+	}
+}
+
+// uploadedSegments returns the segments before the upload position stored
+// next to the manifest (see PositionName). Without a position nothing has
+// been uploaded yet.
+func (f *RotatingFile) uploadedSegments() map[string]bool {
+	pos, err := ReadPosition(PositionName(ManifestName(f.name)))
+	if err != nil {
+		return nil
+	}
+	ret := map[string]bool{}
+	for _, seg := range f.manifest.Segments {
+		if seg.File == pos.Segment {
+			return ret
+		}
+		ret[seg.File] = true
+	}
+	return nil
+}
+
+// writeManifest writes the manifest atomically (write temp file, then rename)
+func (f *RotatingFile) writeManifest() error {
+	data, err := json.MarshalIndent(f.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	fn := ManifestName(f.name)
+	tmp := fn + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// countingWriter counts the bytes written to the file.
+// The compressed data may be flushed by a timer (see gzipWriter).
+type countingWriter struct {
+	w io.WriteCloser
+	n atomic.Int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+func (c *countingWriter) Close() error {
+	return c.w.Close()
+}
+
+// segmentReader reads the segments of a manifest one after another
+type segmentReader struct {
+	io.Reader
+	files []*os.File
+}
+
+func (s *segmentReader) Close() error {
+	errs := make([]error, 0, len(s.files))
+	for _, f := range s.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func openSegments(name string) (io.ReadCloser, error) {
+	m, err := ReadManifest(name)
+	if err != nil {
+		return nil, err
+	}
+	ret := &segmentReader{}
+	readers := make([]io.Reader, 0, len(m.Segments))
+	for _, seg := range m.Segments {
+		f, err := os.Open(filepath.Join(filepath.Dir(name), seg.File))
+		if err != nil {
+			//nolint:errcheck // the open error is reported
+			ret.Close()
+			return nil, err
+		}
+		ret.files = append(ret.files, f)
+		readers = append(readers, f)
+	}
+	ret.Reader = io.MultiReader(readers...)
+	return ret, nil
+}
@@ -0,0 +1,478 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/go-racelogger/log"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+const (
+	queueFileName    = "retry-queue.bin"
+	queueHeadName    = "retry-queue.head"
+	queueCorruptName = "retry-queue.corrupt"
+)
+
+type (
+	// RetryQueue stores messages which could not be sent to the backend in a file
+	// and redelivers them in the order they were queued.
+	// The backlog is limited by size and age. If a limit is exceeded the oldest
+	// messages are dropped.
+	RetryQueue struct {
+		dir        string
+		maxSize    int64
+		maxAge     time.Duration
+		minBackoff time.Duration
+		maxBackoff time.Duration
+		send       func(msg proto.Message) error
+		log        *log.Logger
+
+		publishMu sync.Mutex // keeps the order of concurrent Publish calls
+
+		mu      sync.Mutex
+		f       *os.File
+		head    int64 // file offset of the first pending entry
+		end     int64 // file offset after the last entry
+		entries []queueEntry
+		seq     uint64
+		dropped int
+		corrupt int
+		notify  chan struct{}
+		done    chan struct{}
+		cancel  context.CancelFunc
+	}
+	queueEntry struct {
+		seq    uint64
+		offset int64
+		size   int64
+		queued time.Time
+	}
+	// QueueStats describes the current backlog of a RetryQueue
+	QueueStats struct {
+		Pending   int           // number of messages waiting for redelivery
+		Bytes     int64         // size of the pending messages
+		OldestAge time.Duration // age of the oldest pending message
+		Dropped   int           // messages dropped due to size/age limits
+		Corrupt   int           // unreadable messages (moved to retry-queue.corrupt)
+	}
+	RetryQueueOption func(*RetryQueue)
+)
+
+// WithQueueMaxSize limits the size of the pending messages (bytes)
+func WithQueueMaxSize(n int64) RetryQueueOption {
+	return func(q *RetryQueue) { q.maxSize = n }
+}
+
+// WithQueueMaxAge drops pending messages older than d
+func WithQueueMaxAge(d time.Duration) RetryQueueOption {
+	return func(q *RetryQueue) { q.maxAge = d }
+}
+
+// WithQueueBackoff sets the wait time range between two delivery attempts
+func WithQueueBackoff(minBackoff, maxBackoff time.Duration) RetryQueueOption {
+	return func(q *RetryQueue) {
+		q.minBackoff = minBackoff
+		q.maxBackoff = maxBackoff
+	}
+}
+
+func WithQueueLogger(l *log.Logger) RetryQueueOption {
+	return func(q *RetryQueue) { q.log = l }
+}
+
+// NewRetryQueue opens (or creates) the retry queue in dir.
+// Messages left from a previous run are delivered once the queue is started.
+// The target is used for redelivery and must not be configured with a RetryQueue.
+//
+//nolint:whitespace // can't get different linters happy
+func NewRetryQueue(
+	dir string,
+	target *DataProviderClient,
+	opts ...RetryQueueOption,
+) (*RetryQueue, error) {
+	q := &RetryQueue{
+		dir:        dir,
+		maxSize:    64 * 1024 * 1024,
+		maxAge:     2 * time.Hour,
+		minBackoff: time.Second,
+		maxBackoff: 30 * time.Second,
+		send:       target.Send,
+		log:        log.Default().Named("queue"),
+		notify:     make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := q.open(); err != nil {
+		return nil, err
+	}
+	if len(q.entries) > 0 {
+		q.log.Info("Pending messages found", log.Int("pending", len(q.entries)))
+	}
+	return q, nil
+}
+
+// Start starts the redelivery of pending messages
+func (q *RetryQueue) Start(ctx context.Context) {
+	ctx, q.cancel = context.WithCancel(ctx)
+	q.done = make(chan struct{})
+	go q.deliver(ctx)
+}
+
+// Close stops the redelivery. Pending messages remain in the queue file.
+func (q *RetryQueue) Close() error {
+	if q.cancel != nil {
+		q.cancel()
+		<-q.done
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.entries) > 0 {
+		q.log.Warn("Closing retry queue with pending messages",
+			log.Int("pending", len(q.entries)))
+	}
+	return q.f.Close()
+}
+
+func (q *RetryQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ret := QueueStats{Pending: len(q.entries), Dropped: q.dropped, Corrupt: q.corrupt}
+	if len(q.entries) > 0 {
+		ret.Bytes = q.end - q.entries[0].offset
+		ret.OldestAge = time.Since(q.entries[0].queued)
+	}
+	return ret
+}
+
+// Publish sends the message using send. If the backend is not reachable the
+// message is queued for redelivery. While messages are pending new messages are
+// queued as well to preserve the order.
+func (q *RetryQueue) Publish(msg proto.Message, send func() error) error {
+	// a message must not overtake one which is about to be queued
+	q.publishMu.Lock()
+	defer q.publishMu.Unlock()
+	if !q.Pending() {
+		err := send()
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+		q.log.Debug("Could not send message. Queueing", log.ErrorField(err))
+	}
+	return q.enqueue(msg)
+}
+
+// Pending returns true if there are messages waiting for redelivery
+func (q *RetryQueue) Pending() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries) > 0
+}
+
+func isRetryable(err error) bool {
+	//nolint:exhaustive // only these codes are temporary
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted,
+		codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *RetryQueue) enqueue(msg proto.Message) error {
+	now := time.Now()
+	buf, err := logger.AppendRecord(nil, msg, now)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, err := q.f.WriteAt(buf, q.end); err != nil {
+		return err
+	}
+	q.seq++
+	q.entries = append(q.entries, queueEntry{
+		seq: q.seq, offset: q.end, size: int64(len(buf)), queued: now,
+	})
+	q.end += int64(len(buf))
+	q.enforceLimits(now)
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// enforceLimits drops the oldest entries exceeding size or age limits.
+// Must be called with q.mu held.
+func (q *RetryQueue) enforceLimits(now time.Time) {
+	drop := 0
+	for drop < len(q.entries) {
+		e := q.entries[drop]
+		tooOld := q.maxAge > 0 && now.Sub(e.queued) > q.maxAge
+		tooBig := q.maxSize > 0 && q.end-e.offset > q.maxSize
+		if !tooOld && !tooBig {
+			break
+		}
+		drop++
+	}
+	if drop == 0 {
+		return
+	}
+	q.log.Warn("Retry queue limit reached. Dropping oldest messages",
+		log.Int("dropped", drop))
+	q.dropped += drop
+	q.entries = q.entries[drop:]
+	q.advanceHead()
+}
+
+// advanceHead moves the head to the first pending entry and persists it.
+// Must be called with q.mu held.
+func (q *RetryQueue) advanceHead() {
+	if len(q.entries) == 0 {
+		// queue is empty, start over with an empty file
+		if err := q.f.Truncate(0); err != nil {
+			q.log.Warn("Could not truncate queue file", log.ErrorField(err))
+		} else {
+			q.end = 0
+		}
+		q.head = q.end
+	} else {
+		q.head = q.entries[0].offset
+		if q.maxSize > 0 && q.head > q.maxSize {
+			if err := q.compact(); err != nil {
+				q.log.Warn("Could not compact queue file", log.ErrorField(err))
+			}
+		}
+	}
+	if err := q.saveHead(); err != nil {
+		q.log.Warn("Could not store queue head", log.ErrorField(err))
+	}
+}
+
+// compact removes the already processed entries from the queue file.
+// Must be called with q.mu held.
+func (q *RetryQueue) compact() error {
+	data := make([]byte, q.end-q.head)
+	if _, err := q.f.ReadAt(data, q.head); err != nil {
+		return err
+	}
+	fn := filepath.Join(q.dir, queueFileName)
+	if err := os.WriteFile(fn+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	if err := q.f.Close(); err != nil {
+		return err
+	}
+	renameErr := os.Rename(fn+".tmp", fn)
+	f, err := os.OpenFile(fn, os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	q.f = f
+	if renameErr != nil {
+		return renameErr
+	}
+	for i := range q.entries {
+		q.entries[i].offset -= q.head
+	}
+	q.end -= q.head
+	q.head = 0
+	return nil
+}
+
+func (q *RetryQueue) deliver(ctx context.Context) {
+	defer close(q.done)
+	backoff := q.minBackoff
+	for {
+		entry, msg, ok := q.peek()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.notify:
+			}
+			continue
+		}
+		err := q.send(msg)
+		if err != nil && isRetryable(err) {
+			q.log.Debug("Redelivery failed",
+				log.Duration("backoff", backoff), log.ErrorField(err))
+			t := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+			backoff = min(2*backoff, q.maxBackoff)
+			continue
+		}
+		if err != nil {
+			q.log.Warn("Message rejected by server. Dropping", log.ErrorField(err))
+		}
+		backoff = q.minBackoff
+		q.pop(entry.seq)
+	}
+}
+
+// peek returns the first pending entry and its message.
+// Entries which can't be read are moved to the corrupt file.
+func (q *RetryQueue) peek() (queueEntry, proto.Message, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for {
+		q.enforceLimits(time.Now())
+		if len(q.entries) == 0 {
+			return queueEntry{}, nil, false
+		}
+		e := q.entries[0]
+		data, msg, err := q.readEntry(e)
+		if err == nil {
+			return e, msg, true
+		}
+		q.dropCorrupt(e, data, err)
+	}
+}
+
+// readEntry reads the message of e. data is returned if it could be read.
+// Must be called with q.mu held.
+func (q *RetryQueue) readEntry(e queueEntry) (data []byte, msg proto.Message, err error) {
+	data = make([]byte, e.size)
+	if _, err := q.f.ReadAt(data, e.offset); err != nil {
+		return nil, nil, err
+	}
+	m, err := logger.NewMsgLogger(
+		logger.WithReader(bytes.NewReader(data)),
+		logger.WithFormat(logger.FormatV2)).ReadNext()
+	if err != nil {
+		return data, nil, err
+	}
+	if m == nil {
+		return data, nil, logger.ErrUnknownMsgType
+	}
+	return data, m.Interface(), nil
+}
+
+// dropCorrupt removes the unreadable entry e from the queue.
+// Its data (if available) is appended to the corrupt file for later inspection.
+// Must be called with q.mu held.
+func (q *RetryQueue) dropCorrupt(e queueEntry, data []byte, err error) {
+	q.corrupt++
+	q.log.Error("Could not read queued message. Dropping",
+		log.Int64("offset", e.offset),
+		log.Int64("size", e.size),
+		log.Int("corrupt", q.corrupt),
+		log.ErrorField(err))
+	if data != nil {
+		if err := q.saveCorrupt(data); err != nil {
+			q.log.Warn("Could not store corrupt message", log.ErrorField(err))
+		}
+	}
+	q.entries = q.entries[1:]
+	q.advanceHead()
+}
+
+func (q *RetryQueue) saveCorrupt(data []byte) error {
+	f, err := os.OpenFile(filepath.Join(q.dir, queueCorruptName),
+		os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		//nolint:errcheck // write error is reported
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (q *RetryQueue) pop(seq uint64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	// the entry may have been dropped meanwhile
+	if len(q.entries) == 0 || q.entries[0].seq != seq {
+		return
+	}
+	q.entries = q.entries[1:]
+	q.advanceHead()
+}
+
+// open opens the queue file and restores the pending entries
+func (q *RetryQueue) open() error {
+	f, err := os.OpenFile(filepath.Join(q.dir, queueFileName),
+		os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	q.f = f
+	q.head = q.loadHead()
+	q.end = q.head
+	total := fileSize(f)
+	r := logger.NewMsgLogger(
+		logger.WithReader(io.NewSectionReader(f, q.head, total-q.head)),
+		logger.WithFormat(logger.FormatV2))
+	for {
+		if _, err := r.ReadNext(); err != nil {
+			break // end of queue or incomplete entry
+		}
+		size := r.Offset() - (q.end - q.head)
+		q.seq++
+		q.entries = append(q.entries, queueEntry{
+			seq:    q.seq,
+			offset: q.end,
+			size:   size,
+			queued: r.Timestamp(),
+		})
+		q.end += size
+	}
+	// discard incomplete data at the end of the file
+	return f.Truncate(q.end)
+}
+
+func fileSize(f *os.File) int64 {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+func (q *RetryQueue) loadHead() int64 {
+	data, err := os.ReadFile(filepath.Join(q.dir, queueHeadName))
+	if err != nil {
+		return 0
+	}
+	head, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || head > fileSize(q.f) {
+		return 0
+	}
+	return head
+}
+
+// saveHead stores the head offset atomically (write temp file, then rename)
+func (q *RetryQueue) saveHead() error {
+	fn := filepath.Join(q.dir, queueHeadName)
+	tmp := fn + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(q.head, 10)), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
@@ -0,0 +1,227 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+var errUnavailable = status.Error(codes.Unavailable, "backend not reachable")
+
+func stateMsg(key string) *racestatev1.PublishStateRequest {
+	return &racestatev1.PublishStateRequest{
+		Event: &commonv1.EventSelector{Arg: &commonv1.EventSelector_Key{Key: key}},
+	}
+}
+
+// fakeBackend records the keys of the received state messages.
+// It is unavailable until online is set.
+type fakeBackend struct {
+	mu       sync.Mutex
+	online   bool
+	received []string
+}
+
+func (b *fakeBackend) send(msg proto.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.online {
+		return errUnavailable
+	}
+	if req, ok := msg.(*racestatev1.PublishStateRequest); ok {
+		b.received = append(b.received, req.GetEvent().GetKey())
+	}
+	return nil
+}
+
+func (b *fakeBackend) setOnline(online bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.online = online
+}
+
+func (b *fakeBackend) keys() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string{}, b.received...)
+}
+
+//nolint:whitespace // can't get different linters happy
+func newTestQueue(
+	t *testing.T,
+	dir string,
+	b *fakeBackend,
+	opts ...RetryQueueOption,
+) *RetryQueue {
+	t.Helper()
+	opts = append([]RetryQueueOption{
+		WithQueueBackoff(time.Millisecond, 5*time.Millisecond),
+	}, opts...)
+	q, err := NewRetryQueue(dir, NewDataProviderClient(), opts...)
+	require.NoError(t, err)
+	q.send = b.send
+	return q
+}
+
+// publish publishes the messages the same way the DataProviderClient does
+func publish(t *testing.T, q *RetryQueue, b *fakeBackend, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		msg := stateMsg(key)
+		require.NoError(t, q.Publish(msg, func() error { return b.send(msg) }))
+	}
+}
+
+func TestRetryQueue_OrderedRedelivery(t *testing.T) {
+	b := &fakeBackend{}
+	q := newTestQueue(t, t.TempDir(), b)
+	q.Start(context.Background())
+	defer q.Close()
+
+	publish(t, q, b, "1", "2", "3")
+	assert.Equal(t, 3, q.Stats().Pending)
+
+	b.setOnline(true)
+	// must be queued as well, otherwise it would overtake the pending messages
+	publish(t, q, b, "4")
+
+	assert.Eventually(t, func() bool { return !q.Pending() },
+		time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"1", "2", "3", "4"}, b.keys())
+
+	// queue is empty, messages are sent directly
+	publish(t, q, b, "5")
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, b.keys())
+}
+
+func TestRetryQueue_ConcurrentPublish(t *testing.T) {
+	b := &fakeBackend{}
+	q := newTestQueue(t, t.TempDir(), b)
+	q.Start(context.Background())
+	defer q.Close()
+
+	// the send of the first message fails after the backend came online
+	sending := make(chan struct{})
+	release := make(chan struct{})
+	first := stateMsg("1")
+	firstDone := make(chan error)
+	go func() {
+		firstDone <- q.Publish(first, func() error {
+			close(sending)
+			<-release
+			return errUnavailable
+		})
+	}()
+	<-sending
+	b.setOnline(true)
+	secondDone := make(chan struct{})
+	go func() {
+		defer close(secondDone)
+		second := stateMsg("2")
+		assert.NoError(t, q.Publish(second, func() error { return b.send(second) }))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	require.NoError(t, <-firstDone)
+	<-secondDone
+
+	assert.Eventually(t, func() bool { return !q.Pending() },
+		time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"1", "2"}, b.keys(), "message 2 must not overtake 1")
+}
+
+func TestRetryQueue_Restore(t *testing.T) {
+	dir := t.TempDir()
+	b := &fakeBackend{}
+	q := newTestQueue(t, dir, b)
+	publish(t, q, b, "1", "2")
+	require.NoError(t, q.Close())
+
+	b.setOnline(true)
+	q = newTestQueue(t, dir, b)
+	assert.Equal(t, 2, q.Stats().Pending)
+	q.Start(context.Background())
+	defer q.Close()
+	assert.Eventually(t, func() bool { return !q.Pending() },
+		time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"1", "2"}, b.keys())
+}
+
+func TestRetryQueue_MaxSize(t *testing.T) {
+	b := &fakeBackend{}
+	entry, err := logger.AppendRecord(nil, stateMsg("0"), time.Now())
+	require.NoError(t, err)
+	entrySize := int64(len(entry))
+	q := newTestQueue(t, t.TempDir(), b, WithQueueMaxSize(3*entrySize))
+	defer q.Close()
+	for i := range 5 {
+		publish(t, q, b, fmt.Sprintf("%d", i))
+	}
+	stats := q.Stats()
+	assert.Equal(t, 3, stats.Pending)
+	assert.Equal(t, 2, stats.Dropped)
+	assert.Equal(t, 3*entrySize, stats.Bytes)
+
+	b.setOnline(true)
+	q.Start(context.Background())
+	assert.Eventually(t, func() bool { return !q.Pending() },
+		time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"2", "3", "4"}, b.keys())
+}
+
+func TestRetryQueue_MaxAge(t *testing.T) {
+	b := &fakeBackend{}
+	q := newTestQueue(t, t.TempDir(), b, WithQueueMaxAge(20*time.Millisecond))
+	defer q.Close()
+	publish(t, q, b, "1", "2")
+	time.Sleep(30 * time.Millisecond)
+	publish(t, q, b, "3")
+
+	stats := q.Stats()
+	assert.Equal(t, 1, stats.Pending)
+	assert.Equal(t, 2, stats.Dropped)
+}
+
+func TestRetryQueue_Corrupt(t *testing.T) {
+	dir := t.TempDir()
+	b := &fakeBackend{}
+	q := newTestQueue(t, dir, b)
+	defer q.Close()
+	publish(t, q, b, "1", "2", "3")
+
+	// corrupt the second message
+	q.mu.Lock()
+	e := q.entries[1]
+	data := make([]byte, e.size)
+	_, err := q.f.ReadAt(data, e.offset)
+	require.NoError(t, err)
+	data[bytes.IndexByte(data, '2')] = 'X'
+	_, err = q.f.WriteAt(data, e.offset)
+	require.NoError(t, err)
+	q.mu.Unlock()
+
+	b.setOnline(true)
+	q.Start(context.Background())
+	assert.Eventually(t, func() bool { return !q.Pending() },
+		time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"1", "3"}, b.keys())
+	assert.Equal(t, 1, q.Stats().Corrupt)
+	saved, err := os.ReadFile(filepath.Join(dir, queueCorruptName))
+	require.NoError(t, err)
+	assert.Equal(t, data, saved)
+}
@@ -0,0 +1,169 @@
+package standin
+
+import (
+	"context"
+
+	eventv1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/event/v1/eventv1grpc"
+	providerv1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/provider/v1/providerv1grpc"
+	racestatev1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/racestate/v1/racestatev1grpc"
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/go-racelogger/pkg/statedelta"
+)
+
+// The unary services used by the DataProviderClient.
+// Messages are accepted if they refer to a registered event.
+type (
+	providerService struct {
+		providerv1grpc.UnimplementedProviderServiceServer
+		s *Server
+	}
+	stateService struct {
+		racestatev1grpc.UnimplementedRaceStateServiceServer
+		s *Server
+	}
+	eventService struct {
+		eventv1grpc.UnimplementedEventServiceServer
+		s *Server
+	}
+)
+
+//nolint:whitespace // can't get different linters happy
+func (p *providerService) RegisterEvent(
+	ctx context.Context,
+	req *providerv1.RegisterEventRequest,
+) (*providerv1.RegisterEventResponse, error) {
+	if err := p.s.checkToken(ctx); err != nil {
+		return nil, err
+	}
+	if req.GetEvent().GetKey() == "" {
+		return nil, p.s.reject(codes.InvalidArgument, "missing event key")
+	}
+	p.s.mu.Lock()
+	p.s.events[req.Event.Key] = true
+	p.s.mu.Unlock()
+	p.s.accept(req)
+	return &providerv1.RegisterEventResponse{Event: req.Event, Track: req.Track}, nil
+}
+
+//nolint:whitespace // can't get different linters happy
+func (p *providerService) UnregisterEvent(
+	ctx context.Context,
+	req *providerv1.UnregisterEventRequest,
+) (*providerv1.UnregisterEventResponse, error) {
+	if err := p.s.checkEvent(ctx, req.EventSelector); err != nil {
+		return nil, err
+	}
+	p.s.accept(req)
+	return &providerv1.UnregisterEventResponse{}, nil
+}
+
+//nolint:whitespace // can't get different linters happy
+func (st *stateService) PublishState(
+	ctx context.Context,
+	req *racestatev1.PublishStateRequest,
+) (*racestatev1.PublishStateResponse, error) {
+	if err := st.s.checkEvent(ctx, req.Event); err != nil {
+		return nil, err
+	}
+	if statedelta.IsDelta(req) {
+		return nil, st.s.reject(codes.InvalidArgument, "delta encoded state message")
+	}
+	st.s.accept(req)
+	return &racestatev1.PublishStateResponse{}, nil
+}
+
+//nolint:whitespace // can't get different linters happy
+func (st *stateService) PublishDriverData(
+	ctx context.Context,
+	req *racestatev1.PublishDriverDataRequest,
+) (*racestatev1.PublishDriverDataResponse, error) {
+	if err := st.s.checkEvent(ctx, req.Event); err != nil {
+		return nil, err
+	}
+	st.s.accept(req)
+	return &racestatev1.PublishDriverDataResponse{}, nil
+}
+
+//nolint:whitespace // can't get different linters happy
+func (st *stateService) PublishSpeedmap(
+	ctx context.Context,
+	req *racestatev1.PublishSpeedmapRequest,
+) (*racestatev1.PublishSpeedmapResponse, error) {
+	if err := st.s.checkEvent(ctx, req.Event); err != nil {
+		return nil, err
+	}
+	st.s.accept(req)
+	return &racestatev1.PublishSpeedmapResponse{}, nil
+}
+
+//nolint:whitespace // can't get different linters happy
+func (st *stateService) PublishEventExtraInfo(
+	ctx context.Context,
+	req *racestatev1.PublishEventExtraInfoRequest,
+) (*racestatev1.PublishEventExtraInfoResponse, error) {
+	if err := st.s.checkEvent(ctx, req.Event); err != nil {
+		return nil, err
+	}
+	st.s.accept(req)
+	return &racestatev1.PublishEventExtraInfoResponse{}, nil
+}
+
+//nolint:whitespace // can't get different linters happy
+func (e *eventService) DeleteEvent(
+	ctx context.Context,
+	req *eventv1.DeleteEventRequest,
+) (*eventv1.DeleteEventResponse, error) {
+	if err := e.s.checkToken(ctx); err != nil {
+		return nil, err
+	}
+	e.s.mu.Lock()
+	defer e.s.mu.Unlock()
+	if !e.s.events[req.EventSelector.GetKey()] {
+		return nil, status.Error(codes.NotFound, "event not found")
+	}
+	delete(e.s.events, req.EventSelector.GetKey())
+	return &eventv1.DeleteEventResponse{}, nil
+}
+
+func (s *Server) checkToken(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if s.token != "" && first(md.Get("api-token")) != s.token {
+		return s.reject(codes.Unauthenticated, "invalid token")
+	}
+	return nil
+}
+
+// checkEvent checks the token and if the event is registered
+func (s *Server) checkEvent(ctx context.Context, sel *commonv1.EventSelector) error {
+	if err := s.checkToken(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	registered := s.events[sel.GetKey()]
+	s.mu.Unlock()
+	if !registered {
+		return s.reject(codes.FailedPrecondition, "event not registered")
+	}
+	return nil
+}
+
+func (s *Server) accept(msg proto.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.received = append(s.received, msg)
+}
+
+func (s *Server) reject(code codes.Code, msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejected++
+	return status.Error(code, msg)
+}
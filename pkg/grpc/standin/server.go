@@ -0,0 +1,190 @@
+// Package standin provides a local stand-in for the backend server.
+// It accepts the publish stream and the unary calls of the DataProviderClient.
+// It is used for testing the clients without a backend and by the dry run of
+// the import command.
+package standin
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	eventv1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/event/v1/eventv1grpc"
+	providerv1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/provider/v1/providerv1grpc"
+	racestatev1grpc "buf.build/gen/go/mpapenbr/iracelog/grpc/go/iracelog/racestate/v1/racestatev1grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	owngrpc "github.com/mpapenbr/go-racelogger/pkg/grpc"
+)
+
+type (
+	// Server accepts the publish stream and the unary calls and stores the
+	// received messages
+	Server struct {
+		ackEvery  int
+		breakAt   map[uint64]bool
+		token     string
+		mu        sync.Mutex
+		received  []proto.Message
+		lastSeq   map[string]uint64 // per stream-id
+		streams   int
+		duplicate int
+		events    map[string]bool // registered events
+		rejected  int
+	}
+	Option func(*Server)
+)
+
+var _ owngrpc.PublishStreamServer = (*Server)(nil)
+
+// WithAckEvery sends an ack after every n received frames (default 1).
+// An ack is always sent when the client closes the stream.
+func WithAckEvery(n int) Option {
+	return func(s *Server) { s.ackEvery = n }
+}
+
+// WithBreakAt aborts the stream (once) after receiving the frame with seq
+// without acknowledging it. Used to simulate connection problems.
+func WithBreakAt(seq ...uint64) Option {
+	return func(s *Server) {
+		for _, v := range seq {
+			s.breakAt[v] = true
+		}
+	}
+}
+
+// WithToken rejects streams without this api-token
+func WithToken(token string) Option {
+	return func(s *Server) { s.token = token }
+}
+
+func New(opts ...Option) *Server {
+	ret := &Server{
+		ackEvery: 1,
+		breakAt:  map[uint64]bool{},
+		lastSeq:  map[string]uint64{},
+		events:   map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	return ret
+}
+
+// Register registers the stream service and the unary services at the grpc server
+func (s *Server) Register(gs *grpc.Server) {
+	owngrpc.RegisterPublishStreamServer(gs, s)
+	providerv1grpc.RegisterProviderServiceServer(gs, &providerService{s: s})
+	racestatev1grpc.RegisterRaceStateServiceServer(gs, &stateService{s: s})
+	eventv1grpc.RegisterEventServiceServer(gs, &eventService{s: s})
+}
+
+// Serve creates a grpc server serving the services on lis.
+// Blocks until the listener fails.
+func (s *Server) Serve(lis net.Listener) error {
+	gs := grpc.NewServer()
+	s.Register(gs)
+	return gs.Serve(lis)
+}
+
+// Received returns the messages received so far (without duplicates)
+func (s *Server) Received() []proto.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]proto.Message{}, s.received...)
+}
+
+// Streams returns the number of streams opened by clients
+func (s *Server) Streams() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streams
+}
+
+// Rejected returns the number of rejected calls
+func (s *Server) Rejected() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rejected
+}
+
+// Duplicates returns the number of frames received more than once
+func (s *Server) Duplicates() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.duplicate
+}
+
+//nolint:whitespace // can't get different linters happy
+func (s *Server) Publish(
+	stream grpc.BidiStreamingServer[owngrpc.StreamFrame, owngrpc.StreamAck],
+) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	if s.token != "" && first(md.Get("api-token")) != s.token {
+		return status.Error(codes.Unauthenticated, "invalid token")
+	}
+	id := first(md.Get(owngrpc.StreamIDKey))
+	s.mu.Lock()
+	s.streams++
+	s.mu.Unlock()
+
+	count := 0
+	for {
+		frame, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.Send(&owngrpc.StreamAck{Seq: s.last(id)})
+		}
+		if err != nil {
+			return err
+		}
+		if s.shouldBreak(frame.Seq) {
+			return status.Error(codes.Unavailable, "stream aborted by stand-in")
+		}
+		s.store(id, frame)
+		count++
+		if count%s.ackEvery == 0 {
+			if err := stream.Send(&owngrpc.StreamAck{Seq: s.last(id)}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) store(id string, frame *owngrpc.StreamFrame) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if frame.Seq <= s.lastSeq[id] {
+		s.duplicate++
+		return
+	}
+	s.lastSeq[id] = frame.Seq
+	s.received = append(s.received, frame.Msg)
+}
+
+func (s *Server) last(id string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastSeq[id]
+}
+
+func (s *Server) shouldBreak(seq uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.breakAt[seq] {
+		delete(s.breakAt, seq)
+		return true
+	}
+	return false
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,396 @@
+package grpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/go-racelogger/log"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+// The publish stream is a bidirectional stream. The client sends StreamFrames
+// with increasing sequence numbers, the server answers with StreamAcks.
+// An ack confirms that all frames up to (and including) its sequence number
+// are persisted. Frames which are not acknowledged are sent again when the
+// stream is reopened. The server identifies the client by the stream-id
+// metadata and ignores frames it already received.
+//
+// The messages are encoded by the codec registered as content-subtype "rlstream":
+//   - frame: uvarint seq, msg type (see logger.Msg*), proto encoded message
+//   - ack: uvarint seq
+const (
+	StreamServiceName = "racelogger.stream.v1.PublishStreamService"
+	StreamMethod      = "/" + StreamServiceName + "/Publish"
+	StreamIDKey       = "stream-id"
+	streamCodecName   = "rlstream"
+)
+
+type (
+	StreamFrame struct {
+		Seq uint64
+		Msg proto.Message
+	}
+	StreamAck struct {
+		Seq uint64
+	}
+	// PublishStreamServer is implemented by servers accepting the publish stream
+	PublishStreamServer interface {
+		Publish(stream grpc.BidiStreamingServer[StreamFrame, StreamAck]) error
+	}
+	streamCodec struct{}
+)
+
+var (
+	ErrInvalidFrame  = errors.New("invalid stream frame")
+	ErrStreamNotOpen = errors.New("publish stream not open")
+)
+
+var StreamServiceDesc = grpc.ServiceDesc{
+	ServiceName: StreamServiceName,
+	HandlerType: (*PublishStreamServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Publish",
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				return srv.(PublishStreamServer).Publish(
+					&grpc.GenericServerStream[StreamFrame, StreamAck]{ServerStream: stream})
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
+
+func init() {
+	encoding.RegisterCodec(streamCodec{})
+}
+
+func RegisterPublishStreamServer(s grpc.ServiceRegistrar, srv PublishStreamServer) {
+	s.RegisterService(&StreamServiceDesc, srv)
+}
+
+func (streamCodec) Name() string {
+	return streamCodecName
+}
+
+func (streamCodec) Marshal(v any) ([]byte, error) {
+	switch m := v.(type) {
+	case *StreamAck:
+		return binary.AppendUvarint(nil, m.Seq), nil
+	case *StreamFrame:
+		t := frameMsgType(m.Msg)
+		if t == logger.MsgUnknown {
+			return nil, fmt.Errorf("%w: unsupported message %T", ErrInvalidFrame, m.Msg)
+		}
+		b := binary.AppendUvarint(nil, m.Seq)
+		b = append(b, t)
+		return proto.MarshalOptions{}.MarshalAppend(b, m.Msg)
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %T", ErrInvalidFrame, v)
+	}
+}
+
+func (streamCodec) Unmarshal(data []byte, v any) error {
+	seq, n := binary.Uvarint(data)
+	if n <= 0 {
+		return ErrInvalidFrame
+	}
+	switch m := v.(type) {
+	case *StreamAck:
+		m.Seq = seq
+		return nil
+	case *StreamFrame:
+		if len(data) <= n {
+			return ErrInvalidFrame
+		}
+		var msg proto.Message
+		switch data[n] {
+		case logger.MsgState:
+			msg = &racestatev1.PublishStateRequest{}
+		case logger.MsgSpeedmap:
+			msg = &racestatev1.PublishSpeedmapRequest{}
+		case logger.MsgDriverData:
+			msg = &racestatev1.PublishDriverDataRequest{}
+		default:
+			return ErrInvalidFrame
+		}
+		if err := proto.Unmarshal(data[n+1:], msg); err != nil {
+			return err
+		}
+		m.Seq = seq
+		m.Msg = msg
+		return nil
+	default:
+		return fmt.Errorf("%w: unsupported type %T", ErrInvalidFrame, v)
+	}
+}
+
+func frameMsgType(msg proto.Message) byte {
+	switch msg.(type) {
+	case *racestatev1.PublishStateRequest:
+		return logger.MsgState
+	case *racestatev1.PublishSpeedmapRequest:
+		return logger.MsgSpeedmap
+	case *racestatev1.PublishDriverDataRequest:
+		return logger.MsgDriverData
+	default:
+		return logger.MsgUnknown
+	}
+}
+
+type (
+	// StreamClient publishes state, speedmap and driver data via the publish
+	// stream. All other calls are delegated to the DataProviderClient.
+	StreamClient struct {
+		*DataProviderClient
+		id         string
+		maxUnacked int
+		log        *log.Logger
+
+		sendMu  sync.Mutex
+		mu      sync.Mutex
+		stream  grpc.BidiStreamingClient[StreamFrame, StreamAck]
+		cancel  context.CancelFunc
+		recvErr chan error
+		seq     uint64
+		acked   uint64
+		unacked []StreamFrame
+		dropped int
+	}
+	StreamOption func(*StreamClient)
+)
+
+// WithMaxUnacked limits the number of frames waiting for an ack.
+// If the limit is exceeded the oldest frames are dropped.
+func WithMaxUnacked(n int) StreamOption {
+	return func(c *StreamClient) { c.maxUnacked = n }
+}
+
+func NewStreamClient(dpc *DataProviderClient, opts ...StreamOption) *StreamClient {
+	ret := &StreamClient{
+		DataProviderClient: dpc,
+		id:                 uuid.NewString(),
+		maxUnacked:         1000,
+		log:                log.Default().Named("stream"),
+	}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	return ret
+}
+
+func (c *StreamClient) PublishState(req *racestatev1.PublishStateRequest) error {
+	return c.publish(req)
+}
+
+//nolint:whitespace // can't get different linters happy
+func (c *StreamClient) PublishSpeedmap(
+	req *racestatev1.PublishSpeedmapRequest,
+) error {
+	return c.publish(req)
+}
+
+//nolint:whitespace // can't get different linters happy
+func (c *StreamClient) PublishDriverData(
+	req *racestatev1.PublishDriverDataRequest,
+) error {
+	return c.publish(req)
+}
+
+// Acked returns the sequence number of the last frame persisted by the server
+func (c *StreamClient) Acked() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.acked
+}
+
+// Unacked returns the number of frames not yet acknowledged by the server
+func (c *StreamClient) Unacked() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.unacked)
+}
+
+// Close closes the stream and waits (at most timeout) until all frames are
+// acknowledged. If the stream breaks meanwhile it is reopened.
+func (c *StreamClient) Close(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := c.closeStream(time.Until(deadline))
+		unacked := c.Unacked()
+		if unacked == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			c.log.Warn("Stream closed with unacknowledged frames",
+				log.Int("unacked", unacked))
+			if err == nil {
+				err = context.DeadlineExceeded
+			}
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// closeStream closes the send direction of the stream (opens one if there are
+// unacked frames) and waits until the server has closed the stream.
+func (c *StreamClient) closeStream(timeout time.Duration) error {
+	c.sendMu.Lock()
+	c.mu.Lock()
+	stream, unacked := c.stream, len(c.unacked)
+	c.mu.Unlock()
+	if stream == nil {
+		if unacked == 0 {
+			c.sendMu.Unlock()
+			return nil
+		}
+		if err := c.openStream(); err != nil {
+			c.sendMu.Unlock()
+			return err
+		}
+	}
+	c.mu.Lock()
+	stream, recvErr := c.stream, c.recvErr
+	c.mu.Unlock()
+	if stream == nil {
+		c.sendMu.Unlock()
+		return ErrStreamNotOpen
+	}
+	err := stream.CloseSend()
+	c.sendMu.Unlock()
+	if err != nil {
+		c.discardStream(stream)
+		return err
+	}
+	select {
+	case err := <-recvErr:
+		return err
+	case <-time.After(timeout):
+		c.discardStream(stream)
+		return context.DeadlineExceeded
+	}
+}
+
+// publish sends the message. sendMu serializes the senders, mu protects the
+// ack state. The ack receiver only needs mu, so a blocking Send can't block
+// the processing of acks.
+func (c *StreamClient) publish(msg proto.Message) error {
+	//nolint:errcheck // by design
+	c.msgLogger.Log(msg.ProtoReflect())
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	c.mu.Lock()
+	c.seq++
+	frame := StreamFrame{Seq: c.seq, Msg: msg}
+	c.unacked = append(c.unacked, frame)
+	if len(c.unacked) > c.maxUnacked {
+		drop := len(c.unacked) - c.maxUnacked
+		c.dropped += drop
+		c.unacked = c.unacked[drop:]
+		c.log.Warn("Too many unacknowledged frames. Dropping oldest",
+			log.Int("dropped", c.dropped))
+	}
+	stream := c.stream
+	c.mu.Unlock()
+
+	if stream == nil {
+		// a new stream sends all unacked frames including this one
+		return c.openStream()
+	}
+	if err := stream.Send(&frame); err != nil {
+		c.discardStream(stream)
+		return err
+	}
+	return nil
+}
+
+// openStream opens a new stream and sends the unacked frames.
+// Must be called with c.sendMu held.
+func (c *StreamClient) openStream() error {
+	if c.conn == nil {
+		return ErrStreamNotOpen
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = metadata.NewOutgoingContext(ctx,
+		metadata.Pairs("api-token", c.token, StreamIDKey, c.id))
+	s, err := c.conn.NewStream(ctx, &StreamServiceDesc.Streams[0], StreamMethod,
+		grpc.CallContentSubtype(streamCodecName))
+	if err != nil {
+		cancel()
+		return err
+	}
+	stream := &grpc.GenericClientStream[StreamFrame, StreamAck]{ClientStream: s}
+	recvErr := make(chan error, 1)
+	c.mu.Lock()
+	c.stream = stream
+	c.cancel = cancel
+	c.recvErr = recvErr
+	pending := append([]StreamFrame{}, c.unacked...)
+	c.mu.Unlock()
+
+	go c.receiveAcks(stream, recvErr)
+	for i := range pending {
+		if err := stream.Send(&pending[i]); err != nil {
+			c.discardStream(stream)
+			return err
+		}
+	}
+	return nil
+}
+
+// discardStream cancels the stream (if it is still the current one)
+//
+//nolint:whitespace // can't get different linters happy
+func (c *StreamClient) discardStream(
+	stream grpc.BidiStreamingClient[StreamFrame, StreamAck],
+) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stream != stream {
+		return
+	}
+	c.cancel()
+	c.stream = nil
+	c.cancel = nil
+}
+
+//nolint:whitespace // can't get different linters happy
+func (c *StreamClient) receiveAcks(
+	stream grpc.BidiStreamingClient[StreamFrame, StreamAck],
+	recvErr chan error,
+) {
+	for {
+		ack, err := stream.Recv()
+		if err != nil {
+			c.discardStream(stream)
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			recvErr <- err
+			return
+		}
+		c.mu.Lock()
+		c.acked = max(c.acked, ack.Seq)
+		i := 0
+		for i < len(c.unacked) && c.unacked[i].Seq <= ack.Seq {
+			i++
+		}
+		c.unacked = c.unacked[i:]
+		c.mu.Unlock()
+	}
+}
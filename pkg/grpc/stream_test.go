@@ -0,0 +1,90 @@
+package grpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	owngrpc "github.com/mpapenbr/go-racelogger/pkg/grpc"
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/standin"
+)
+
+func startStandin(t *testing.T, srv *standin.Server) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	gs := grpc.NewServer()
+	srv.Register(gs)
+	go func() { _ = gs.Serve(lis) }()
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///standin",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func state(key string) *racestatev1.PublishStateRequest {
+	return &racestatev1.PublishStateRequest{
+		Event: &commonv1.EventSelector{Arg: &commonv1.EventSelector_Key{Key: key}},
+	}
+}
+
+func receivedKeys(srv *standin.Server) []string {
+	ret := []string{}
+	for _, msg := range srv.Received() {
+		if req, ok := msg.(*racestatev1.PublishStateRequest); ok {
+			ret = append(ret, req.GetEvent().GetKey())
+		}
+	}
+	return ret
+}
+
+func TestStreamClient_Publish(t *testing.T) {
+	srv := standin.New(standin.WithToken("secret"))
+	conn := startStandin(t, srv)
+	c := owngrpc.NewStreamClient(owngrpc.NewDataProviderClient(
+		owngrpc.WithConnection(conn), owngrpc.WithToken("secret")))
+
+	require.NoError(t, c.PublishState(state("1")))
+	require.NoError(t, c.PublishSpeedmap(&racestatev1.PublishSpeedmapRequest{}))
+	require.NoError(t, c.PublishDriverData(&racestatev1.PublishDriverDataRequest{}))
+	require.NoError(t, c.PublishState(state("2")))
+	require.NoError(t, c.Close(5*time.Second))
+
+	assert.Equal(t, uint64(4), c.Acked())
+	assert.Equal(t, 0, c.Unacked())
+	assert.Len(t, srv.Received(), 4)
+	assert.Equal(t, []string{"1", "2"}, receivedKeys(srv))
+	assert.Equal(t, 1, srv.Streams())
+}
+
+func TestStreamClient_Resend(t *testing.T) {
+	// the stream breaks on frame 3, no acks before close
+	srv := standin.New(standin.WithBreakAt(3), standin.WithAckEvery(100))
+	conn := startStandin(t, srv)
+	c := owngrpc.NewStreamClient(owngrpc.NewDataProviderClient(
+		owngrpc.WithConnection(conn)))
+
+	for _, key := range []string{"1", "2", "3", "4", "5"} {
+		// errors are expected while the stream is broken
+		_ = c.PublishState(state(key))
+	}
+	require.NoError(t, c.Close(5*time.Second))
+
+	assert.Equal(t, uint64(5), c.Acked())
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, receivedKeys(srv))
+	assert.GreaterOrEqual(t, srv.Streams(), 2)
+}
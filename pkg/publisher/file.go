@@ -0,0 +1,141 @@
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	commonv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/common/v1"
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+// messageWriter is implemented by publishers which just write the messages
+type messageWriter interface {
+	write(msg proto.Message) error
+}
+
+// writerPublisher implements the Publisher interface for a messageWriter
+type writerPublisher struct {
+	w messageWriter
+}
+
+//nolint:whitespace // can't get different linters happy
+func (p writerPublisher) RegisterProvider(
+	event *eventv1.Event,
+	track *trackv1.Track,
+	recordingMode providerv1.RecordingMode,
+) (*providerv1.RegisterEventResponse, error) {
+	req := providerv1.RegisterEventRequest{
+		Event: event, Track: track, Key: event.Key, RecordingMode: recordingMode,
+	}
+	return &providerv1.RegisterEventResponse{Track: track}, p.w.write(&req)
+}
+
+func (p writerPublisher) UnregisterProvider(eventKey string) error {
+	req := providerv1.UnregisterEventRequest{
+		EventSelector: &commonv1.EventSelector{Arg: &commonv1.EventSelector_Key{
+			Key: eventKey,
+		}},
+	}
+	return p.w.write(&req)
+}
+
+func (p writerPublisher) PublishState(req *racestatev1.PublishStateRequest) error {
+	return p.w.write(req)
+}
+
+//nolint:whitespace // can't get different linters happy
+func (p writerPublisher) PublishDriverData(
+	req *racestatev1.PublishDriverDataRequest,
+) error {
+	return p.w.write(req)
+}
+
+//nolint:whitespace // can't get different linters happy
+func (p writerPublisher) PublishSpeedmap(
+	req *racestatev1.PublishSpeedmapRequest,
+) error {
+	return p.w.write(req)
+}
+
+//nolint:whitespace // can't get different linters happy
+func (p writerPublisher) PublishEventExtraInfo(
+	req *racestatev1.PublishEventExtraInfoRequest,
+) error {
+	return p.w.write(req)
+}
+
+type msgLogWriter struct {
+	m *logger.MsgLogger
+}
+
+func (w msgLogWriter) write(msg proto.Message) error {
+	return w.m.Log(msg.ProtoReflect())
+}
+
+// NewMsgLog creates a publisher writing the messages to a msg log file.
+// The file can be imported later by the import command.
+// Additional logger options (e.g. logger.WithStateDelta) may be passed.
+func NewMsgLog(w io.Writer, opts ...logger.Option) Publisher {
+	opts = append(opts, logger.WithWriter(w))
+	return writerPublisher{w: msgLogWriter{m: logger.NewMsgLogger(opts...)}}
+}
+
+// jsonWriter writes each message as a single line of JSON.
+// Format: {"type":"<message name>","data":<message>}
+type jsonWriter struct {
+	w  io.Writer
+	mu *sync.Mutex
+}
+
+func (w jsonWriter) write(msg proto.Message) error {
+	b, err := protojson.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = fmt.Fprintf(w.w, "{\"type\":%q,\"data\":%s}\n",
+		msg.ProtoReflect().Descriptor().Name(), b)
+	return err
+}
+
+func (w jsonWriter) writeLocal(msg *LocalMessage) error {
+	b, err := json.Marshal(msg.Data)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = fmt.Fprintf(w.w, "{\"type\":%q,\"data\":%s}\n", msg.Type, b)
+	return err
+}
+
+// jsonPublisher also writes the local messages
+type jsonPublisher struct {
+	writerPublisher
+	w jsonWriter
+}
+
+var _ LocalPublisher = jsonPublisher{}
+
+func (p jsonPublisher) PublishLocal(msg *LocalMessage) error {
+	return p.w.writeLocal(msg)
+}
+
+// NewJSON creates a publisher writing each message as a single line of JSON.
+// Format: {"type":"<message name>","data":<message>}
+// Local messages (see LocalMessage) are written the same way.
+// This is intended for local consumers like overlays.
+func NewJSON(w io.Writer) Publisher {
+	jw := jsonWriter{w: w, mu: &sync.Mutex{}}
+	return jsonPublisher{writerPublisher: writerPublisher{w: jw}, w: jw}
+}
@@ -0,0 +1,79 @@
+package publisher
+
+import (
+	"sync"
+
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+
+	"github.com/mpapenbr/go-racelogger/log"
+)
+
+// Channels are the output channels of the processor
+type Channels struct {
+	State      chan *racestatev1.PublishStateRequest
+	Speedmap   chan *racestatev1.PublishSpeedmapRequest
+	DriverData chan *racestatev1.PublishDriverDataRequest
+	ExtraInfo  chan *racestatev1.PublishEventExtraInfoRequest
+	Local      chan *LocalMessage // only for publishers implementing LocalPublisher
+}
+
+// Forward publishes the messages received on the channels.
+// Each channel is handled by its own goroutine which ends when the channel
+// is closed. The returned channel is closed once all goroutines are done.
+func Forward(p Publisher, ch Channels) <-chan struct{} {
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	start := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	start(func() { forward(ch.State, "state", p.PublishState) })
+	start(func() { forward(ch.Speedmap, "speedmap", p.PublishSpeedmap) })
+	start(func() { forward(ch.DriverData, "driver data", p.PublishDriverData) })
+	start(func() { forward(ch.ExtraInfo, "extra info", p.PublishEventExtraInfo) })
+	if lp, ok := p.(LocalPublisher); ok {
+		start(func() { forward(ch.Local, "local", lp.PublishLocal) })
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// forward publishes the messages of rcv until the channel is closed.
+func forward[T any](rcv chan T, name string, publish func(T) error) {
+	if rcv == nil {
+		return
+	}
+	forwardFrom(func() (T, bool) {
+		msg, ok := <-rcv
+		return msg, ok
+	}, name, publish)
+}
+
+// forwardFrom publishes the messages returned by next until next returns false.
+// Consecutive errors are only logged every 30th time.
+func forwardFrom[T any](next func() (T, bool), name string, publish func(T) error) {
+	errorCounter := 0
+	for msg, ok := next(); ok; msg, ok = next() {
+		if err := publish(msg); err != nil {
+			if errorCounter%30 == 0 {
+				log.Error("Error publishing "+name,
+					log.Int("errorCounter", errorCounter+1),
+					log.ErrorField(err))
+			}
+			errorCounter++
+			continue
+		}
+		if errorCounter > 0 {
+			log.Info("Published "+name+" successful again",
+				log.Int("errorCounter", errorCounter))
+		}
+		errorCounter = 0
+	}
+	log.Debug("closed channel signaled", log.String("channel", name))
+}
@@ -0,0 +1,220 @@
+package publisher
+
+import (
+	"expvar"
+	"sync"
+
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+
+	"github.com/mpapenbr/go-racelogger/log"
+)
+
+// maxPending is the maximum number of pending extra info or local messages.
+// If the limit is reached the oldest message is dropped.
+const maxPending = 1000
+
+// metrics about the overflow decisions of ForwardDecoupled (see /debug/vars)
+var (
+	stateCoalesced   = expvar.NewInt("publisher_state_coalesced")
+	speedmapReplaced = expvar.NewInt("publisher_speedmap_replaced")
+	extraInfoDropped = expvar.NewInt("publisher_extra_info_dropped")
+	localDropped     = expvar.NewInt("publisher_local_dropped")
+)
+
+// ForwardDecoupled publishes the messages received on the channels to each
+// publisher like Forward. The channels are read immediately into a mailbox per
+// publisher and message type, so the sender is never blocked by a slow
+// publisher and a slow publisher does not affect the others.
+// If a publisher can't keep up
+//   - state messages are coalesced (latest snapshot, race messages are kept)
+//   - only the latest speedmap is kept
+//   - driver data is never dropped (the mailbox is unbounded)
+//   - extra info and local messages are kept up to maxPending messages
+//
+// The returned channel is closed once all channels are closed and the pending
+// messages are published.
+func ForwardDecoupled(ch Channels, pubs ...Publisher) <-chan struct{} {
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	start := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	state := make([]*mailbox[*racestatev1.PublishStateRequest], len(pubs))
+	speedmap := make([]*mailbox[*racestatev1.PublishSpeedmapRequest], len(pubs))
+	driverData := make([]*mailbox[*racestatev1.PublishDriverDataRequest], len(pubs))
+	extraInfo := make([]*mailbox[*racestatev1.PublishEventExtraInfoRequest], len(pubs))
+	local := make([]*mailbox[*LocalMessage], 0, len(pubs))
+	for i, p := range pubs {
+		state[i] = newMailbox(coalesceState)
+		speedmap[i] = newMailbox(keepLatest[*racestatev1.PublishSpeedmapRequest](
+			speedmapReplaced))
+		driverData[i] = newMailbox[*racestatev1.PublishDriverDataRequest](nil)
+		extraInfo[i] = newMailbox(keepAll[*racestatev1.PublishEventExtraInfoRequest](
+			maxPending, extraInfoDropped))
+
+		start(func() { forwardFrom(state[i].take, "state", p.PublishState) })
+		start(func() { forwardFrom(speedmap[i].take, "speedmap", p.PublishSpeedmap) })
+		start(func() {
+			forwardFrom(driverData[i].take, "driver data", p.PublishDriverData)
+		})
+		start(func() {
+			forwardFrom(extraInfo[i].take, "extra info", p.PublishEventExtraInfo)
+		})
+		if lp, ok := p.(LocalPublisher); ok {
+			box := newMailbox(keepAll[*LocalMessage](maxPending, localDropped))
+			local = append(local, box)
+			start(func() { forwardFrom(box.take, "local", lp.PublishLocal) })
+		}
+	}
+	start(func() { relay(ch.State, state) })
+	start(func() { relay(ch.Speedmap, speedmap) })
+	start(func() { relay(ch.DriverData, driverData) })
+	start(func() { relay(ch.ExtraInfo, extraInfo) })
+	start(func() { relay(ch.Local, local) })
+	go func() {
+		wg.Wait()
+		log.Debug("Publisher overflow",
+			log.Int64("stateCoalesced", stateCoalesced.Value()),
+			log.Int64("speedmapReplaced", speedmapReplaced.Value()),
+			log.Int64("extraInfoDropped", extraInfoDropped.Value()),
+			log.Int64("localDropped", localDropped.Value()))
+		close(done)
+	}()
+	return done
+}
+
+// relay moves the messages of rcv into the mailboxes until rcv is closed
+func relay[T any](rcv chan T, boxes []*mailbox[T]) {
+	defer func() {
+		for _, m := range boxes {
+			m.close()
+		}
+	}()
+	if rcv == nil {
+		return
+	}
+	for msg := range rcv {
+		for _, m := range boxes {
+			m.put(msg)
+		}
+	}
+}
+
+type (
+	// mergeFunc is called with the pending messages when a new message arrives.
+	// It returns the new list of pending messages.
+	mergeFunc[T any] func(pending []T, msg T) []T
+
+	// mailbox holds the messages which are not yet published.
+	mailbox[T any] struct {
+		mu      sync.Mutex
+		pending []T
+		merge   mergeFunc[T]
+		notify  chan struct{}
+		closed  bool
+	}
+)
+
+// newMailbox creates a mailbox. If merge is nil all messages are kept.
+func newMailbox[T any](merge mergeFunc[T]) *mailbox[T] {
+	if merge == nil {
+		merge = func(pending []T, msg T) []T { return append(pending, msg) }
+	}
+	return &mailbox[T]{merge: merge, notify: make(chan struct{}, 1)}
+}
+
+func (m *mailbox[T]) put(msg T) {
+	m.mu.Lock()
+	m.pending = m.merge(m.pending, msg)
+	m.mu.Unlock()
+	m.signal()
+}
+
+func (m *mailbox[T]) close() {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	m.signal()
+}
+
+func (m *mailbox[T]) signal() {
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the oldest pending message. It blocks until a message is available.
+// Returns false if the mailbox is closed and empty.
+func (m *mailbox[T]) take() (msg T, ok bool) {
+	for {
+		m.mu.Lock()
+		if len(m.pending) > 0 {
+			msg = m.pending[0]
+			m.pending = m.pending[1:]
+			m.mu.Unlock()
+			return msg, true
+		}
+		closed := m.closed
+		m.mu.Unlock()
+		if closed {
+			return msg, false
+		}
+		<-m.notify
+	}
+}
+
+// keepAll keeps all messages up to limit pending ones.
+// If the limit is reached the oldest message is dropped.
+func keepAll[T any](limit int, counter *expvar.Int) mergeFunc[T] {
+	return func(pending []T, msg T) []T {
+		if len(pending) >= limit {
+			counter.Add(int64(len(pending) - limit + 1))
+			pending = pending[len(pending)-limit+1:]
+		}
+		return append(pending, msg)
+	}
+}
+
+// keepLatest replaces a pending message with the new one
+func keepLatest[T any](counter *expvar.Int) mergeFunc[T] {
+	return func(pending []T, msg T) []T {
+		if len(pending) > 0 {
+			counter.Add(int64(len(pending)))
+		}
+		return append(pending[:0], msg)
+	}
+}
+
+// coalesceState replaces a pending state message with the new one.
+// Cars and session are snapshots, so the latest values are sufficient.
+// The race messages of the replaced state are added to a copy of the new one.
+// The messages are shared by the mailboxes of all publishers, so they must not
+// be modified here.
+//
+//nolint:whitespace // can't get different linters happy
+func coalesceState(
+	pending []*racestatev1.PublishStateRequest,
+	msg *racestatev1.PublishStateRequest,
+) []*racestatev1.PublishStateRequest {
+	if len(pending) == 0 {
+		return append(pending, msg)
+	}
+	stateCoalesced.Add(int64(len(pending)))
+	messages := []*racestatev1.Message{}
+	for _, p := range pending {
+		messages = append(messages, p.Messages...)
+	}
+	merged := &racestatev1.PublishStateRequest{
+		Event:     msg.Event,
+		Session:   msg.Session,
+		Cars:      msg.Cars,
+		Timestamp: msg.Timestamp,
+		Messages:  append(messages, msg.Messages...),
+	}
+	return append(pending[:0], merged)
+}
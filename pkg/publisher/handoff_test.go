@@ -0,0 +1,202 @@
+package publisher
+
+import (
+	"bytes"
+	"expvar"
+	"sync"
+	"testing"
+	"time"
+
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func takeAll[T any](m *mailbox[T]) []T {
+	m.close()
+	ret := []T{}
+	for msg, ok := m.take(); ok; msg, ok = m.take() {
+		ret = append(ret, msg)
+	}
+	return ret
+}
+
+func TestMailbox_KeepAll(t *testing.T) {
+	m := newMailbox[int](nil)
+	for i := range 5 {
+		m.put(i)
+	}
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, takeAll(m))
+}
+
+func TestMailbox_KeepAllLimit(t *testing.T) {
+	counter := new(expvar.Int)
+	m := newMailbox(keepAll[int](3, counter))
+	for i := range 5 {
+		m.put(i)
+	}
+	assert.Equal(t, []int{2, 3, 4}, takeAll(m))
+	assert.Equal(t, int64(2), counter.Value())
+}
+
+func TestMailbox_KeepLatest(t *testing.T) {
+	counter := new(expvar.Int)
+	m := newMailbox(keepLatest[int](counter))
+	for i := range 5 {
+		m.put(i)
+	}
+	assert.Equal(t, []int{4}, takeAll(m))
+	assert.Equal(t, int64(4), counter.Value())
+}
+
+func stateWithMessages(msgs ...string) *racestatev1.PublishStateRequest {
+	ret := &racestatev1.PublishStateRequest{}
+	for _, msg := range msgs {
+		ret.Messages = append(ret.Messages, &racestatev1.Message{Msg: msg})
+	}
+	return ret
+}
+
+func messageTexts(req *racestatev1.PublishStateRequest) []string {
+	ret := []string{}
+	for _, m := range req.Messages {
+		ret = append(ret, m.Msg)
+	}
+	return ret
+}
+
+func TestMailbox_CoalesceState(t *testing.T) {
+	before := stateCoalesced.Value()
+	m := newMailbox(coalesceState)
+	m.put(stateWithMessages("a"))
+	m.put(stateWithMessages())
+	latest := stateWithMessages("b", "c")
+	m.put(latest)
+
+	got := takeAll(m)
+	require.Len(t, got, 1)
+	assert.Equal(t, []string{"a", "b", "c"}, messageTexts(got[0]))
+	assert.Equal(t, []string{"b", "c"}, messageTexts(latest),
+		"shared message must not be modified")
+	assert.Equal(t, int64(2), stateCoalesced.Value()-before)
+}
+
+// blockingPublisher blocks publishing state messages until release is closed
+type blockingPublisher struct {
+	writerPublisher
+	release    chan struct{}
+	mu         sync.Mutex
+	states     []*racestatev1.PublishStateRequest
+	driverData int
+}
+
+func (p *blockingPublisher) PublishState(req *racestatev1.PublishStateRequest) error {
+	<-p.release
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.states = append(p.states, req)
+	return nil
+}
+
+//nolint:whitespace // can't get different linters happy
+func (p *blockingPublisher) PublishDriverData(
+	req *racestatev1.PublishDriverDataRequest,
+) error {
+	<-p.release
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.driverData++
+	return nil
+}
+
+// collectingPublisher collects the state messages
+type collectingPublisher struct {
+	writerPublisher
+	mu     sync.Mutex
+	states []*racestatev1.PublishStateRequest
+}
+
+func (p *collectingPublisher) PublishState(req *racestatev1.PublishStateRequest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.states = append(p.states, req)
+	return nil
+}
+
+func (p *collectingPublisher) messageTexts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ret := []string{}
+	for _, s := range p.states {
+		ret = append(ret, messageTexts(s)...)
+	}
+	return ret
+}
+
+func TestForwardDecoupled(t *testing.T) {
+	p := &blockingPublisher{
+		writerPublisher: writerPublisher{w: discardWriter{}},
+		release:         make(chan struct{}),
+	}
+	ch := Channels{
+		State:      make(chan *racestatev1.PublishStateRequest),
+		Speedmap:   make(chan *racestatev1.PublishSpeedmapRequest),
+		DriverData: make(chan *racestatev1.PublishDriverDataRequest),
+		ExtraInfo:  make(chan *racestatev1.PublishEventExtraInfoRequest),
+	}
+	fast := &collectingPublisher{writerPublisher: writerPublisher{w: discardWriter{}}}
+	done := ForwardDecoupled(ch, p, fast)
+
+	// the sender must not be blocked by the publisher
+	numDriverData := maxPending + 10
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for i := range 10 {
+			ch.State <- stateWithMessages(string(rune('a' + i)))
+		}
+		for range numDriverData {
+			ch.DriverData <- &racestatev1.PublishDriverDataRequest{}
+		}
+	}()
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sender was blocked by publisher")
+	}
+
+	// the blocked publisher must not affect the others
+	assert.Eventually(t, func() bool {
+		return len(fast.messageTexts()) == 10
+	}, 5*time.Second, 10*time.Millisecond, "other publishers must not be blocked")
+
+	close(p.release)
+	close(ch.State)
+	close(ch.Speedmap)
+	close(ch.DriverData)
+	close(ch.ExtraInfo)
+	<-done
+
+	assert.Equal(t, numDriverData, p.driverData, "driver data must not be dropped")
+	require.NotEmpty(t, p.states)
+	all := []string{}
+	for _, s := range p.states {
+		all = append(all, messageTexts(s)...)
+	}
+	assert.Equal(t,
+		[]string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}, all,
+		"race messages must survive coalescing")
+}
+
+func TestForwardDecoupled_Local(t *testing.T) {
+	var buf bytes.Buffer
+	ch := Channels{Local: make(chan *LocalMessage)}
+	done := ForwardDecoupled(ch, &collectingPublisher{
+		writerPublisher: writerPublisher{w: discardWriter{}},
+	}, NewJSON(&buf))
+	ch.Local <- &LocalMessage{Type: LocalLapChart, Data: 1}
+	close(ch.Local)
+	<-done
+	assert.Equal(t, `{"type":"LapChart","data":1}`+"\n", buf.String(),
+		"only publishers implementing LocalPublisher get local messages")
+}
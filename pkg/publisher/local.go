@@ -0,0 +1,22 @@
+package publisher
+
+// Types of the local messages
+const (
+	LocalLapChart = "LapChart" // new lap chart rows ([]processor.LapChartEntry)
+	LocalTiming   = "Timing"   // gaps and intervals of all cars ([]processor.Timing)
+)
+
+type (
+	// LocalMessage is a message without a counterpart in the backend API.
+	// It is passed along with the extra info messages and published by the
+	// publishers implementing LocalPublisher (the json sink).
+	LocalMessage struct {
+		Type string
+		Data any // marshaled as JSON
+	}
+
+	// LocalPublisher is implemented by publishers which accept local messages
+	LocalPublisher interface {
+		PublishLocal(msg *LocalMessage) error
+	}
+)
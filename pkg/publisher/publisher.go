@@ -0,0 +1,130 @@
+// Package publisher defines the sinks receiving the data of a recording.
+// The gRPC client for the backend server is one implementation, others write
+// the data to local files. Several publishers can be combined by Multi.
+package publisher
+
+import (
+	"errors"
+
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
+
+	"github.com/mpapenbr/go-racelogger/log"
+	owngrpc "github.com/mpapenbr/go-racelogger/pkg/grpc"
+)
+
+// Publisher receives the data of a recording
+type Publisher interface {
+	// RegisterProvider is called once before any data is published.
+	// The track of the response is used for processing
+	// (the backend may provide additional data like pit info)
+	RegisterProvider(
+		event *eventv1.Event,
+		track *trackv1.Track,
+		recordingMode providerv1.RecordingMode,
+	) (*providerv1.RegisterEventResponse, error)
+	UnregisterProvider(eventKey string) error
+	PublishState(req *racestatev1.PublishStateRequest) error
+	PublishDriverData(req *racestatev1.PublishDriverDataRequest) error
+	PublishSpeedmap(req *racestatev1.PublishSpeedmapRequest) error
+	PublishEventExtraInfo(req *racestatev1.PublishEventExtraInfoRequest) error
+}
+
+var _ Publisher = (*owngrpc.DataProviderClient)(nil)
+
+// Multi sends the data to several publishers.
+// The first publisher is the primary one. Its response to RegisterProvider
+// is used and its errors are fatal for the registration.
+// A failing publisher does not prevent the others from receiving the data.
+type Multi struct {
+	primary Publisher
+	others  []Publisher
+	log     *log.Logger
+}
+
+var (
+	_ Publisher      = (*Multi)(nil)
+	_ LocalPublisher = (*Multi)(nil)
+)
+
+func NewMulti(primary Publisher, others ...Publisher) *Multi {
+	return &Multi{
+		primary: primary,
+		others:  others,
+		log:     log.Default().Named("publisher"),
+	}
+}
+
+// Combine returns p if there are no others, otherwise a Multi publisher
+func Combine(p Publisher, others ...Publisher) Publisher {
+	if len(others) == 0 {
+		return p
+	}
+	return NewMulti(p, others...)
+}
+
+//nolint:whitespace // can't get different linters happy
+func (m *Multi) RegisterProvider(
+	event *eventv1.Event,
+	track *trackv1.Track,
+	recordingMode providerv1.RecordingMode,
+) (*providerv1.RegisterEventResponse, error) {
+	resp, err := m.primary.RegisterProvider(event, track, recordingMode)
+	if err != nil {
+		return nil, err
+	}
+	// the others should get the same track data the recording is working with
+	if resp.GetTrack() != nil {
+		track = resp.GetTrack()
+	}
+	for _, p := range m.others {
+		if _, err := p.RegisterProvider(event, track, recordingMode); err != nil {
+			m.log.Warn("Could not register event", log.ErrorField(err))
+		}
+	}
+	return resp, nil
+}
+
+func (m *Multi) UnregisterProvider(eventKey string) error {
+	return m.each(func(p Publisher) error { return p.UnregisterProvider(eventKey) })
+}
+
+func (m *Multi) PublishState(req *racestatev1.PublishStateRequest) error {
+	return m.each(func(p Publisher) error { return p.PublishState(req) })
+}
+
+func (m *Multi) PublishDriverData(req *racestatev1.PublishDriverDataRequest) error {
+	return m.each(func(p Publisher) error { return p.PublishDriverData(req) })
+}
+
+func (m *Multi) PublishSpeedmap(req *racestatev1.PublishSpeedmapRequest) error {
+	return m.each(func(p Publisher) error { return p.PublishSpeedmap(req) })
+}
+
+//nolint:whitespace // can't get different linters happy
+func (m *Multi) PublishEventExtraInfo(
+	req *racestatev1.PublishEventExtraInfoRequest,
+) error {
+	return m.each(func(p Publisher) error { return p.PublishEventExtraInfo(req) })
+}
+
+// PublishLocal passes msg to the publishers implementing LocalPublisher
+func (m *Multi) PublishLocal(msg *LocalMessage) error {
+	return m.each(func(p Publisher) error {
+		if lp, ok := p.(LocalPublisher); ok {
+			return lp.PublishLocal(msg)
+		}
+		return nil
+	})
+}
+
+// each calls f for every publisher and returns the joined errors
+func (m *Multi) each(f func(p Publisher) error) error {
+	errs := []error{f(m.primary)}
+	for _, p := range m.others {
+		errs = append(errs, f(p))
+	}
+	return errors.Join(errs...)
+}
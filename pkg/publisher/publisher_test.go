@@ -0,0 +1,119 @@
+package publisher
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	eventv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/event/v1"
+	providerv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/provider/v1"
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	trackv1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/track/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+var errPublish = errors.New("publish failed")
+
+// recordingPublisher records the calls and returns err (if set)
+type recordingPublisher struct {
+	writerPublisher
+	calls []string
+	track *trackv1.Track // returned on register
+	err   error
+}
+
+type discardWriter struct{}
+
+func (discardWriter) write(msg proto.Message) error { return nil }
+
+func newRecordingPublisher(err error) *recordingPublisher {
+	return &recordingPublisher{
+		writerPublisher: writerPublisher{w: discardWriter{}},
+		err:             err,
+	}
+}
+
+//nolint:whitespace // can't get different linters happy
+func (p *recordingPublisher) RegisterProvider(
+	event *eventv1.Event,
+	track *trackv1.Track,
+	recordingMode providerv1.RecordingMode,
+) (*providerv1.RegisterEventResponse, error) {
+	p.calls = append(p.calls, "register:"+track.GetName())
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.track != nil {
+		track = p.track
+	}
+	return &providerv1.RegisterEventResponse{Track: track}, nil
+}
+
+func (p *recordingPublisher) PublishState(req *racestatev1.PublishStateRequest) error {
+	p.calls = append(p.calls, "state")
+	return p.err
+}
+
+func TestMulti_Register(t *testing.T) {
+	primary := newRecordingPublisher(nil)
+	primary.track = &trackv1.Track{Name: "from backend"}
+	other := newRecordingPublisher(errPublish)
+	m := NewMulti(primary, other)
+
+	resp, err := m.RegisterProvider(&eventv1.Event{}, &trackv1.Track{Name: "local"},
+		providerv1.RecordingMode_RECORDING_MODE_PERSIST)
+	require.NoError(t, err, "errors of other publishers are not fatal")
+	assert.Equal(t, "from backend", resp.GetTrack().GetName())
+	assert.Equal(t, []string{"register:local"}, primary.calls)
+	assert.Equal(t, []string{"register:from backend"}, other.calls)
+
+	_, err = NewMulti(other, primary).RegisterProvider(&eventv1.Event{},
+		&trackv1.Track{}, providerv1.RecordingMode_RECORDING_MODE_PERSIST)
+	assert.ErrorIs(t, err, errPublish)
+}
+
+func TestMulti_Publish(t *testing.T) {
+	failing := newRecordingPublisher(errPublish)
+	ok := newRecordingPublisher(nil)
+	m := NewMulti(failing, ok)
+
+	err := m.PublishState(&racestatev1.PublishStateRequest{})
+	assert.ErrorIs(t, err, errPublish)
+	assert.Equal(t, []string{"state"}, failing.calls)
+	assert.Equal(t, []string{"state"}, ok.calls, "all publishers get the data")
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewJSON(&buf)
+	require.NoError(t, p.PublishState(&racestatev1.PublishStateRequest{}))
+	require.NoError(t, p.UnregisterProvider("abc"))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], `{"type":"PublishStateRequest"`))
+	assert.True(t, strings.HasPrefix(lines[1], `{"type":"UnregisterEventRequest"`))
+}
+
+func TestJSON_Local(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewJSON(&buf)
+	lp, ok := p.(LocalPublisher)
+	require.True(t, ok, "json publisher must accept local messages")
+	require.NoError(t, lp.PublishLocal(&LocalMessage{
+		Type: LocalLapChart,
+		Data: []map[string]int{{"lap": 1}},
+	}))
+	assert.Equal(t, `{"type":"LapChart","data":[{"lap":1}]}`+"\n", buf.String())
+}
+
+func TestOpenSink_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "msglog", "json:", "xml:out.xml"} {
+		_, _, err := OpenSink(spec, logger.FileConfig{})
+		assert.ErrorIs(t, err, ErrInvalidSink, spec)
+	}
+}
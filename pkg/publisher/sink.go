@@ -0,0 +1,85 @@
+package publisher
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mpapenbr/go-racelogger/pkg/grpc/logger"
+)
+
+const (
+	SinkMsgLog = "msglog"
+	SinkJSON   = "json"
+)
+
+var ErrInvalidSink = errors.New("invalid sink")
+
+// OpenSink creates a file based publisher from a spec of the form <type>:<file>.
+// Supported types are msglog and json. For json the file "-" means stdout.
+// The fileCfg (compression, rotation) and the msgLogOpts are used for msglog sinks.
+// The returned closer has to be called when the publisher is no longer needed.
+//
+//nolint:whitespace // can't get different linters happy
+func OpenSink(
+	spec string,
+	fileCfg logger.FileConfig,
+	msgLogOpts ...logger.Option,
+) (Publisher, io.Closer, error) {
+	kind, fn, ok := strings.Cut(spec, ":")
+	if !ok || fn == "" {
+		return nil, nil, fmt.Errorf("%w: %q (expected <type>:<file>)", ErrInvalidSink, spec)
+	}
+	switch kind {
+	case SinkMsgLog:
+		f, err := logger.CreateFile(fn, fileCfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewMsgLog(f, msgLogOpts...), f, nil
+	case SinkJSON:
+		if fn == "-" {
+			return NewJSON(os.Stdout), io.NopCloser(os.Stdout), nil
+		}
+		f, err := os.Create(fn)
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewJSON(f), f, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown type %q", ErrInvalidSink, kind)
+	}
+}
+
+// OpenSinks opens all sinks. On error the already opened sinks are closed.
+//
+//nolint:whitespace // can't get different linters happy
+func OpenSinks(
+	specs []string,
+	fileCfg logger.FileConfig,
+	msgLogOpts ...logger.Option,
+) ([]Publisher, []io.Closer, error) {
+	pubs := make([]Publisher, 0, len(specs))
+	closers := make([]io.Closer, 0, len(specs))
+	for _, spec := range specs {
+		p, c, err := OpenSink(spec, fileCfg, msgLogOpts...)
+		if err != nil {
+			CloseAll(closers)
+			return nil, nil, err
+		}
+		pubs = append(pubs, p)
+		closers = append(closers, c)
+	}
+	return pubs, closers, nil
+}
+
+// CloseAll closes all closers and returns the joined errors
+func CloseAll(closers []io.Closer) error {
+	errs := make([]error, 0, len(closers))
+	for _, c := range closers {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mpapenbr/go-racelogger/internal/processor"
+	"github.com/mpapenbr/go-racelogger/log"
+)
+
+type (
+	liveBattles struct {
+		Battles []processor.Battle `json:"battles"`
+	}
+	liveLapChart struct {
+		Laps []processor.LapChartEntry `json:"laps"`
+	}
+	liveTiming struct {
+		Cars []processor.Timing `json:"cars"`
+	}
+)
+
+// setBattles is called by the processor with the current battles
+func (s *serverImpl) setBattles(battles []processor.Battle) {
+	s.liveMu.Lock()
+	defer s.liveMu.Unlock()
+	s.battles = battles
+}
+
+// maxLiveLaps is the maximum number of lap chart rows kept for /live/laps.
+// If the limit is reached the oldest rows are dropped.
+const maxLiveLaps = 10000
+
+// addLaps is called by the processor with the lap completions since the last call
+func (s *serverImpl) addLaps(laps []processor.LapChartEntry) {
+	s.liveMu.Lock()
+	defer s.liveMu.Unlock()
+	s.lapChart = append(s.lapChart, laps...)
+	if over := len(s.lapChart) - maxLiveLaps; over > 0 {
+		s.lapChart = append(s.lapChart[:0:0], s.lapChart[over:]...)
+	}
+}
+
+func (s *serverImpl) clearLapChart() {
+	s.liveMu.Lock()
+	defer s.liveMu.Unlock()
+	s.lapChart = nil
+}
+
+// setTiming is called by the processor with the gaps and intervals of all cars
+func (s *serverImpl) setTiming(timing []processor.Timing) {
+	s.liveMu.Lock()
+	defer s.liveMu.Unlock()
+	s.timing = timing
+}
+
+// handleBattles returns the current battles of the ongoing recording as JSON
+func (s *serverImpl) handleBattles(w http.ResponseWriter, _ *http.Request) {
+	s.liveMu.Lock()
+	ret := liveBattles{Battles: s.battles}
+	s.liveMu.Unlock()
+	if ret.Battles == nil {
+		ret.Battles = []processor.Battle{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ret); err != nil {
+		s.l.Warn("Could not send battles", log.ErrorField(err))
+	}
+}
+
+// handleLapChart returns the lap chart of the ongoing recording as JSON
+func (s *serverImpl) handleLapChart(w http.ResponseWriter, _ *http.Request) {
+	s.liveMu.Lock()
+	ret := liveLapChart{Laps: s.lapChart}
+	s.liveMu.Unlock()
+	if ret.Laps == nil {
+		ret.Laps = []processor.LapChartEntry{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ret); err != nil {
+		s.l.Warn("Could not send lap chart", log.ErrorField(err))
+	}
+}
+
+// handleTiming returns the overall and class based gaps and intervals of the
+// ongoing recording as JSON
+func (s *serverImpl) handleTiming(w http.ResponseWriter, _ *http.Request) {
+	s.liveMu.Lock()
+	ret := liveTiming{Cars: s.timing}
+	s.liveMu.Unlock()
+	if ret.Cars == nil {
+		ret.Cars = []processor.Timing{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ret); err != nil {
+		s.l.Warn("Could not send timing", log.ErrorField(err))
+	}
+}
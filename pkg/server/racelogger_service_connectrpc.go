@@ -2,14 +2,24 @@ package server
 
 import (
 	"context"
+	"time"
 
 	pb "buf.build/gen/go/mpapenbr/iracelog/connectrpc/go/racelogger/v1/raceloggerv1connect"
 	v1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/racelogger/v1"
 	"connectrpc.com/connect"
+	"google.golang.org/protobuf/encoding/protowire"
 
 	"github.com/mpapenbr/go-racelogger/log"
 )
 
+// Field numbers of the backlog in GetStatusStreamResponse.
+// The racelogger API has no fields for the backlog yet, so they are sent as
+// unknown fields. Clients using the JSON encoding don't get them.
+const (
+	backlogDepthField     protowire.Number = 9  // messages in the retry queue
+	backlogOldestAgeField protowire.Number = 10 // seconds
+)
+
 type (
 	raceloggerServiceConnectRPC struct {
 		pb.UnimplementedRaceloggerServiceHandler
@@ -56,7 +66,7 @@ func (s *raceloggerServiceConnectRPC) GetStatusStream(
 		return raceSessions
 	}
 	composeResponse := func(status *myStatus) *v1.GetStatusStreamResponse {
-		return &v1.GetStatusStreamResponse{
+		ret := &v1.GetStatusStreamResponse{
 			BackendAvailable:   status.BackendAvailable,
 			BackendCompatible:  status.BackendCompatible,
 			ValidCredentials:   status.ValidCredentials,
@@ -66,6 +76,8 @@ func (s *raceloggerServiceConnectRPC) GetStatusStream(
 			CurrentSessionNum:  status.CurrentSessionNum,
 			RaceSessions:       composeRaceSessions(status),
 		}
+		setBacklog(ret, status.BacklogDepth, status.BacklogOldestAge)
+		return ret
 	}
 	for status := range statusChan {
 		if err := stream.Send(composeResponse(&status)); err != nil {
@@ -77,6 +89,22 @@ func (s *raceloggerServiceConnectRPC) GetStatusStream(
 	return nil
 }
 
+// setBacklog adds the backlog of the retry queue as unknown fields to msg
+//
+//nolint:whitespace // editor/linter issue
+func setBacklog(
+	msg *v1.GetStatusStreamResponse,
+	depth int,
+	oldestAge time.Duration,
+) {
+	var b []byte
+	b = protowire.AppendTag(b, backlogDepthField, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(depth))
+	b = protowire.AppendTag(b, backlogOldestAgeField, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(oldestAge/time.Second))
+	msg.ProtoReflect().SetUnknown(b)
+}
+
 //nolint:whitespace // editor/linter issue
 func (s *raceloggerServiceConnectRPC) StartRecording(
 	ctx context.Context,
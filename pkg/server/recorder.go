@@ -6,9 +6,11 @@ import (
 	v1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/racelogger/v1"
 	"google.golang.org/grpc"
 
+	"github.com/mpapenbr/go-racelogger/internal/processor"
 	"github.com/mpapenbr/go-racelogger/internal/recorder"
 	"github.com/mpapenbr/go-racelogger/log"
 	"github.com/mpapenbr/go-racelogger/pkg/config"
+	owngrpc "github.com/mpapenbr/go-racelogger/pkg/grpc"
 )
 
 type (
@@ -17,9 +19,13 @@ type (
 		ctx             context.Context
 		cancel          context.CancelFunc
 		conn            *grpc.ClientConn // connection to the provider service
+		retryQueue      *owngrpc.RetryQueue
 		recorder        *recorder.Recorder
 		l               *log.Logger
 		cbRecordingDone func()
+		battleListener  processor.BattleListener
+		chartListener   processor.LapChartListener
+		timingListener  processor.TimingListener
 	}
 )
 
@@ -27,6 +33,7 @@ type (
 func newRecordingContext(
 	ctx context.Context,
 	conn *grpc.ClientConn,
+	retryQueue *owngrpc.RetryQueue,
 	cbRecordingDone func(),
 ) *recordingContext {
 	myCtx, cancel := context.WithCancel(ctx)
@@ -34,6 +41,7 @@ func newRecordingContext(
 		ctx:             myCtx,
 		cancel:          cancel,
 		conn:            conn,
+		retryQueue:      retryQueue,
 		cbRecordingDone: cbRecordingDone,
 		l:               log.GetFromContext(myCtx).Named("recsrv"),
 	}
@@ -45,8 +53,12 @@ func (rc *recordingContext) startRecording(msg *v1.StartRecordingRequest) {
 		recorder.WithContext(rc.ctx, rc.cancel),
 		recorder.WithCliArgs(config.DefaultCliArgs()),
 		recorder.WithConnection(rc.conn),
+		recorder.WithRetryQueue(rc.retryQueue),
 		recorder.WithEventNames([]string{msg.Name}),
 		recorder.WithEventDescriptions(msg.Descriptions),
+		recorder.WithBattleListener(rc.battleListener),
+		recorder.WithLapChartListener(rc.chartListener),
+		recorder.WithTimingListener(rc.timingListener),
 	)
 	rc.recorder.Start()
 	go func() {
@@ -2,9 +2,11 @@ package server
 
 import (
 	"context"
+	"expvar"
 	"net/http"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"buf.build/gen/go/mpapenbr/iracelog/connectrpc/go/racelogger/v1/raceloggerv1connect"
@@ -16,8 +18,10 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
 
+	"github.com/mpapenbr/go-racelogger/internal/processor"
 	"github.com/mpapenbr/go-racelogger/log"
 	"github.com/mpapenbr/go-racelogger/pkg/config"
+	owngrpc "github.com/mpapenbr/go-racelogger/pkg/grpc"
 	"github.com/mpapenbr/go-racelogger/pkg/util"
 	"github.com/mpapenbr/go-racelogger/version"
 )
@@ -31,9 +35,15 @@ type (
 		cfg         *serverConfig
 		ctx         context.Context
 		l           *log.Logger
+		statusMu    sync.Mutex // guards status
 		status      myStatus
 		recCtx      *recordingContext
 		broadcaster *Broadcaster[myStatus]
+
+		liveMu   sync.Mutex
+		battles  []processor.Battle // current battles of the recording
+		lapChart []processor.LapChartEntry
+		timing   []processor.Timing // overall and class gaps/intervals
 	}
 	raceSession struct {
 		Num  uint32
@@ -48,6 +58,9 @@ type (
 		Recording          bool
 		CurrentSessionNum  int32
 		RaceSessions       []raceSession
+		BacklogDepth       int // messages waiting in the retry queue
+		// age of the oldest message in the retry queue (not a change of the status)
+		BacklogOldestAge time.Duration
 	}
 
 	serverConfig struct {
@@ -56,6 +69,7 @@ type (
 		conn                 *grpc.ClientConn
 		addr                 string
 		backendCheckInterval time.Duration
+		retryQueue           *owngrpc.RetryQueue
 	}
 	Option interface {
 		apply(*serverConfig) *serverConfig
@@ -104,6 +118,15 @@ func WithBackendCheckInterval(interval time.Duration) Option {
 	})
 }
 
+// WithRetryQueue sets the retry queue used for recordings.
+// Its backlog is reported in the status.
+func WithRetryQueue(q *owngrpc.RetryQueue) Option {
+	return optFunc(func(cfg *serverConfig) *serverConfig {
+		cfg.retryQueue = q
+		return cfg
+	})
+}
+
 func NewServer(opts ...Option) (Server, error) {
 	cfg := newServerConfig(opts)
 
@@ -122,9 +145,29 @@ func NewServer(opts ...Option) (Server, error) {
 			srv.l = log.Default().Named("server")
 		}
 	}
+	if cfg.retryQueue != nil {
+		publishBacklog(cfg.retryQueue)
+	}
 	return srv, nil
 }
 
+// publishBacklog publishes the backlog of the retry queue at /debug/vars.
+func publishBacklog(q *owngrpc.RetryQueue) {
+	if expvar.Get("retry_queue") != nil {
+		return
+	}
+	expvar.Publish("retry_queue", expvar.Func(func() any {
+		stats := q.Stats()
+		return map[string]any{
+			"pending":          stats.Pending,
+			"bytes":            stats.Bytes,
+			"oldestAgeSeconds": int64(stats.OldestAge.Seconds()),
+			"dropped":          stats.Dropped,
+			"corrupt":          stats.Corrupt,
+		}
+	}))
+}
+
 func newServerConfig(opts []Option) *serverConfig {
 	c := &serverConfig{
 		ctx:  context.Background(),
@@ -157,6 +200,12 @@ func (s *serverImpl) startConnectRPCServer() {
 	path, handler := raceloggerv1connect.NewRaceloggerServiceHandler(
 		NewRaceloggerServiceConnectRPC(s))
 	mux.Handle(path, handler)
+	// metrics (e.g. publisher overflow counters)
+	mux.Handle("/debug/vars", expvar.Handler())
+	// live data of the current recording
+	mux.HandleFunc("/live/battles", s.handleBattles)
+	mux.HandleFunc("/live/lapchart", s.handleLapChart)
+	mux.HandleFunc("/live/timing", s.handleTiming)
 
 	// Configure CORS (otherwise browser will not allow requests)
 	corsHandler := func(h http.Handler) http.Handler {
@@ -204,16 +253,18 @@ func (s *serverImpl) showStatusUpdate() {
 	s.l.Debug("Starting status update collector")
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
-	lastStatus := s.status
+	lastStatus := s.currentStatus()
 	for {
 		select {
 		case <-ticker.C:
-			if !statusEqual(s.status, lastStatus) {
+			s.collectBacklog()
+			status := s.currentStatus()
+			if !statusEqual(status, lastStatus) {
 				s.l.Info("Status update",
-					log.Any("status", s.status),
+					log.Any("status", status),
 				)
-				lastStatus = s.status
-				s.broadcaster.Broadcast(s.status)
+				lastStatus = status
+				s.broadcaster.Broadcast(status)
 			}
 		case <-s.ctx.Done():
 			s.l.Debug("Stopping status update collector")
@@ -222,8 +273,35 @@ func (s *serverImpl) showStatusUpdate() {
 	}
 }
 
+func (s *serverImpl) collectBacklog() {
+	if s.cfg.retryQueue == nil {
+		return
+	}
+	stats := s.cfg.retryQueue.Stats()
+	s.setStatus(func(st *myStatus) {
+		st.BacklogDepth = stats.Pending
+		st.BacklogOldestAge = stats.OldestAge.Truncate(time.Second)
+	})
+}
+
+// setStatus changes the status while holding the status lock
+func (s *serverImpl) setStatus(f func(st *myStatus)) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	f(&s.status)
+}
+
+// currentStatus returns a copy of the status
+func (s *serverImpl) currentStatus() myStatus {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	return s.status
+}
+
 // statusEqual compares two myStatus structs for equality.
+// The age of the backlog changes every second, so it is not compared.
 func statusEqual(a, b myStatus) bool {
+	a.BacklogOldestAge, b.BacklogOldestAge = 0, 0
 	return reflect.DeepEqual(a, b)
 }
 
@@ -240,20 +318,26 @@ func (s *serverImpl) UnsubscribeStatus(c <-chan myStatus) {
 }
 
 func (s *serverImpl) StartRecording(msg *v1.StartRecordingRequest) *myStatus {
-	rc := newRecordingContext(s.ctx, s.cfg.conn, func() {
+	rc := newRecordingContext(s.ctx, s.cfg.conn, s.cfg.retryQueue, func() {
 		s.l.Debug("Callback recordingDone called. Marking recording as stopped")
-		s.status.Recording = false
+		s.setStatus(func(st *myStatus) { st.Recording = false })
 		s.recCtx = nil
+		s.setBattles(nil)
+		s.clearLapChart()
+		s.setTiming(nil)
 	})
+	rc.battleListener = s.setBattles
+	rc.chartListener = s.addLaps
+	rc.timingListener = s.setTiming
 	rc.startRecording(msg)
-	s.status.Recording = true
+	s.setStatus(func(st *myStatus) { st.Recording = true })
 	s.recCtx = rc
 	s.l.Debug("Recording started")
 	return &s.status
 }
 
 func (s *serverImpl) StopRecording() *myStatus {
-	s.status.Recording = false
+	s.setStatus(func(st *myStatus) { st.Recording = false })
 	if s.recCtx != nil {
 		s.recCtx.stopRecording()
 		s.recCtx = nil
@@ -273,13 +357,15 @@ func (s *serverImpl) checkIRacing() {
 			ticker.Stop()
 			return
 		case <-ticker.C:
-			s.status.SimulationRunning, _ = irsdk.IsSimRunning(s.ctx, http.DefaultClient)
-			if s.status.SimulationRunning {
+			running, _ := irsdk.IsSimRunning(s.ctx, http.DefaultClient)
+			s.setStatus(func(st *myStatus) { st.SimulationRunning = running })
+			if running {
 				if ir == nil {
 					ir = irsdk.NewIrsdk()
 				}
-				s.status.TelemetryAvailable = util.HasValidAPIData(ir)
-				if s.status.TelemetryAvailable {
+				available := util.HasValidAPIData(ir)
+				s.setStatus(func(st *myStatus) { st.TelemetryAvailable = available })
+				if available {
 					s.collectIracingData(ir)
 				} else {
 					ir.Close()
@@ -291,7 +377,7 @@ func (s *serverImpl) checkIRacing() {
 					ir.Close()
 					ir = nil
 				}
-				s.status.TelemetryAvailable = false
+				s.setStatus(func(st *myStatus) { st.TelemetryAvailable = false })
 				s.resetIracingData()
 			}
 		}
@@ -299,9 +385,11 @@ func (s *serverImpl) checkIRacing() {
 }
 
 func (s *serverImpl) resetIracingData() {
-	s.status.CurrentSessionNum = -1
-	s.status.RaceSessions = make([]raceSession, 0)
-	if s.status.Recording {
+	s.setStatus(func(st *myStatus) {
+		st.CurrentSessionNum = -1
+		st.RaceSessions = make([]raceSession, 0)
+	})
+	if s.currentStatus().Recording {
 		s.l.Debug("Stopping recording due to iRacing telemetry not available")
 		s.StopRecording()
 	}
@@ -323,8 +411,11 @@ func (s *serverImpl) collectIracingData(ir *irsdk.Irsdk) {
 			})
 		}
 	}
-	s.status.CurrentSessionNum, _ = ir.GetIntValue("SessionNum")
-	s.status.RaceSessions = raceSessions
+	sessionNum, _ := ir.GetIntValue("SessionNum")
+	s.setStatus(func(st *myStatus) {
+		st.CurrentSessionNum = sessionNum
+		st.RaceSessions = raceSessions
+	})
 }
 
 func (s *serverImpl) checkBackend() {
@@ -345,13 +436,17 @@ func (s *serverImpl) checkBackend() {
 				&providerv1.VersionCheckRequest{
 					RaceloggerVersion: version.Version,
 				}); err != nil {
-				s.status.BackendAvailable = false
-				s.status.BackendCompatible = false
-				s.status.ValidCredentials = false
+				s.setStatus(func(st *myStatus) {
+					st.BackendAvailable = false
+					st.BackendCompatible = false
+					st.ValidCredentials = false
+				})
 			} else {
-				s.status.BackendAvailable = true
-				s.status.BackendCompatible = res.RaceloggerCompatible
-				s.status.ValidCredentials = res.ValidCredentials
+				s.setStatus(func(st *myStatus) {
+					st.BackendAvailable = true
+					st.BackendCompatible = res.RaceloggerCompatible
+					st.ValidCredentials = res.ValidCredentials
+				})
 			}
 		}
 	}
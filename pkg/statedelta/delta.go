@@ -0,0 +1,247 @@
+// Package statedelta reduces the size of state messages by sending only the
+// car fields which changed since the previous message.
+//
+// The encoder emits a keyframe (a complete state message) every n messages.
+// In between it emits delta messages. A delta message contains all cars in the
+// current order, but each car only carries the fields which changed compared to
+// the previous message. Session and race messages are always complete.
+//
+// Delta messages are regular PublishStateRequest messages with additional
+// (unknown) fields:
+//   - the request is marked as delta
+//   - each car lists the numbers of the changed fields. This way a field changed
+//     to its zero value can be distinguished from an unchanged field.
+//
+// Delta messages must be decoded before they are sent to the backend.
+package statedelta
+
+import (
+	"bytes"
+	"errors"
+
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// field number of the delta marker (request) and the changed fields (car)
+	deltaFieldNum protowire.Number = 15000
+)
+
+var (
+	ErrMissingKeyframe = errors.New("delta message without previous keyframe")
+	ErrInvalidDelta    = errors.New("invalid delta message")
+)
+
+// IsDelta returns true if req is a delta message
+func IsDelta(req *racestatev1.PublishStateRequest) bool {
+	_, ok := findUnknown(req.ProtoReflect(), deltaFieldNum)
+	return ok
+}
+
+type Encoder struct {
+	keyframeInterval int
+	count            int
+	last             map[int32]*racestatev1.Car
+}
+
+// NewEncoder creates an encoder which emits a keyframe every keyframeInterval
+// messages. A value <= 1 disables the delta encoding.
+func NewEncoder(keyframeInterval int) *Encoder {
+	return &Encoder{keyframeInterval: keyframeInterval}
+}
+
+// Reset starts over with a keyframe
+func (e *Encoder) Reset() {
+	e.count = 0
+	e.last = nil
+}
+
+// Encode returns the message to be sent instead of req.
+// req is not modified.
+//
+//nolint:whitespace // can't get different linters happy
+func (e *Encoder) Encode(
+	req *racestatev1.PublishStateRequest,
+) *racestatev1.PublishStateRequest {
+	keyframe := e.keyframeInterval <= 1 || e.count%e.keyframeInterval == 0
+	e.count++
+	last := e.last
+	e.last = make(map[int32]*racestatev1.Car, len(req.Cars))
+	for _, c := range req.Cars {
+		e.last[c.CarIdx] = c
+	}
+	if keyframe {
+		return req
+	}
+
+	ret := &racestatev1.PublishStateRequest{
+		Event:     req.Event,
+		Session:   req.Session,
+		Messages:  req.Messages,
+		Timestamp: req.Timestamp,
+		Cars:      make([]*racestatev1.Car, len(req.Cars)),
+	}
+	for i, c := range req.Cars {
+		ret.Cars[i] = diffCar(last[c.CarIdx], c)
+	}
+	setUnknown(ret.ProtoReflect(), deltaFieldNum, protowire.AppendVarint(nil, 1))
+	return ret
+}
+
+// diffCar creates a car containing the fields of cur which differ from prev.
+// If prev is nil all fields are included.
+func diffCar(prev, cur *racestatev1.Car) *racestatev1.Car {
+	ret := &racestatev1.Car{CarIdx: cur.CarIdx}
+	dst := ret.ProtoReflect()
+	src := cur.ProtoReflect()
+	changed := []byte{}
+	fields := src.Descriptor().Fields()
+	for i := range fields.Len() {
+		fd := fields.Get(i)
+		if prev != nil && fieldEqual(prev.ProtoReflect(), src, fd) {
+			continue
+		}
+		if src.Has(fd) {
+			dst.Set(fd, src.Get(fd))
+		}
+		changed = protowire.AppendVarint(changed, uint64(fd.Number()))
+	}
+	setUnknown(dst, deltaFieldNum, changed)
+	return ret
+}
+
+// fieldEqual compares the field fd of a and b by their encoding
+func fieldEqual(a, b protoreflect.Message, fd protoreflect.FieldDescriptor) bool {
+	if a.Has(fd) != b.Has(fd) {
+		return false
+	}
+	if !a.Has(fd) {
+		return true
+	}
+	return bytes.Equal(encodeField(a, fd), encodeField(b, fd))
+}
+
+func encodeField(m protoreflect.Message, fd protoreflect.FieldDescriptor) []byte {
+	tmp := m.New()
+	tmp.Set(fd, m.Get(fd))
+	b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(tmp.Interface())
+	return b
+}
+
+type Decoder struct {
+	last map[int32]*racestatev1.Car
+}
+
+func NewDecoder() *Decoder {
+	return &Decoder{}
+}
+
+// Decode returns the complete state message for req.
+// Keyframes are returned as they are.
+//
+//nolint:whitespace // can't get different linters happy
+func (d *Decoder) Decode(
+	req *racestatev1.PublishStateRequest,
+) (*racestatev1.PublishStateRequest, error) {
+	if !IsDelta(req) {
+		d.remember(req.Cars)
+		return req, nil
+	}
+	if d.last == nil {
+		return nil, ErrMissingKeyframe
+	}
+	ret := &racestatev1.PublishStateRequest{
+		Event:     req.Event,
+		Session:   req.Session,
+		Messages:  req.Messages,
+		Timestamp: req.Timestamp,
+		Cars:      make([]*racestatev1.Car, len(req.Cars)),
+	}
+	for i, c := range req.Cars {
+		car, err := d.applyCar(d.last[c.CarIdx], c)
+		if err != nil {
+			return nil, err
+		}
+		ret.Cars[i] = car
+	}
+	d.remember(ret.Cars)
+	return ret, nil
+}
+
+func (d *Decoder) remember(cars []*racestatev1.Car) {
+	d.last = make(map[int32]*racestatev1.Car, len(cars))
+	for _, c := range cars {
+		d.last[c.CarIdx] = c
+	}
+}
+
+// applyCar applies the changed fields of delta to prev
+func (d *Decoder) applyCar(prev, delta *racestatev1.Car) (*racestatev1.Car, error) {
+	changed, ok := findUnknown(delta.ProtoReflect(), deltaFieldNum)
+	if !ok {
+		return nil, ErrInvalidDelta
+	}
+	var ret *racestatev1.Car
+	if prev != nil {
+		ret = proto.Clone(prev).(*racestatev1.Car)
+	} else {
+		ret = &racestatev1.Car{}
+	}
+	dst := ret.ProtoReflect()
+	src := delta.ProtoReflect()
+	fields := src.Descriptor().Fields()
+	for len(changed) > 0 {
+		num, n := protowire.ConsumeVarint(changed)
+		if n < 0 {
+			return nil, ErrInvalidDelta
+		}
+		changed = changed[n:]
+		//nolint:gosec // field numbers are small
+		fd := fields.ByNumber(protoreflect.FieldNumber(num))
+		if fd == nil {
+			continue // field unknown to this version
+		}
+		if src.Has(fd) {
+			dst.Set(fd, src.Get(fd))
+		} else {
+			dst.Clear(fd)
+		}
+	}
+	ret.CarIdx = delta.CarIdx
+	return ret, nil
+}
+
+// setUnknown adds a bytes field with number num to the unknown fields of m
+func setUnknown(m protoreflect.Message, num protowire.Number, value []byte) {
+	b := protowire.AppendTag(nil, num, protowire.BytesType)
+	b = protowire.AppendBytes(b, value)
+	m.SetUnknown(append(m.GetUnknown(), b...))
+}
+
+// findUnknown returns the value of the unknown bytes field num
+func findUnknown(m protoreflect.Message, num protowire.Number) ([]byte, bool) {
+	b := m.GetUnknown()
+	for len(b) > 0 {
+		n, t, l := protowire.ConsumeTag(b)
+		if l < 0 {
+			return nil, false
+		}
+		b = b[l:]
+		if n == num && t == protowire.BytesType {
+			v, vl := protowire.ConsumeBytes(b)
+			if vl < 0 {
+				return nil, false
+			}
+			return v, true
+		}
+		vl := protowire.ConsumeFieldValue(n, t, b)
+		if vl < 0 {
+			return nil, false
+		}
+		b = b[vl:]
+	}
+	return nil, false
+}
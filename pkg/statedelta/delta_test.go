@@ -0,0 +1,91 @@
+package statedelta
+
+import (
+	"testing"
+
+	racestatev1 "buf.build/gen/go/mpapenbr/iracelog/protocolbuffers/go/iracelog/racestate/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func car(idx int32, pos int32, lap int32, dist float32) *racestatev1.Car {
+	return &racestatev1.Car{
+		CarIdx: idx, Pos: pos, Lap: lap, Dist: dist, TrackPos: dist / 1000,
+		TimeInfo:     &racestatev1.TimeInfo{Time: float32(lap) * 90},
+		TireCompound: &racestatev1.TireCompound{RawValue: 1},
+	}
+}
+
+func states() []*racestatev1.PublishStateRequest {
+	return []*racestatev1.PublishStateRequest{
+		{Cars: []*racestatev1.Car{car(1, 1, 3, 100), car(2, 2, 3, 50)}},
+		{Cars: []*racestatev1.Car{car(1, 1, 3, 200), car(2, 2, 3, 150)}},
+		// position change and a field changed to its zero value
+		{Cars: []*racestatev1.Car{car(2, 1, 4, 0), car(1, 2, 3, 300)}},
+		// new car
+		{Cars: []*racestatev1.Car{car(2, 1, 4, 0), car(1, 2, 3, 300), car(5, 3, 1, 10)}},
+		{Cars: []*racestatev1.Car{car(2, 1, 4, 10), car(5, 2, 1, 20)}},
+		{
+			Cars:     []*racestatev1.Car{car(2, 1, 4, 10), car(5, 2, 1, 20)},
+			Messages: []*racestatev1.Message{{Msg: "msg"}},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	enc := NewEncoder(3)
+	dec := NewDecoder()
+	for i, s := range states() {
+		orig := proto.Clone(s)
+		encoded := enc.Encode(s)
+		assert.True(t, proto.Equal(orig, s), "encoder must not modify the request")
+		assert.Equal(t, i%3 != 0, IsDelta(encoded), "msg %d", i)
+
+		// simulate writing to a msg log
+		b, err := proto.Marshal(encoded)
+		require.NoError(t, err)
+		read := &racestatev1.PublishStateRequest{}
+		require.NoError(t, proto.Unmarshal(b, read))
+
+		got, err := dec.Decode(read)
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(s, got), "msg %d: want %v, got %v", i, s, got)
+	}
+}
+
+func TestDeltaIsSmaller(t *testing.T) {
+	enc := NewEncoder(10)
+	s := states()
+	enc.Encode(s[0])
+	delta := enc.Encode(s[1])
+	assert.Less(t, proto.Size(delta), proto.Size(s[1]))
+	for _, c := range delta.Cars {
+		assert.Nil(t, c.TireCompound, "unchanged fields must not be sent")
+		assert.NotZero(t, c.Dist)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	enc := NewEncoder(0)
+	for _, s := range states() {
+		assert.Same(t, s, enc.Encode(s))
+	}
+}
+
+func TestMissingKeyframe(t *testing.T) {
+	enc := NewEncoder(3)
+	s := states()
+	enc.Encode(s[0])
+	delta := enc.Encode(s[1])
+
+	dec := NewDecoder()
+	_, err := dec.Decode(delta)
+	require.ErrorIs(t, err, ErrMissingKeyframe)
+
+	// decoding works again after the next keyframe
+	enc.Encode(s[2])
+	got, err := dec.Decode(enc.Encode(s[3]))
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(s[3], got))
+}
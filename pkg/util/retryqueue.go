@@ -0,0 +1,41 @@
+package util
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/mpapenbr/go-racelogger/pkg/config"
+	owngrpc "github.com/mpapenbr/go-racelogger/pkg/grpc"
+)
+
+// OpenRetryQueue opens and starts the retry queue configured by cfg.
+// Returns nil if no retry queue is configured.
+//
+//nolint:whitespace // can't get different linters happy
+func OpenRetryQueue(
+	ctx context.Context,
+	conn *grpc.ClientConn,
+	cfg *config.CliArgs,
+) (*owngrpc.RetryQueue, error) {
+	if cfg.RetryQueueDir == "" {
+		return nil, nil //nolint:nilnil // no retry queue configured
+	}
+	maxAge, err := time.ParseDuration(cfg.RetryQueueMaxAge)
+	if err != nil {
+		maxAge = 2 * time.Hour
+	}
+	q, err := owngrpc.NewRetryQueue(cfg.RetryQueueDir,
+		owngrpc.NewDataProviderClient(
+			owngrpc.WithConnection(conn),
+			owngrpc.WithToken(cfg.Token)),
+		owngrpc.WithQueueMaxSize(cfg.RetryQueueMaxSize*1024*1024),
+		owngrpc.WithQueueMaxAge(maxAge),
+	)
+	if err != nil {
+		return nil, err
+	}
+	q.Start(ctx)
+	return q, nil
+}